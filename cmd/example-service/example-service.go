@@ -20,8 +20,20 @@ import (
 
 	"github.com/rumsrami/example-service/cmd/example-service/internal/handlers"
 	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/erasure"
+	"github.com/rumsrami/example-service/internal/expiry"
+	"github.com/rumsrami/example-service/internal/export"
+	"github.com/rumsrami/example-service/internal/moderation"
+	"github.com/rumsrami/example-service/internal/pending"
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/platform/blob"
 	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/platform/ratelimit"
 	"github.com/rumsrami/example-service/internal/platform/web"
+	"github.com/rumsrami/example-service/internal/presence"
+	"github.com/rumsrami/example-service/internal/rpc"
+	"github.com/rumsrami/example-service/internal/schedule"
+	"github.com/rumsrami/example-service/internal/thumbnail"
 )
 
 const (
@@ -47,6 +59,11 @@ const (
 	errGracefulShutdown        = "could not stop server gracefully"
 	errNatsServer              = "nats server error"
 	errNatsBroker              = "nats broker error"
+	errPresence                = "presence tracker error"
+	errBlobStore               = "blob store error"
+	errScheduler               = "message scheduler error"
+	errEraser                  = "user eraser error"
+	errPendingQueues           = "pending queues error"
 	errAWSSession              = "aws session error"
 	errDynamoDb                = "aws dynamodb unknown error"
 	errGoProcesses             = "error running go process"
@@ -84,11 +101,75 @@ func start() error {
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
 			ShutdownTimeout time.Duration `conf:"default:20s"`
 		}
+		Chat struct {
+			DeleteWindow  time.Duration `conf:"default:1h"`
+			TypingTimeout time.Duration `conf:"default:6s"`
+			// how long a client MessageUUID is remembered to drop retries
+			DedupeWindow time.Duration `conf:"default:24h"`
+			// how often instances share the users connected to them
+			PresenceInterval time.Duration `conf:"default:10s"`
+			// longest lifetime a conversation can give its messages
+			MaxMessageTTL time.Duration `conf:"default:720h"`
+			// events kept for a user while none of their streams gets them
+			PendingEvents int `conf:"default:500"`
+			// how often expired messages are purged
+			ExpiryInterval time.Duration `conf:"default:1s"`
+		}
+		Attachments struct {
+			// directory of the filesystem blob store
+			Dir string `conf:"default:/tmp/example-service/blobs"`
+			// largest attachment accepted, in bytes
+			MaxSize int64 `conf:"default:10485760"`
+			// storage every user can fill with attachments, in bytes
+			Quota int64 `conf:"default:104857600"`
+			// longest side of image thumbnails, in pixels
+			ThumbnailSize int `conf:"default:320"`
+			// images processed at once
+			ThumbnailWorkers int `conf:"default:2"`
+		}
+		Schedule struct {
+			// directory scheduled messages are kept in, shared by every instance. It must
+			// outlive restarts and be on storage every instance mounts
+			Dir string `conf:"default:/var/lib/example-service/scheduled"`
+			// how often due messages are looked for
+			Interval time.Duration `conf:"default:1s"`
+			// how long an instance has to send a message it claimed
+			Lease time.Duration `conf:"default:1m"`
+		}
+		Export struct {
+			// data exports written at once
+			Workers int `conf:"default:1"`
+		}
+		Erasure struct {
+			// directory erasure journals and completion records are kept in
+			Dir string `conf:"default:/tmp/example-service/erasures"`
+		}
+		RateLimit struct {
+			// write calls per second allowed to every user once their burst is spent
+			Rate  float64 `conf:"default:5"`
+			Burst int     `conf:"default:20"`
+			// write calls per second allowed to admins
+			AdminRate  float64 `conf:"default:20"`
+			AdminBurst int     `conf:"default:100"`
+		}
+		Moderation struct {
+			// longest message text accepted, in characters
+			MaxLength int `conf:"default:4000"`
+			// words and phrases messages are rejected for, separated by ;
+			Blocklist []string
+			// hosts links can point to, separated by ; links are not checked when empty
+			AllowedHosts []string
+			// card and phone numbers are replaced in message text
+			RedactPII bool `conf:"default:true"`
+		}
 		ZAuth struct {
 			// used with the authentication middleware
 			// to verify the jwt token
 			Authority string `conf:"default:https://localhost.auth0.com/"`
 			Audience  string `conf:"default:http://localhost:9000"`
+			// claims of the token the caller email and role are read from
+			EmailClaim string `conf:"default:email"`
+			RoleClaim  string `conf:"default:role"`
 		}
 	}
 	cfg.Version.SVN = build
@@ -199,12 +280,6 @@ func start() error {
 		stOutLogger.Info().Msgf(fmt.Sprintf("main : Started : Debuging Listening %s", cfg.Web.DebugHost))
 	}
 
-	errGroup := make(chan error)
-
-	go func() {
-		errGroup <- g.Run()
-	}()
-
 	// =========================================================================
 	// Start AWS Session
 	// -> Commented out as it needs AWS credentials in Environmen
@@ -227,6 +302,26 @@ func start() error {
 
 	stOutLogger.Info().Msgf("main : Started : NATS Client support")
 
+	// =========================================================================
+	// Start Presence Tracking
+
+	stOutLogger.Info().Msgf("main : Initializing : Presence support")
+
+	// instances share the users connected to them over nats
+	tracker, err := presence.NewTracker(natsClient, cfg.Chat.PresenceInterval)
+	if err != nil {
+		return errors.Wrap(err, errPresence)
+	}
+	{
+		g.Add(func() error {
+			return tracker.Run()
+		}, func(error) {
+			tracker.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Presence support")
+
 	// =========================================================================
 	// Start Database
 
@@ -279,14 +374,178 @@ func start() error {
 
 	stOutLogger.Info().Msgf("main : Started : Database support")
 
+	// =========================================================================
+	// Start Blob Store
+
+	stOutLogger.Info().Msgf("main : Initializing : Blob store support")
+
+	// attachments are kept on the local filesystem keyed by their SHA-256
+	blobs, err := blob.NewFileStore(cfg.Attachments.Dir)
+	if err != nil {
+		return errors.Wrap(err, errBlobStore)
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Blob store support")
+
+	// =========================================================================
+	// Start Thumbnail Generation
+
+	stOutLogger.Info().Msgf("main : Initializing : Thumbnail support")
+
+	// image attachments are processed in the background by a bounded pool of workers
+	thumbnails := thumbnail.NewGenerator(blobs, cfg.Attachments.ThumbnailWorkers, cfg.Attachments.ThumbnailSize)
+	{
+		g.Add(func() error {
+			return thumbnails.Run()
+		}, func(error) {
+			thumbnails.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Thumbnail support")
+
+	// =========================================================================
+	// Start Message Scheduling
+
+	stOutLogger.Info().Msgf("main : Initializing : Scheduling support")
+
+	// scheduled messages survive restarts, every instance dispatches from the same directory
+	scheduler, err := schedule.NewScheduler(cfg.Schedule.Dir, cfg.Schedule.Interval, cfg.Schedule.Lease)
+	if err != nil {
+		return errors.Wrap(err, errScheduler)
+	}
+	{
+		g.Add(func() error {
+			return scheduler.Run()
+		}, func(error) {
+			scheduler.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Scheduling support")
+
+	// =========================================================================
+	// Start Message Expiry
+
+	stOutLogger.Info().Msgf("main : Initializing : Message expiry support")
+
+	// messages of conversations with a lifetime are purged once expired
+	sweeper := expiry.NewSweeper(database, cfg.Chat.ExpiryInterval)
+	{
+		g.Add(func() error {
+			return sweeper.Run()
+		}, func(error) {
+			sweeper.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Message expiry support")
+
+	// =========================================================================
+	// Start Data Export
+
+	stOutLogger.Info().Msgf("main : Initializing : Data export support")
+
+	// archives of the data of users are written in the background to the blob store
+	exporter := export.NewExporter(database, blobs, scheduler, cfg.Export.Workers)
+	{
+		g.Add(func() error {
+			return exporter.Run()
+		}, func(error) {
+			exporter.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Data export support")
+
+	// =========================================================================
+	// Start User Erasure
+
+	stOutLogger.Info().Msgf("main : Initializing : Erasure support")
+
+	// erasures interrupted by a restart are resumed once running
+	eraser, err := erasure.NewEraser(cfg.Erasure.Dir, database, blobs, scheduler, natsClient)
+	if err != nil {
+		return errors.Wrap(err, errEraser)
+	}
+	{
+		g.Add(func() error {
+			return eraser.Run()
+		}, func(error) {
+			eraser.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Erasure support")
+
+	// =========================================================================
+	// Start Pending Events
+
+	stOutLogger.Info().Msgf("main : Initializing : Pending events support")
+
+	// instances apply the events the others queue so that streams replay them on any instance
+	queues, err := pending.NewQueues(database, natsClient, cfg.Chat.PendingEvents)
+	if err != nil {
+		return errors.Wrap(err, errPendingQueues)
+	}
+	{
+		g.Add(func() error {
+			return queues.Run()
+		}, func(error) {
+			queues.Stop()
+		})
+	}
+
+	stOutLogger.Info().Msgf("main : Started : Pending events support")
+
 	// =========================================================================
 	// Start Routing Service
 
 	stOutLogger.Info().Msgf("main : Initializing : Routing support")
 
-	handlers.Mount(build, database, cfg.ZAuth.Authority, cfg.ZAuth.Audience, natsClient, app, stOutLogger)
+	// write calls are rate limited per caller depending on their role
+	limiter := ratelimit.NewLimiter(map[string]ratelimit.Rate{
+		auth.RoleAdmin: {PerSecond: cfg.RateLimit.AdminRate, Burst: cfg.RateLimit.AdminBurst},
+	}, ratelimit.Rate{PerSecond: cfg.RateLimit.Rate, Burst: cfg.RateLimit.Burst})
+
+	// every new message and edit goes through the moderation stages in order
+	stages := []moderation.Stage{moderation.MaxLength(cfg.Moderation.MaxLength)}
+	if len(cfg.Moderation.Blocklist) > 0 {
+		stages = append(stages, moderation.Blocklist(cfg.Moderation.Blocklist))
+	}
+	if len(cfg.Moderation.AllowedHosts) > 0 {
+		stages = append(stages, moderation.URLAllowlist(cfg.Moderation.AllowedHosts))
+	}
+	if cfg.Moderation.RedactPII {
+		stages = append(stages, moderation.RedactPII())
+	}
+	pipeline := moderation.NewPipeline(stages...)
+
+	chatCfg := rpc.Config{
+		DeleteWindow:      cfg.Chat.DeleteWindow,
+		TypingTimeout:     cfg.Chat.TypingTimeout,
+		DedupeWindow:      cfg.Chat.DedupeWindow,
+		MaxAttachmentSize: cfg.Attachments.MaxSize,
+		StorageQuota:      cfg.Attachments.Quota,
+		MaxMessageTTL:     cfg.Chat.MaxMessageTTL,
+	}
+
+	// callers are identified by the JWT tokens the authority issues for the audience
+	verifier := auth.NewVerifier(cfg.ZAuth.Authority, cfg.ZAuth.Audience, cfg.ZAuth.EmailClaim, cfg.ZAuth.RoleClaim)
+
+	handlers.Mount(build, database, verifier, chatCfg, natsClient, blobs, tracker, thumbnails, scheduler, sweeper, limiter, pipeline, exporter, eraser, queues, app, stOutLogger)
 
 	stOutLogger.Info().Msgf("main : Started : Routing support")
+
+	// =========================================================================
+	// Start the run group once every service has been added,
+	// actors added after Run is called are never executed
+	errGroup := make(chan error)
+
+	go func() {
+		errGroup <- g.Run()
+	}()
+
 	stOutLogger.Info().Msgf(fmt.Sprintf("main : Started : Application version %q", build))
 
 	// =========================================================================
@@ -306,11 +565,11 @@ func start() error {
 
 		// Give outstanding requests a deadline for completion.
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
 
 		// Asking listener to shutdown and load shed.
 		err := httpServer.Shutdown(ctx)
 		if err != nil {
-			cancel()
 			return errors.Wrap(err, errGracefulShutdown)
 		}
 		err = <-errGroup
@@ -8,19 +8,30 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/erasure"
+	"github.com/rumsrami/example-service/internal/expiry"
+	"github.com/rumsrami/example-service/internal/export"
+	"github.com/rumsrami/example-service/internal/moderation"
+	"github.com/rumsrami/example-service/internal/pending"
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/platform/blob"
 	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/platform/ratelimit"
 	"github.com/rumsrami/example-service/internal/platform/web"
+	"github.com/rumsrami/example-service/internal/presence"
 	"github.com/rumsrami/example-service/internal/proto"
 	"github.com/rumsrami/example-service/internal/rpc"
+	"github.com/rumsrami/example-service/internal/schedule"
+	"github.com/rumsrami/example-service/internal/thumbnail"
 )
 
 // Mount connects the dots :)
-func Mount(build string, db *db.Database, authority, audience string, mb broker.MessageBroker, app *web.App, stOutLogger zerolog.Logger) {
+func Mount(build string, db *db.Database, verifier *auth.Verifier, chatCfg rpc.Config, mb broker.MessageBroker, blobs blob.Store, tracker *presence.Tracker, thumbnails *thumbnail.Generator, scheduler *schedule.Scheduler, sweeper *expiry.Sweeper, limiter *ratelimit.Limiter, pipeline *moderation.Pipeline, exporter *export.Exporter, eraser *erasure.Eraser, queues *pending.Queues, app *web.App, stOutLogger zerolog.Logger) {
 	// Create struct validator
 	validate := validator.New()
 
 	// Create new RPC Handler
-	chat := rpc.NewChat(app, build, db, stOutLogger, validate, mb)
+	chat := rpc.NewChat(app, build, db, stOutLogger, validate, mb, blobs, chatCfg, tracker, thumbnails, scheduler, sweeper, pipeline, exporter, eraser, queues)
 
 	app.Mux.Use(middleware.RequestID)
 	app.Mux.Use(middleware.RealIP)
@@ -30,7 +41,7 @@ func Mount(build string, db *db.Database, authority, audience string, mb broker.
 	app.Mux.Get("/_ah/health", getHealth(build))
 
 	// Handle Websockets
-	// Authenticates using a secure cookie
+	// Authenticates using JWT token
 	app.Mux.Group(func(r chi.Router) {
 		cors := cors.New(cors.Options{
 			AllowOriginFunc:  allowOriginFunc,
@@ -41,7 +52,8 @@ func Mount(build string, db *db.Database, authority, audience string, mb broker.
 			MaxAge:           600,
 		})
 		r.Use(cors.Handler)
-		r.Handle("/stream", Stream(mb, stOutLogger))
+		r.Use(authenticateStream(verifier))
+		r.Handle("/stream", Stream(mb, chat, tracker, stOutLogger))
 	})
 
 	// Handle RPC calls
@@ -49,15 +61,23 @@ func Mount(build string, db *db.Database, authority, audience string, mb broker.
 	app.Mux.Group(func(r chi.Router) {
 		cors := cors.New(cors.Options{
 			AllowOriginFunc:  allowOriginFunc,
-			AllowedMethods:   []string{"OPTIONS", "POST"},
+			AllowedMethods:   []string{"GET", "OPTIONS", "POST"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "User-Agent"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", retryAfterHeader},
 			AllowCredentials: true,
 			MaxAge:           600,
 		})
 		r.Use(cors.Handler)
+		r.Use(authenticate(verifier))
+		r.Use(rateLimit(limiter))
 		//Handle rpc calls
 		webrpcHandler := proto.NewChatServer(chat)
 		r.Handle("/rpc/*", webrpcHandler)
+		//Handle attachments
+		r.Post("/attachments", uploadAttachment(chat))
+		r.Get("/attachments/{attachmentID}", downloadAttachment(chat, stOutLogger))
+		r.Get("/attachments/{attachmentID}/thumbnail", downloadThumbnail(chat, stOutLogger))
+		//Handle data exports
+		r.Get("/exports/{exportID}", downloadExport(chat, stOutLogger))
 	})
 }
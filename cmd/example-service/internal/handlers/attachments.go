@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+	"gopkg.in/matryer/respond.v1"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// attachment download error
+	downloadErr = "attachment download error"
+)
+
+// attachmentService is the part of the RPC server the attachment endpoints rely on
+type attachmentService interface {
+	UploadAttachment(ctx context.Context, fileName string, body io.Reader, size int64) (*proto.Attachment, error)
+	OpenAttachment(ctx context.Context, attachmentID string) (*proto.Attachment, io.ReadCloser, error)
+	OpenThumbnail(ctx context.Context, attachmentID string) (*proto.Thumbnail, io.ReadCloser, error)
+}
+
+// uploadAttachment stores the request body as an attachment of the caller,
+// the file name is passed in the name query parameter
+func uploadAttachment(chat attachmentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, err := chat.UploadAttachment(r.Context(), r.URL.Query().Get("name"), r.Body, r.ContentLength)
+		if err != nil {
+			proto.RespondWithError(w, err)
+			return
+		}
+		respond.With(w, r, http.StatusCreated, attachment)
+	}
+}
+
+// downloadAttachment sends an attachment the caller has access to
+func downloadAttachment(chat attachmentService, logger zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, content, err := chat.OpenAttachment(r.Context(), chi.URLParam(r, "attachmentID"))
+		if err != nil {
+			proto.RespondWithError(w, err)
+			return
+		}
+		defer content.Close()
+
+		// attachments are never rendered in place of the app
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+		sendContent(w, attachment.ContentType, attachment.Size, disposition, content, logger)
+	}
+}
+
+// downloadThumbnail sends the thumbnail of an image attachment the caller has access to
+func downloadThumbnail(chat attachmentService, logger zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		thumbnail, content, err := chat.OpenThumbnail(r.Context(), chi.URLParam(r, "attachmentID"))
+		if err != nil {
+			proto.RespondWithError(w, err)
+			return
+		}
+		defer content.Close()
+
+		sendContent(w, thumbnail.ContentType, thumbnail.Size, "inline", content, logger)
+	}
+}
+
+// sendContent writes stored content, it never changes so it can be cached for good
+func sendContent(w http.ResponseWriter, contentType string, size int64, disposition string, content io.Reader, logger zerolog.Logger) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, content)
+	if err != nil {
+		logger.Err(err).Msgf("%v : cannot send content", downloadErr)
+	}
+}
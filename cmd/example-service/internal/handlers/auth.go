@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/platform/auth"
+)
+
+const (
+	// carries the JWT token of the caller as "Bearer <token>"
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+	// carries the token of streams, browsers can't set headers on event sources
+	accessTokenParam = "access_token"
+	// Errors
+	invalidTokenErr = "invalid token"
+	verifyTokenErr  = "cannot verify token"
+)
+
+// authenticate verifies the JWT token of the caller and stores the claims it carries
+// in the request context. Anonymous requests are passed through so public calls like
+// Ping keep working and the RPC methods that need a caller reject them,
+// requests with a token that doesn't verify are rejected
+func authenticate(verifier *auth.Verifier) func(http.Handler) http.Handler {
+	return authenticateFrom(verifier, false)
+}
+
+// authenticateStream is authenticate for streams,
+// the token can also be passed as the access_token query parameter
+func authenticateStream(verifier *auth.Verifier) func(http.Handler) http.Handler {
+	return authenticateFrom(verifier, true)
+}
+
+func authenticateFrom(verifier *auth.Verifier, fromQuery bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r, fromQuery)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := verifier.Verify(r.Context(), token)
+			if errors.Cause(err) == auth.ErrInvalidToken {
+				http.Error(w, invalidTokenErr, http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, verifyTokenErr, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// bearerToken returns the token of the Authorization header of r,
+// or of its access_token query parameter when fromQuery is set
+func bearerToken(r *http.Request, fromQuery bool) string {
+	if h := r.Header.Get(authorizationHeader); strings.HasPrefix(h, bearerPrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(h, bearerPrefix))
+	}
+	if fromQuery {
+		return r.URL.Query().Get(accessTokenParam)
+	}
+	return ""
+}
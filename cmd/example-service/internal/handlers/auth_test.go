@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/platform/auth"
+)
+
+const testAudience = "https://chat.example.com"
+
+// testIssuer issues the tokens of the callers of the tests and publishes the key they are signed with
+type testIssuer struct {
+	key *rsa.PrivateKey
+	srv *httptest.Server
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{key: key}
+	iss.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(iss.srv.Close)
+	return iss
+}
+
+func (iss *testIssuer) verifier() *auth.Verifier {
+	return auth.NewVerifier(iss.srv.URL+"/", testAudience, "email", "role")
+}
+
+// token returns a token of email with role
+func (iss *testIssuer) token(t *testing.T, email, role string) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := segment(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + segment(map[string]interface{}{
+		"iss":   iss.srv.URL + "/",
+		"aud":   testAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": email,
+		"role":  role,
+	})
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// authorize sets the token of email with role on req
+func (iss *testIssuer) authorize(t *testing.T, req *http.Request, email, role string) {
+	t.Helper()
+	req.Header.Set(authorizationHeader, bearerPrefix+iss.token(t, email, role))
+}
+
+// claimsServer answers with the email and role of the authenticated caller
+func claimsServer(t *testing.T, iss *testIssuer) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(authenticate(iss.verifier())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.FromContext(r.Context())
+		if !ok {
+			w.Write([]byte("anonymous"))
+			return
+		}
+		w.Write([]byte(claims.Email + " " + claims.Role))
+	})))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, req *http.Request) (int, string) {
+	t.Helper()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var b [256]byte
+	n, _ := res.Body.Read(b[:])
+	return res.StatusCode, string(b[:n])
+}
+
+func TestAuthenticateTrustsVerifiedTokensOnly(t *testing.T) {
+	iss := newTestIssuer(t)
+	srv := claimsServer(t, iss)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	iss.authorize(t, req, "a@x.com", auth.RoleAdmin)
+	if status, body := get(t, req); status != http.StatusOK || body != "a@x.com admin" {
+		t.Fatalf("verified token: %d %q", status, body)
+	}
+
+	// identity headers are not trusted
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("X-User-Email", "victim@x.com")
+	req.Header.Set("X-User-Role", auth.RoleAdmin)
+	if status, body := get(t, req); status != http.StatusOK || body != "anonymous" {
+		t.Fatalf("identity headers: %d %q, want anonymous", status, body)
+	}
+
+	// nor are tokens of another issuer
+	forged := newTestIssuer(t)
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	forged.authorize(t, req, "victim@x.com", auth.RoleAdmin)
+	if status, _ := get(t, req); status != http.StatusUnauthorized {
+		t.Fatalf("forged token: %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// only streams take the token from the query
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"?access_token="+iss.token(t, "a@x.com", ""), nil)
+	if status, body := get(t, req); status != http.StatusOK || body != "anonymous" {
+		t.Fatalf("token in the query: %d %q, want anonymous", status, body)
+	}
+}
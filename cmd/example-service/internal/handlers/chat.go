@@ -3,9 +3,11 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog"
 
+	"github.com/rumsrami/example-service/internal/event"
 	"github.com/rumsrami/example-service/internal/platform/broker"
 )
 
@@ -35,7 +37,6 @@ func (s streamer) start(ctx context.Context, topic string, brokerMsgCh chan []by
 	defer func() {
 		s.logger.Info().Msg("Streamer closed")
 	}()
-	fmt.Println("TOPIC", topic)
 	go s.broker.Sub(ctx, topic, brokerMsgCh, brokerErrCh)
 
 	for {
@@ -47,3 +48,76 @@ func (s streamer) start(ctx context.Context, topic string, brokerMsgCh chan []by
 		}
 	}
 }
+
+// joinRoom subscribes to a room topic and forwards its messages to outputCh
+// until ctx is done or the returned cancel func is called. The room id is sent
+// to readyCh once the subscription is live, or once subscribeTimeout passed
+// failing to follow a room is logged and doesn't end the stream
+func (s streamer) joinRoom(ctx context.Context, roomID string, outputCh chan []byte, readyCh chan string) context.CancelFunc {
+	roomCtx, cancel := context.WithCancel(ctx)
+	topic := fmt.Sprintf("%s%s", roomTopicPrefix, roomID)
+	roomCh := make(chan []byte, 512)
+	roomErrCh := make(chan error, 1)
+
+	go s.broker.Sub(roomCtx, topic, roomCh, roomErrCh)
+
+	// the subscription is live once the marker published to the room comes back,
+	// it is published again until then since markers published before are lost
+	marker, err := newMarker()
+	if err != nil {
+		s.logger.Err(err).Msgf("streamer cannot create marker of room: %s", roomID)
+	}
+	b, err := event.Marshal(event.Subscribed, marker)
+	if err != nil {
+		s.logger.Err(err).Msgf("streamer cannot create marker of room: %s", roomID)
+	}
+
+	// the broker closes roomCh once unsubscribed, keep draining it
+	// until then so the subscription never blocks
+	go func() {
+		ticker := time.NewTicker(markerInterval)
+		defer ticker.Stop()
+		timeout := time.NewTimer(subscribeTimeout)
+		defer timeout.Stop()
+
+		tickerC, timeoutC := ticker.C, timeout.C
+		ready := func() {
+			tickerC, timeoutC = nil, nil
+			select {
+			case readyCh <- roomID:
+			case <-roomCtx.Done():
+			}
+		}
+		_ = s.broker.Pub(topic, b)
+
+		for {
+			select {
+			case m, open := <-roomCh:
+				if !open {
+					select {
+					case err := <-roomErrCh:
+						s.logger.Err(err).Msgf("streamer cannot follow room: %s", roomID)
+					default:
+						s.logger.Info().Msgf("streamer left room: %s", roomID)
+					}
+					return
+				}
+				if marker != "" && subscribedMarker(m) == marker {
+					ready()
+					continue
+				}
+				select {
+				case outputCh <- m:
+				case <-roomCtx.Done():
+				}
+			case <-tickerC:
+				_ = s.broker.Pub(topic, b)
+			case <-timeoutC:
+				s.logger.Info().Msgf("stream subscription not confirmed on topic: %s", topic)
+				ready()
+			}
+		}
+	}()
+
+	return cancel
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// data export download error
+	exportDownloadErr = "data export download error"
+	// content type of the export archives, one JSON record per line
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// exportService is the part of the RPC server the export endpoint relies on
+type exportService interface {
+	OpenExport(ctx context.Context, exportID string) (*proto.DataExport, io.ReadCloser, error)
+}
+
+// downloadExport sends the archive of a done data export of the caller,
+// archives hold personal data so they are never cached
+func downloadExport(chat exportService, logger zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dataExport, content, err := chat.OpenExport(r.Context(), chi.URLParam(r, "exportID"))
+		if err != nil {
+			proto.RespondWithError(w, err)
+			return
+		}
+		defer content.Close()
+
+		fileName := fmt.Sprintf("export-%s.ndjson", dataExport.ExportID)
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(dataExport.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		_, err = io.Copy(w, content)
+		if err != nil {
+			logger.Err(err).Msgf("%v : cannot send archive", exportDownloadErr)
+		}
+	}
+}
@@ -1,19 +1,28 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sse"
 	"github.com/rs/zerolog"
 	"gopkg.in/matryer/respond.v1"
 
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/platform/auth"
 	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/proto"
 )
 
 const (
 	chatTopicPrefix = "users.chat."
+	roomTopicPrefix = "users.chat.room."
 
 	// sse authentication error
 	sseAuthErr = "websocket error"
@@ -23,6 +32,15 @@ const (
 
 	// sse upgrader error
 	sseUpgraderErr = " websocket upgrader error"
+
+	// sse event error
+	sseEventErr = "stream event error"
+
+	// time a stream waits for its subscription to be live,
+	// the pending events are replayed anyway past that
+	subscribeTimeout = 5 * time.Second
+	// how often a stream publishes its marker until it comes back
+	markerInterval = 50 * time.Millisecond
 )
 
 // warmup broker
@@ -55,17 +73,117 @@ func allowOriginFunc(r *http.Request, origin string) bool {
 	return false
 }
 
-// Stream handles server streams
-func Stream(broker broker.MessageBroker, logger zerolog.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// chatService is the part of the RPC server the stream relies on
+type chatService interface {
+	MarkDelivered(ctx context.Context, messageUUIDs []string) (bool, error)
+	ListRooms(ctx context.Context) ([]*proto.Room, error)
+	PendingEvents(ctx context.Context) ([]event.Event, error)
+	AckEvent(ctx context.Context, id string) error
+}
+
+// presenceTracker tracks the lifetime of stream connections
+type presenceTracker interface {
+	Connect(email string)
+	Disconnect(email string)
+}
+
+// markDelivered marks a flushed chat message as delivered when the stream
+// belongs to its recipient, as told by the claims ctx was authenticated with
+func markDelivered(ctx context.Context, chat chatService, msg proto.ChatMessage, logger zerolog.Logger) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok || msg.ToEmail != claims.Email || msg.Delivered {
+		return
+	}
+
+	_, err := chat.MarkDelivered(ctx, []string{msg.MessageUUID})
+	if err != nil {
+		logger.Err(err).Msgf("%v : cannot mark message %s delivered", sseEventErr, msg.MessageUUID)
+	}
+}
+
+// awaitSubscription publishes a marker to the topic of a stream until it comes back, every event
+// published afterwards reaches the stream. The events received meanwhile are returned,
+// an error means the stream can't go on
+func awaitSubscription(ctx context.Context, broker broker.MessageBroker, topic, marker string, brokerMessageChan chan []byte, brokerErrCh chan error, logger zerolog.Logger) ([][]byte, error) {
+	b, err := event.Marshal(event.Subscribed, marker)
+	if err != nil {
+		return nil, err
+	}
+	err = broker.Pub(topic, b)
+	if err != nil {
+		return nil, err
+	}
 
-		// extract username and role from url
-		email := r.URL.Query().Get("email")
-		role := r.URL.Query().Get("role")
+	// markers published before the subscription is live are lost
+	ticker := time.NewTicker(markerInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(subscribeTimeout)
+	defer timeout.Stop()
+
+	var received [][]byte
+	for {
+		select {
+		case err := <-brokerErrCh:
+			return nil, err
+		case brokerMessage, open := <-brokerMessageChan:
+			if !open {
+				return nil, errors.New("streamer broker channel closed")
+			}
+			if subscribedMarker(brokerMessage) == marker {
+				return received, nil
+			}
+			received = append(received, brokerMessage)
+		case <-ticker.C:
+			err = broker.Pub(topic, b)
+			if err != nil {
+				return nil, err
+			}
+		case <-timeout.C:
+			logger.Info().Msgf("stream subscription not confirmed on topic: %s", topic)
+			return received, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// newMarker returns a random marker identifying a stream
+func newMarker() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// subscribedMarker returns the marker of a subscribed event, empty for other events
+func subscribedMarker(brokerMessage []byte) string {
+	ev, err := event.Unmarshal(brokerMessage)
+	if err != nil || ev.Type != event.Subscribed {
+		return ""
+	}
+	var marker string
+	_ = json.Unmarshal(ev.Data, &marker)
+	return marker
+}
+
+// Stream handles server streams of the authenticated caller
+func Stream(broker broker.MessageBroker, chat chatService, presence presenceTracker, logger zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 
 		// get request context and wait for it to be cancelled
 		ctx := r.Context()
 
+		// the stream belongs to the caller, the chat service is called on their behalf
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			logger.Info().Msgf("%v : stream handler called without credentials", authErr)
+			http.Error(w, authErr, http.StatusUnauthorized)
+			return
+		}
+		email, role := claims.Email, claims.Role
+
 		logger.Info().Msgf("stream handler called by: %s, with the role of: %s\n", email, role)
 
 		// upgrade connection
@@ -100,12 +218,193 @@ func Stream(broker broker.MessageBroker, logger zerolog.Logger) http.HandlerFunc
 		// run the streamer
 		go newStreamer.start(ctx, topic, brokerMessageChan, brokerErrCh)
 
+		// follow the topics of the rooms the user is a member of
+		// messages from every room topic come through roomMessageChan
+		roomMessageChan := make(chan []byte, 512)
+		roomReadyCh := make(chan string)
+		rooms := make(map[string]context.CancelFunc)
+
+		joinRoom := func(roomID string) {
+			if _, ok := rooms[roomID]; ok {
+				return
+			}
+			rooms[roomID] = newStreamer.joinRoom(ctx, roomID, roomMessageChan, roomReadyCh)
+		}
+
+		leaveRoom := func(roomID string) {
+			if cancel, ok := rooms[roomID]; ok {
+				cancel()
+				delete(rooms, roomID)
+			}
+		}
+
+		memberRooms, err := chat.ListRooms(ctx)
+		if err != nil {
+			logger.Err(err).Msgf("%v : cannot list rooms of: %s", sseEventErr, email)
+		}
+		for _, room := range memberRooms {
+			joinRoom(room.RoomID)
+		}
+
+		// set once the user is erased, their stream ends
+		erased := false
+		// pending events replayed, their live copies were already sent
+		replayed := make(map[string]bool)
+
+		// send writes a broker message to the client
+		send := func(brokerMessage []byte) {
+			logger.Info().Msgf("SSE: %s", string(brokerMessage))
+
+			// the event type is used as the SSE event name
+			ev, err := event.Unmarshal(brokerMessage)
+			if err != nil {
+				logger.Err(err).Msgf("%v : cannot read broker event", sseEventErr)
+				return
+			}
+
+			// markers of the streams are not for clients,
+			// and the live events the replay covered were already sent
+			if ev.Type == event.Subscribed {
+				return
+			}
+			if replayed[ev.ID] {
+				delete(replayed, ev.ID)
+				return
+			}
+
+			// new messages of conversations the user muted are flagged
+			var delivered *proto.ChatMessage
+			switch ev.Type {
+			case event.Message:
+				var msg proto.ChatMessage
+				err := json.Unmarshal(ev.Data, &msg)
+				if err == nil && ev.MutedBy(email) {
+					msg.Muted = true
+					ev.Data, err = json.Marshal(msg)
+				}
+				if err != nil {
+					logger.Err(err).Msgf("%v : cannot read chat message", sseEventErr)
+					return
+				}
+				delivered = &msg
+			case event.Reply:
+				var reply proto.ThreadReply
+				err := json.Unmarshal(ev.Data, &reply)
+				if err == nil && reply.Reply == nil {
+					err = errors.New("thread reply without message")
+				}
+				if err == nil && ev.MutedBy(email) {
+					reply.Reply.Muted = true
+					ev.Data, err = json.Marshal(reply)
+				}
+				if err != nil {
+					logger.Err(err).Msgf("%v : cannot read thread reply", sseEventErr)
+					return
+				}
+				delivered = reply.Reply
+			}
+
+			_ = sse.Encode(w, sse.Event{
+				Event: ev.Type,
+				Data:  string(ev.Data),
+			})
+
+			f.Flush()
+
+			// the message reached one of the recipient connections
+			if delivered != nil {
+				markDelivered(ctx, chat, *delivered, logger)
+			}
+
+			// the event leaves the pending queue of the user
+			if ev.ID != "" {
+				err := chat.AckEvent(ctx, ev.ID)
+				if err != nil {
+					logger.Err(err).Msgf("%v : cannot ack event %s of: %s", sseEventErr, ev.ID, email)
+				}
+			}
+
+			switch ev.Type {
+			// membership changes of the stream user
+			case event.RoomJoined, event.RoomLeft:
+				var room proto.Room
+				err := json.Unmarshal(ev.Data, &room)
+				if err != nil {
+					logger.Err(err).Msgf("%v : cannot read room", sseEventErr)
+					return
+				}
+				if ev.Type == event.RoomJoined {
+					joinRoom(room.RoomID)
+				} else {
+					leaveRoom(room.RoomID)
+				}
+			case event.Erased:
+				erased = true
+			}
+		}
+
+		// replay sends the pending events that were not sent yet, in the order they were queued
+		replay := func() {
+			pending, err := chat.PendingEvents(ctx)
+			if err != nil {
+				logger.Err(err).Msgf("%v : cannot list pending events of: %s", sseEventErr, email)
+			}
+			for _, ev := range pending {
+				if replayed[ev.ID] {
+					continue
+				}
+				brokerMessage, err := json.Marshal(ev)
+				if err != nil {
+					logger.Err(err).Msgf("%v : cannot replay event %s of: %s", sseEventErr, ev.ID, email)
+					continue
+				}
+				send(brokerMessage)
+				replayed[ev.ID] = true
+			}
+		}
+
+		// the user is online for as long as one of their streams is open
+		presence.Connect(email)
+
 		defer func() {
 			// Done.
+			presence.Disconnect(email)
 			logger.Info().Msgf("stream handler ended by: %s, with the role of: %s", email, role)
 			return
 		}()
 
+		// the events published while no stream of the user got them are replayed before
+		// the live ones. The subscriptions to the user and room topics are live before they
+		// are read so nothing published in between is missed, and the live events the replay
+		// covered are dropped
+		marker, err := newMarker()
+		if err != nil {
+			logger.Err(err).Msgf("%v : cannot create stream marker", sseEventErr)
+			return
+		}
+		received, err := awaitSubscription(ctx, broker, topic, marker, brokerMessageChan, brokerErrCh, logger)
+		if err != nil {
+			logger.Info().Msgf("cannot subscribe to broker: %v", err)
+			return
+		}
+		for joining := len(rooms); joining > 0; {
+			select {
+			case <-roomReadyCh:
+				joining--
+			case brokerMessage := <-roomMessageChan:
+				received = append(received, brokerMessage)
+			case <-ctx.Done():
+				return
+			}
+		}
+		replay()
+		for _, brokerMessage := range received {
+			send(brokerMessage)
+		}
+		if erased {
+			return
+		}
+
 		// wait for messages to come from the broker
 		for {
 			select {
@@ -114,7 +413,7 @@ func Stream(broker broker.MessageBroker, logger zerolog.Logger) http.HandlerFunc
 				// streamer or broker should close this channel
 				// depending on which one of them had the error
 				<-brokerMessageChan
-				logger.Info().Msgf("cannot connect to broker: ", err)
+				logger.Info().Msgf("cannot connect to broker: %v", err)
 				return
 			// this listens for messages from broker
 			// if messageChan closes unexpectedly for any reason
@@ -127,16 +426,23 @@ func Stream(broker broker.MessageBroker, logger zerolog.Logger) http.HandlerFunc
 					return
 				}
 				// send the messages to client
-				logger.Info().Msgf("SSE: %s", string(brokerMessage))
-
-				_ = sse.Encode(w, sse.Event{
-					Event: "message",
-					Data:  string(brokerMessage),
-				})
-
-				f.Flush()
+				send(brokerMessage)
+				if erased {
+					logger.Info().Msgf("stream of erased user closed: %s", email)
+					return
+				}
+			// messages from the rooms topics
+			case brokerMessage := <-roomMessageChan:
+				send(brokerMessage)
+			// a room joined since the stream started is followed, the events
+			// published to it before its subscription was live are replayed
+			case <-roomReadyCh:
+				replay()
+				if erased {
+					return
+				}
 			}
 		}
 
 	}
-}
\ No newline at end of file
+}
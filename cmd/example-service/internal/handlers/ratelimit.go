@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// sent along with rate limited calls
+	retryAfterHeader = "Retry-After"
+
+	// rate limited error
+	rateLimitedErr = "rate limited"
+
+	// anonymous write error
+	anonymousWriteErr = "write calls need an authenticated caller"
+)
+
+// readMethods are the cheap RPC methods that change nothing, they are not rate limited.
+// Methods missing here are limited so new write methods are covered by default,
+// SearchMessages is left out as every search scores messages
+var readMethods = map[string]bool{
+	"Ping":                    true,
+	"Version":                 true,
+	"ListConversation":        true,
+	"ListConversations":       true,
+	"GetMessageHistory":       true,
+	"ListRooms":               true,
+	"ListRoomMessages":        true,
+	"GetPresence":             true,
+	"ListThread":              true,
+	"GetAttachments":          true,
+	"GetUsage":                true,
+	"ListScheduledMessages":   true,
+	"GetConversationSettings": true,
+	"ListBlocked":             true,
+	"ListMentions":            true,
+	"GetExportStatus":         true,
+}
+
+// rateLimiter limits the calls of a caller
+type rateLimiter interface {
+	Allow(key, role string) (bool, time.Duration)
+}
+
+// rateLimit rejects the write calls of an authenticated caller that go past
+// the rate of their role with a rate limited error, the Retry-After header
+// and the error cause tell when to try again. Callers are keyed on the email and
+// role of their verified token, anonymous write calls are rejected before
+// they reach any method since they can't be limited per caller
+func rateLimit(limiter rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !isWrite(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			claims, ok := auth.FromContext(r.Context())
+			if !ok {
+				proto.RespondWithError(w, proto.Errorf(proto.ErrUnauthenticated, anonymousWriteErr))
+				return
+			}
+
+			allowed, wait := limiter.Allow(claims.Email, claims.Role)
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seconds := int64(math.Ceil(wait.Seconds()))
+			w.Header().Set(retryAfterHeader, strconv.FormatInt(seconds, 10))
+			proto.RespondWithError(w, proto.WrapError(proto.ErrRateLimited, fmt.Errorf("retry after %d seconds", seconds), rateLimitedErr))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// isWrite reports whether a request changes anything, every
+// method but GET and OPTIONS does except the read RPC methods
+func isWrite(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodOptions {
+		return false
+	}
+	if !strings.HasPrefix(r.URL.Path, proto.ChatPathPrefix) {
+		return true
+	}
+	return !readMethods[strings.TrimPrefix(r.URL.Path, proto.ChatPathPrefix)]
+}
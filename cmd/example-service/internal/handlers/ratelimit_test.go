@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rumsrami/example-service/internal/platform/ratelimit"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// limitedServer serves the calls behind the authentication and rate limiting they have in the app,
+// the callers get one call and none after it
+func limitedServer(t *testing.T) (*httptest.Server, *testIssuer) {
+	iss := newTestIssuer(t)
+	limiter := ratelimit.NewLimiter(nil, ratelimit.Rate{PerSecond: 0.5, Burst: 1})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return httptest.NewServer(authenticate(iss.verifier())(rateLimit(limiter)(ok))), iss
+}
+
+func call(t *testing.T, iss *testIssuer, srv *httptest.Server, method, path, email string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "" {
+		iss.authorize(t, req, email, "")
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestRateLimitWriteCallsPerCaller(t *testing.T) {
+	srv, iss := limitedServer(t)
+	defer srv.Close()
+	send := proto.ChatPathPrefix + "CreateChatMessage"
+
+	if res := call(t, iss, srv, http.MethodPost, send, "a@x.com"); res.StatusCode != http.StatusOK {
+		t.Fatalf("first call = %d", res.StatusCode)
+	}
+	res := call(t, iss, srv, http.MethodPost, send, "a@x.com")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("call past the rate = %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if res.Header.Get(retryAfterHeader) != "2" {
+		t.Fatalf("Retry-After = %q, want 2", res.Header.Get(retryAfterHeader))
+	}
+
+	// other callers have their own bucket
+	if res := call(t, iss, srv, http.MethodPost, send, "b@x.com"); res.StatusCode != http.StatusOK {
+		t.Fatalf("call of another caller = %d", res.StatusCode)
+	}
+}
+
+func TestRateLimitRejectsAnonymousWriteCalls(t *testing.T) {
+	srv, iss := limitedServer(t)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		res := call(t, iss, srv, http.MethodPost, proto.ChatPathPrefix+"CreateChatMessage", "")
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("anonymous call = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	res := call(t, iss, srv, http.MethodPost, "/attachments", "")
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous upload = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestRateLimitLeavesReadCallsAlone(t *testing.T) {
+	srv, iss := limitedServer(t)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		if res := call(t, iss, srv, http.MethodPost, proto.ChatPathPrefix+"ListConversations", "a@x.com"); res.StatusCode != http.StatusOK {
+			t.Fatalf("read call %d = %d", i, res.StatusCode)
+		}
+		if res := call(t, iss, srv, http.MethodGet, "/attachments/id", ""); res.StatusCode != http.StatusOK {
+			t.Fatalf("download %d = %d", i, res.StatusCode)
+		}
+	}
+}
+
+func TestRateLimitSearches(t *testing.T) {
+	srv, iss := limitedServer(t)
+	defer srv.Close()
+	search := proto.ChatPathPrefix + "SearchMessages"
+
+	call(t, iss, srv, http.MethodPost, search, "a@x.com")
+	if res := call(t, iss, srv, http.MethodPost, search, "a@x.com"); res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("search past the rate = %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func TestIsWrite(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		write        bool
+	}{
+		{http.MethodPost, proto.ChatPathPrefix + "CreateChatMessage", true},
+		{http.MethodPost, proto.ChatPathPrefix + "SomeNewMethod", true},
+		{http.MethodPost, proto.ChatPathPrefix + "Ping", false},
+		{http.MethodPost, "/attachments", true},
+		{http.MethodGet, "/exports/id", false},
+		{http.MethodOptions, proto.ChatPathPrefix + "CreateChatMessage", false},
+	} {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if got := isWrite(r); got != tc.write {
+			t.Errorf("isWrite(%s %s) = %v, want %v", tc.method, tc.path, got, tc.write)
+		}
+	}
+}
+
+func TestRateLimitKeysOnTheVerifiedCaller(t *testing.T) {
+	srv, iss := limitedServer(t)
+	defer srv.Close()
+	send := proto.ChatPathPrefix + "CreateChatMessage"
+
+	call(t, iss, srv, http.MethodPost, send, "a@x.com")
+
+	// identity headers neither give a fresh bucket nor another tier
+	req, err := http.NewRequest(http.MethodPost, srv.URL+send, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.authorize(t, req, "a@x.com", "")
+	req.Header.Set("X-User-Email", "fresh@x.com")
+	req.Header.Set("X-User-Role", "admin")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("call past the rate = %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	var payload proto.ErrorPayload
+	err = json.NewDecoder(res.Body).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Code != string(proto.ErrRateLimited) || payload.Cause != "retry after 2 seconds" {
+		t.Fatalf("error = %+v, want rate limited with the seconds to wait", payload)
+	}
+}
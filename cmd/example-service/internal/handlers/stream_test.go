@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// memBroker is an in memory broker delivering to the subscriptions live when a message is published
+type memBroker struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+func newMemBroker() *memBroker {
+	return &memBroker{subs: make(map[string][]chan []byte)}
+}
+
+func (b *memBroker) Pub(topic string, message interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs[topic] {
+		ch <- message.([]byte)
+	}
+	return nil
+}
+
+func (b *memBroker) Sub(ctx context.Context, topic string, receive chan []byte, errCh chan error) {
+	b.mu.Lock()
+	b.subs[topic] = append(b.subs[topic], receive)
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	subs := b.subs[topic]
+	for i, ch := range subs {
+		if ch == receive {
+			b.subs[topic] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+	close(receive)
+}
+
+// fakeChat records the callers of the chat service the stream relies on
+type fakeChat struct {
+	mu        sync.Mutex
+	rooms     []*proto.Room
+	pending   []event.Event
+	delivered map[string][]string
+	acked     map[string][]string
+}
+
+func newFakeChat(pending ...event.Event) *fakeChat {
+	return &fakeChat{
+		pending:   pending,
+		delivered: make(map[string][]string),
+		acked:     make(map[string][]string),
+	}
+}
+
+// caller returns the email the stream called the chat service as
+func (c *fakeChat) caller(ctx context.Context) string {
+	claims, _ := auth.FromContext(ctx)
+	return claims.Email
+}
+
+func (c *fakeChat) MarkDelivered(ctx context.Context, messageUUIDs []string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	email := c.caller(ctx)
+	c.delivered[email] = append(c.delivered[email], messageUUIDs...)
+	return true, nil
+}
+
+func (c *fakeChat) ListRooms(ctx context.Context) ([]*proto.Room, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rooms, nil
+}
+
+func (c *fakeChat) PendingEvents(ctx context.Context) ([]event.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]event.Event(nil), c.pending...), nil
+}
+
+// queue adds ev to the pending events
+func (c *fakeChat) queue(ev event.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, ev)
+}
+
+func (c *fakeChat) AckEvent(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	email := c.caller(ctx)
+	c.acked[email] = append(c.acked[email], id)
+	for i, ev := range c.pending {
+		if ev.ID == id {
+			c.pending = append(c.pending[:i:i], c.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// ackedBy returns the ids of the events email acked, in order
+func (c *fakeChat) ackedBy(email string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.acked[email], ",")
+}
+
+// fakePresence reports the users connecting and disconnecting
+type fakePresence struct {
+	connected    chan string
+	disconnected chan string
+}
+
+func newFakePresence() *fakePresence {
+	return &fakePresence{
+		connected:    make(chan string, 10),
+		disconnected: make(chan string, 10),
+	}
+}
+
+func (p *fakePresence) Connect(email string) {
+	p.connected <- email
+}
+
+func (p *fakePresence) Disconnect(email string) {
+	p.disconnected <- email
+}
+
+// streamServer serves the stream behind the authentication it has in the app
+func streamServer(iss *testIssuer, mb *memBroker, chat chatService, presence presenceTracker) *httptest.Server {
+	return httptest.NewServer(authenticateStream(iss.verifier())(Stream(mb, chat, presence, zerolog.Nop())))
+}
+
+// openStream connects to the stream as email in the background, the SSE lines
+// received are sent to lines until ctx is done
+func openStream(t *testing.T, ctx context.Context, iss *testIssuer, url, email string, lines chan<- string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	iss.authorize(t, req, email, "")
+
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			if ctx.Err() == nil {
+				t.Error(err)
+			}
+			return
+		}
+		defer res.Body.Close()
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" {
+				lines <- line
+			}
+		}
+	}()
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing received")
+		return ""
+	}
+}
+
+func TestStreamRejectsAnonymousCallers(t *testing.T) {
+	presence := newFakePresence()
+	iss := newTestIssuer(t)
+	srv := streamServer(iss, newMemBroker(), newFakeChat(), presence)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "?email=b@x.com&role=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+	select {
+	case email := <-presence.connected:
+		t.Fatalf("%s connected without credentials", email)
+	default:
+	}
+}
+
+func TestStreamPresenceFollowsTheCaller(t *testing.T) {
+	presence := newFakePresence()
+	iss := newTestIssuer(t)
+	srv := streamServer(iss, newMemBroker(), newFakeChat(), presence)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// the query can't make the stream someone else's
+	openStream(t, ctx, iss, srv.URL+"?email=victim@x.com", "b@x.com", make(chan string, 10))
+
+	if email := receive(t, presence.connected); email != "b@x.com" {
+		t.Fatalf("connected %s, want b@x.com", email)
+	}
+	cancel()
+	if email := receive(t, presence.disconnected); email != "b@x.com" {
+		t.Fatalf("disconnected %s, want b@x.com", email)
+	}
+}
+
+// queued returns an event as the pending queues have it under id, empty for events never queued
+func queued(t *testing.T, eventType string, data interface{}, id string) event.Event {
+	t.Helper()
+	ev, err := event.New(eventType, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.ID = id
+	return ev
+}
+
+// publish publishes an event to the chat topic of email
+func publish(t *testing.T, mb *memBroker, email string, ev event.Event) {
+	t.Helper()
+	publishTopic(t, mb, chatTopicPrefix+email, ev)
+}
+
+// publishTopic publishes an event to topic
+func publishTopic(t *testing.T, mb *memBroker, topic string, ev event.Event) {
+	t.Helper()
+	b, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mb.Pub(topic, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// subscribed waits until topic has n subscriptions
+func subscribed(t *testing.T, mb *memBroker, topic string, n int) {
+	t.Helper()
+	waitFor(t, "subscription to "+topic, func() bool {
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+		return len(mb.subs[topic]) == n
+	})
+}
+
+// receiveEvent returns the type and data of the next SSE event of a stream
+func receiveEvent(t *testing.T, lines <-chan string) (string, string) {
+	t.Helper()
+	eventType := strings.TrimPrefix(receive(t, lines), "event:")
+	return eventType, strings.TrimPrefix(receive(t, lines), "data:")
+}
+
+// waitFor waits until cond holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamReplaysPendingEventsBeforeLiveOnes(t *testing.T) {
+	mb := newMemBroker()
+	chat := newFakeChat(
+		queued(t, event.Message, proto.ChatMessage{MessageUUID: "m1", FromEmail: "a@x.com", ToEmail: "b@x.com"}, "e1"),
+		queued(t, event.Edited, proto.ChatMessage{MessageUUID: "m1", FromEmail: "a@x.com", ToEmail: "b@x.com"}, "e2"),
+	)
+	iss := newTestIssuer(t)
+	srv := streamServer(iss, mb, chat, newFakePresence())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 100)
+	openStream(t, ctx, iss, srv.URL, "b@x.com", lines)
+
+	for _, want := range []string{event.Message, event.Edited} {
+		if eventType, _ := receiveEvent(t, lines); eventType != want {
+			t.Fatalf("replayed %s, want %s", eventType, want)
+		}
+	}
+
+	// the replay covered the live copy of e2
+	publish(t, mb, "b@x.com", queued(t, event.Edited, proto.ChatMessage{MessageUUID: "m1"}, "e2"))
+	publish(t, mb, "b@x.com", queued(t, event.Typing, proto.Typing{FromEmail: "a@x.com"}, ""))
+	publish(t, mb, "b@x.com", queued(t, event.Deleted, proto.ChatMessage{MessageUUID: "m1"}, "e3"))
+	for _, want := range []string{event.Typing, event.Deleted} {
+		if eventType, _ := receiveEvent(t, lines); eventType != want {
+			t.Fatalf("received %s, want %s", eventType, want)
+		}
+	}
+
+	waitFor(t, "ack of e3", func() bool {
+		return chat.ackedBy("b@x.com") == "e1,e2,e3"
+	})
+}
+
+func TestStreamMarksDeliveredAsTheRecipient(t *testing.T) {
+	mb := newMemBroker()
+	chat := newFakeChat(
+		queued(t, event.Message, proto.ChatMessage{MessageUUID: "to-b", FromEmail: "a@x.com", ToEmail: "b@x.com"}, "e1"),
+		queued(t, event.Message, proto.ChatMessage{MessageUUID: "from-b", FromEmail: "b@x.com", ToEmail: "a@x.com"}, "e2"),
+		queued(t, event.Message, proto.ChatMessage{MessageUUID: "seen", FromEmail: "a@x.com", ToEmail: "b@x.com", Delivered: true}, "e3"),
+	)
+	iss := newTestIssuer(t)
+	srv := streamServer(iss, mb, chat, newFakePresence())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 100)
+	openStream(t, ctx, iss, srv.URL, "b@x.com", lines)
+	for i := 0; i < 3; i++ {
+		receiveEvent(t, lines)
+	}
+	publish(t, mb, "b@x.com", queued(t, event.Message, proto.ChatMessage{MessageUUID: "live", FromEmail: "a@x.com", ToEmail: "b@x.com"}, "e4"))
+	receiveEvent(t, lines)
+
+	waitFor(t, "ack of e4", func() bool {
+		return chat.ackedBy("b@x.com") == "e1,e2,e3,e4"
+	})
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+	if len(chat.delivered) != 1 {
+		t.Fatalf("marked delivered as %v, want only b@x.com", chat.delivered)
+	}
+	if got := strings.Join(chat.delivered["b@x.com"], ","); got != "to-b,live" {
+		t.Fatalf("delivered %s, want to-b,live", got)
+	}
+}
+
+func TestStreamFollowsTheRoomsOfTheCaller(t *testing.T) {
+	mb := newMemBroker()
+	chat := newFakeChat(
+		queued(t, event.RoomUpdated, proto.Room{RoomID: "r1"}, "e1"),
+	)
+	chat.rooms = []*proto.Room{{RoomID: "r1"}}
+	iss := newTestIssuer(t)
+	srv := streamServer(iss, mb, chat, newFakePresence())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 100)
+	openStream(t, ctx, iss, srv.URL, "b@x.com", lines)
+
+	if eventType, _ := receiveEvent(t, lines); eventType != event.RoomUpdated {
+		t.Fatalf("replayed %s, want %s", eventType, event.RoomUpdated)
+	}
+	// the replay covered the live copy of e1
+	publishTopic(t, mb, roomTopicPrefix+"r1", queued(t, event.RoomUpdated, proto.Room{RoomID: "r1"}, "e1"))
+	publishTopic(t, mb, roomTopicPrefix+"r1", queued(t, event.Message, proto.ChatMessage{MessageUUID: "m1", RoomID: "r1"}, "e2"))
+	if eventType, data := receiveEvent(t, lines); eventType != event.Message || !strings.Contains(data, "m1") {
+		t.Fatalf("received %s %s, want message m1", eventType, data)
+	}
+
+	// the stream follows the rooms the caller joins, and replays what was
+	// published to them before their subscription was live
+	joined := queued(t, event.RoomJoined, proto.Room{RoomID: "r2"}, "e3")
+	chat.queue(joined)
+	chat.queue(queued(t, event.Message, proto.ChatMessage{MessageUUID: "m2", RoomID: "r2"}, "e4"))
+	publish(t, mb, "b@x.com", joined)
+	if eventType, _ := receiveEvent(t, lines); eventType != event.RoomJoined {
+		t.Fatalf("received %s, want %s", eventType, event.RoomJoined)
+	}
+	if eventType, data := receiveEvent(t, lines); eventType != event.Message || !strings.Contains(data, "m2") {
+		t.Fatalf("replayed %s %s, want message m2", eventType, data)
+	}
+	subscribed(t, mb, roomTopicPrefix+"r2", 1)
+	publishTopic(t, mb, roomTopicPrefix+"r2", queued(t, event.Message, proto.ChatMessage{MessageUUID: "m2", RoomID: "r2"}, "e4"))
+	publishTopic(t, mb, roomTopicPrefix+"r2", queued(t, event.Message, proto.ChatMessage{MessageUUID: "m3", RoomID: "r2"}, "e5"))
+	if eventType, data := receiveEvent(t, lines); eventType != event.Message || !strings.Contains(data, "m3") {
+		t.Fatalf("received %s %s, want message m3", eventType, data)
+	}
+	waitFor(t, "ack of e5", func() bool {
+		return chat.ackedBy("b@x.com") == "e1,e2,e3,e4,e5"
+	})
+
+	// and stops following the rooms the caller leaves
+	publish(t, mb, "b@x.com", queued(t, event.RoomLeft, proto.Room{RoomID: "r1"}, "e6"))
+	receiveEvent(t, lines)
+	subscribed(t, mb, roomTopicPrefix+"r1", 0)
+}
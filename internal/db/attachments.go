@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when no attachment has the requested ID,
+	// or when it was neither uploaded by nor sent to the caller
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrQuotaExceeded is returned when an upload doesn't fit in the storage quota of its owner
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// storedAttachment is an attachment along with the blob holding its content
+type storedAttachment struct {
+	attachment proto.Attachment
+	// key of the content in the blob store
+	blobKey string
+	// key of the thumbnail in the blob store, set on processed images
+	thumbnailKey string
+	// MessageUUIDs of the messages the attachment was sent with
+	messages map[string]bool
+}
+
+// storageUsage is the storage used by the attachments of a user
+type storageUsage struct {
+	used int64
+	// reserved for uploads in progress
+	reserved    int64
+	attachments int
+}
+
+// usage returns the storage usage of owner, creating it if needed.
+// Must be called from within an action
+func (d *Database) usage(owner string) *storageUsage {
+	u, ok := d.storage[owner]
+	if !ok {
+		u = &storageUsage{}
+		d.storage[owner] = u
+	}
+	return u
+}
+
+// ReserveStorage sets size bytes aside for an upload of owner as long as
+// they fit in quota along with the storage already used or reserved
+func (d *Database) ReserveStorage(ctx context.Context, owner string, size, quota int64) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		u := d.usage(owner)
+		if u.used+u.reserved+size > quota {
+			e <- errors.Wrap(ErrQuotaExceeded, owner)
+			return
+		}
+		u.reserved += size
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReleaseStorage gives back storage reserved for an upload that failed
+func (d *Database) ReleaseStorage(ctx context.Context, owner string, reserved int64) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		d.usage(owner).reserved -= reserved
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CreateAttachment stores an uploaded attachment whose content is kept under blobKey since storedAt,
+// the storage reserved for the upload is replaced by the attachment size
+func (d *Database) CreateAttachment(ctx context.Context, attachment proto.Attachment, blobKey string, reserved int64, storedAt time.Time) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		err := d.checkBlob(blobKey, storedAt)
+		if err != nil {
+			e <- err
+			return
+		}
+		d.attachments[attachment.AttachmentID] = &storedAttachment{
+			attachment: attachment,
+			blobKey:    blobKey,
+			messages:   make(map[string]bool),
+		}
+		u := d.usage(attachment.OwnerEmail)
+		u.reserved -= reserved
+		u.used += attachment.Size
+		u.attachments++
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetAttachment returns an attachment along with the key of its content in the blob store,
+// only its owner and the participants of a conversation it was sent to can get it
+func (d *Database) GetAttachment(ctx context.Context, viewer, attachmentID string) (proto.Attachment, string, error) {
+	type result struct {
+		attachment proto.Attachment
+		blobKey    string
+		err        error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.attachments[attachmentID]
+		if !ok || !d.canView(stored, viewer) {
+			r <- result{err: errors.Wrap(ErrAttachmentNotFound, attachmentID)}
+			return
+		}
+		r <- result{attachment: stored.attachment, blobKey: stored.blobKey}
+	}
+	select {
+	case res := <-r:
+		return res.attachment, res.blobKey, res.err
+	case <-ctx.Done():
+		return proto.Attachment{}, "", ctx.Err()
+	}
+}
+
+// SetAttachmentImage records the dimensions of an image attachment
+// along with its thumbnail, kept under thumbnailKey since storedAt, once they are known
+func (d *Database) SetAttachmentImage(ctx context.Context, attachmentID string, width, height int, thumbnail proto.Thumbnail, thumbnailKey string, storedAt time.Time) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		stored, ok := d.attachments[attachmentID]
+		if !ok {
+			e <- errors.Wrap(ErrAttachmentNotFound, attachmentID)
+			return
+		}
+		err := d.checkBlob(thumbnailKey, storedAt)
+		if err != nil {
+			e <- err
+			return
+		}
+		stored.attachment.Width = width
+		stored.attachment.Height = height
+		stored.attachment.Thumbnail = &thumbnail
+		stored.thumbnailKey = thumbnailKey
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetThumbnail returns the thumbnail of an image attachment along with its key in
+// the blob store, to the viewers of the attachment
+func (d *Database) GetThumbnail(ctx context.Context, viewer, attachmentID string) (proto.Thumbnail, string, error) {
+	type result struct {
+		thumbnail    proto.Thumbnail
+		thumbnailKey string
+		err          error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.attachments[attachmentID]
+		if !ok || !d.canView(stored, viewer) || stored.attachment.Thumbnail == nil {
+			r <- result{err: errors.Wrap(ErrAttachmentNotFound, attachmentID)}
+			return
+		}
+		r <- result{thumbnail: *stored.attachment.Thumbnail, thumbnailKey: stored.thumbnailKey}
+	}
+	select {
+	case res := <-r:
+		return res.thumbnail, res.thumbnailKey, res.err
+	case <-ctx.Done():
+		return proto.Thumbnail{}, "", ctx.Err()
+	}
+}
+
+// GetAttachments returns the attachments the viewer can get among attachmentIDs
+func (d *Database) GetAttachments(ctx context.Context, viewer string, attachmentIDs []string) ([]proto.Attachment, error) {
+	r := make(chan []proto.Attachment, 1)
+	d.actionCh <- func() {
+		res := make([]proto.Attachment, 0, len(attachmentIDs))
+		for _, id := range attachmentIDs {
+			stored, ok := d.attachments[id]
+			if ok && d.canView(stored, viewer) {
+				res = append(res, stored.attachment)
+			}
+		}
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetUsage returns the storage used by the attachments of owner
+func (d *Database) GetUsage(ctx context.Context, owner string) (int64, int, error) {
+	type result struct {
+		used        int64
+		attachments int
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		u := d.usage(owner)
+		r <- result{used: u.used, attachments: u.attachments}
+	}
+	select {
+	case res := <-r:
+		return res.used, res.attachments, nil
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+}
+
+// canView reports whether viewer uploaded the attachment or takes part in a
+// conversation it was sent to. Must be called from within an action
+func (d *Database) canView(stored *storedAttachment, viewer string) bool {
+	if stored.attachment.OwnerEmail == viewer {
+		return true
+	}
+	for messageUUID := range stored.messages {
+		if msg, ok := d.messages[messageUUID]; ok && d.isParticipant(msg.msg, viewer) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAttachments makes sure the attachments of msg were uploaded by the authenticated
+// sender. Must be called from within an action
+func (d *Database) checkAttachments(sender string, msg proto.ChatMessage) error {
+	for _, id := range msg.AttachmentIDs {
+		stored, ok := d.attachments[id]
+		if !ok || stored.attachment.OwnerEmail != sender {
+			return errors.Wrap(ErrAttachmentNotFound, id)
+		}
+	}
+	return nil
+}
+
+// linkAttachments records that the attachments of msg were sent with it,
+// or that they no longer are. Must be called from within an action
+func (d *Database) linkAttachments(msg proto.ChatMessage, linked bool) {
+	for _, id := range msg.AttachmentIDs {
+		stored, ok := d.attachments[id]
+		if !ok {
+			continue
+		}
+		if linked {
+			stored.messages[msg.MessageUUID] = true
+		} else {
+			delete(stored.messages, msg.MessageUUID)
+		}
+	}
+}
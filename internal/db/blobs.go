@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrBlobDeleted is returned when content is stored under a blob key that was
+	// deleted from the blob store since, it has to be stored again
+	ErrBlobDeleted = errors.New("blob deleted while it was stored")
+)
+
+// ClaimBlob reports whether nothing refers to blobKey anymore, the blob is then
+// claimed for deletion until DeletedBlob is called. Blobs are addressed by content
+// so an upload of the same content may refer to a released blob again at any time,
+// whatever refers to a claimed blob is turned down with ErrBlobDeleted meanwhile
+func (d *Database) ClaimBlob(ctx context.Context, blobKey string) (bool, error) {
+	r := make(chan bool, 1)
+	d.actionCh <- func() {
+		if d.blobReferenced(blobKey) {
+			r <- false
+			return
+		}
+		d.deletingBlobs[blobKey] = true
+		r <- true
+	}
+	select {
+	case claimed := <-r:
+		return claimed, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// DeletedBlob records that the deletion of a claimed blob is over, whatever stored
+// the same content before that has to store it again
+func (d *Database) DeletedBlob(ctx context.Context, blobKey string, at time.Time) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		delete(d.deletingBlobs, blobKey)
+		d.deletedBlobs[blobKey] = at
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// blobReferenced reports whether an attachment, a thumbnail or an export refers to blobKey.
+// Must be called from within an action
+func (d *Database) blobReferenced(blobKey string) bool {
+	for _, stored := range d.attachments {
+		if stored.blobKey == blobKey || stored.thumbnailKey == blobKey {
+			return true
+		}
+	}
+	for _, stored := range d.exports {
+		if stored.blobKey == blobKey {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBlob makes sure the content stored under blobKey from storedAt on
+// was not deleted in the meantime. Must be called from within an action
+func (d *Database) checkBlob(blobKey string, storedAt time.Time) error {
+	deletedAt, ok := d.deletedBlobs[blobKey]
+	if d.deletingBlobs[blobKey] || (ok && !deletedAt.Before(storedAt)) {
+		return errors.Wrap(ErrBlobDeleted, blobKey)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+func TestClaimBlobOnlyClaimsUnreferencedBlobs(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	storedAt := time.Now().UTC()
+
+	err := d.CreateAttachment(ctx, proto.Attachment{AttachmentID: "a-1", OwnerEmail: "a@x.com"}, "referenced", 0, storedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := d.ClaimBlob(ctx, "referenced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed {
+		t.Fatal("claimed a blob an attachment refers to")
+	}
+
+	claimed, err = d.ClaimBlob(ctx, "released")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claimed {
+		t.Fatal("the released blob was not claimed")
+	}
+	// the deletion is in progress
+	err = d.CreateAttachment(ctx, proto.Attachment{AttachmentID: "b-1", OwnerEmail: "b@x.com"}, "released", 0, time.Now().UTC())
+	if errors.Cause(err) != ErrBlobDeleted {
+		t.Fatalf("err = %v, want %v", err, ErrBlobDeleted)
+	}
+
+	deletedAt := time.Now().UTC()
+	err = d.DeletedBlob(ctx, "released", deletedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.CreateAttachment(ctx, proto.Attachment{AttachmentID: "b-1", OwnerEmail: "b@x.com"}, "released", 0, deletedAt)
+	if errors.Cause(err) != ErrBlobDeleted {
+		t.Fatalf("stored when deleted: err = %v, want %v", err, ErrBlobDeleted)
+	}
+	err = d.CreateAttachment(ctx, proto.Attachment{AttachmentID: "b-1", OwnerEmail: "b@x.com"}, "released", 0, deletedAt.Add(time.Millisecond))
+	if err != nil {
+		t.Fatalf("stored after the deletion: %v", err)
+	}
+}
+
+func TestEraseUserOutlivesItsContext(t *testing.T) {
+	d := newTestDatabase(t)
+	err := d.CreateAttachment(context.Background(), proto.Attachment{AttachmentID: "a-1", OwnerEmail: "a@x.com"}, "blob", 0, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.EraseUser(cancelled, "a@x.com", time.Now().UTC())
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+
+	// the erasure is queued behind an action still running when ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	started, busy := make(chan struct{}), make(chan struct{})
+	d.actionCh <- func() {
+		close(started)
+		<-busy
+	}
+	<-started
+	done := make(chan Erasure, 1)
+	go func() {
+		erased, err := d.EraseUser(ctx, "a@x.com", time.Now().UTC())
+		if err != nil {
+			t.Error(err)
+		}
+		done <- erased
+	}()
+	for len(d.actionCh) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(busy)
+
+	select {
+	case erased := <-done:
+		if erased.Attachments != 1 || len(erased.BlobKeys) != 1 || erased.BlobKeys[0] != "blob" {
+			t.Fatalf("erased %+v, want the attachment and its blob", erased)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the erasure never returned")
+	}
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrBlocked is returned when sending a message to a user who blocked the sender
+	ErrBlocked = errors.New("blocked by the recipient")
+)
+
+// BlockUser stops email from sending messages to blocker, blocking
+// a user twice keeps the time they were first blocked
+func (d *Database) BlockUser(ctx context.Context, blocker, email string, at time.Time) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		blocked, ok := d.blocks[blocker]
+		if !ok {
+			blocked = make(map[string]time.Time)
+			d.blocks[blocker] = blocked
+		}
+		if _, ok := blocked[email]; !ok {
+			blocked[email] = at
+		}
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// UnblockUser lets email send messages to blocker again
+func (d *Database) UnblockUser(ctx context.Context, blocker, email string) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		delete(d.blocks[blocker], email)
+		if len(d.blocks[blocker]) == 0 {
+			delete(d.blocks, blocker)
+		}
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ListBlocked returns the users blocker blocked ordered by email
+func (d *Database) ListBlocked(ctx context.Context, blocker string) ([]proto.BlockedUser, error) {
+	r := make(chan []proto.BlockedUser, 1)
+	d.actionCh <- func() {
+		res := make([]proto.BlockedUser, 0, len(d.blocks[blocker]))
+		for email, at := range d.blocks[blocker] {
+			res = append(res, proto.BlockedUser{Email: email, BlockedAt: at})
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Email < res[j].Email
+		})
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Blocked reports whether either user blocked the other
+func (d *Database) Blocked(ctx context.Context, a, b string) (bool, error) {
+	r := make(chan bool, 1)
+	d.actionCh <- func() {
+		r <- d.isBlocked(a, b) || d.isBlocked(b, a)
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Blockers returns which of emails blocked email
+func (d *Database) Blockers(ctx context.Context, email string, emails []string) (map[string]bool, error) {
+	r := make(chan map[string]bool, 1)
+	d.actionCh <- func() {
+		res := make(map[string]bool)
+		for _, blocker := range emails {
+			if d.isBlocked(blocker, email) {
+				res[blocker] = true
+			}
+		}
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// isBlocked reports whether blocker blocked email. Must be called from within an action
+func (d *Database) isBlocked(blocker, email string) bool {
+	_, ok := d.blocks[blocker][email]
+	return ok
+}
+
+// MuteConversation sets whether email is notified of the messages of their conversation
+// with withEmail, or of a room they are a member of when roomID is set
+func (d *Database) MuteConversation(ctx context.Context, email, withEmail, roomID string, muted bool) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		key, err := d.conversationKey(email, withEmail, roomID)
+		if err != nil {
+			e <- err
+			return
+		}
+		if !muted {
+			delete(d.muted[key], email)
+			if len(d.muted[key]) == 0 {
+				delete(d.muted, key)
+			}
+			e <- nil
+			return
+		}
+		if d.muted[key] == nil {
+			d.muted[key] = make(map[string]bool)
+		}
+		d.muted[key][email] = true
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MutedBy returns the users that muted the conversation of msg ordered by email
+func (d *Database) MutedBy(ctx context.Context, msg proto.ChatMessage) ([]string, error) {
+	r := make(chan []string, 1)
+	d.actionCh <- func() {
+		muted := d.muted[messageKey(msg)]
+		res := make([]string, 0, len(muted))
+		for email := range muted {
+			res = append(res, email)
+		}
+		sort.Strings(res)
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
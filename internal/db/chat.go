@@ -0,0 +1,233 @@
+package db
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrMessageExists is returned when a chat message with the same
+	// MessageUUID is already stored
+	ErrMessageExists = errors.New("chat message already exists")
+)
+
+// storedMessage is a chat message along with its position in the store,
+// seq only grows so it orders messages by arrival
+type storedMessage struct {
+	seq uint64
+	msg proto.ChatMessage
+	// previous revisions of msg, oldest first
+	history []proto.ChatMessage
+	// time the message was first stored
+	createdAt time.Time
+	// users that hid the message for themselves
+	hiddenFor map[string]bool
+}
+
+// ConversationKey returns the key of the conversation between two users
+// regardless of who sent the message
+func ConversationKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return strings.Join([]string{a, b}, "#")
+}
+
+// RoomKey returns the key of the conversation of a room
+func RoomKey(roomID string) string {
+	return strings.Join([]string{"ROOM", roomID}, "#")
+}
+
+// messageKey returns the key of the conversation msg belongs to
+func messageKey(msg proto.ChatMessage) string {
+	if msg.RoomID != "" {
+		return RoomKey(msg.RoomID)
+	}
+	return ConversationKey(msg.FromEmail, msg.ToEmail)
+}
+
+// isParticipant reports whether email takes part in the conversation
+// of msg. Must be called from within an action
+func (d *Database) isParticipant(msg proto.ChatMessage, email string) bool {
+	if msg.RoomID != "" {
+		room, ok := d.rooms[msg.RoomID]
+		return ok && room.members[email]
+	}
+	return msg.FromEmail == email || msg.ToEmail == email
+}
+
+// CreateChatMessage stores a new chat message sent by the authenticated sender keyed
+// by its MessageUUID and returns it along with the fields set by the store
+func (d *Database) CreateChatMessage(ctx context.Context, sender string, msg proto.ChatMessage) (proto.ChatMessage, error) {
+	type result struct {
+		msg proto.ChatMessage
+		err error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		msg, err := d.createMessage(sender, msg)
+		r <- result{msg: msg, err: err}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, ctx.Err()
+	}
+}
+
+// createMessage stores msg sent by the authenticated sender at the end of its conversation,
+// an expiry time is set when the conversation has a message lifetime and the mentions of
+// its text are parsed.
+// Must be called from within an action
+func (d *Database) createMessage(sender string, msg proto.ChatMessage) (proto.ChatMessage, error) {
+	if msg.FromEmail != sender {
+		return proto.ChatMessage{}, errors.Wrap(ErrNotSender, sender)
+	}
+	if _, ok := d.messages[msg.MessageUUID]; ok {
+		return proto.ChatMessage{}, ErrMessageExists
+	}
+	if msg.RoomID != "" {
+		if _, ok := d.rooms[msg.RoomID]; !ok {
+			return proto.ChatMessage{}, errors.Wrap(ErrRoomNotFound, msg.RoomID)
+		}
+		if !d.isParticipant(msg, sender) {
+			return proto.ChatMessage{}, errors.Wrap(ErrNotMember, msg.RoomID)
+		}
+	}
+	// blocks apply to the authenticated sender
+	if msg.RoomID == "" && d.isBlocked(msg.ToEmail, sender) {
+		return proto.ChatMessage{}, errors.Wrap(ErrBlocked, msg.ToEmail)
+	}
+	if msg.ParentMessageUUID != "" {
+		err := d.checkParent(msg)
+		if err != nil {
+			return proto.ChatMessage{}, err
+		}
+	}
+	err := d.checkAttachments(sender, msg)
+	if err != nil {
+		return proto.ChatMessage{}, err
+	}
+	d.seq++
+	stored := &storedMessage{seq: d.seq, createdAt: time.Now().UTC()}
+	if msg.UpdatedAt != nil {
+		stored.createdAt = *msg.UpdatedAt
+	}
+	msg.ExpiresAt = nil
+	if ttl := d.messageTTL(messageKey(msg)); ttl > 0 {
+		expiresAt := stored.createdAt.Add(ttl)
+		msg.ExpiresAt = &expiresAt
+	}
+	msg.Mentions = d.messageMentions(msg)
+	stored.msg = msg
+	d.messages[msg.MessageUUID] = stored
+	d.linkAttachments(msg, true)
+	d.indexMessage(msg)
+	d.indexMentions(stored)
+
+	// replies go to their thread instead of the conversation
+	if msg.ParentMessageUUID != "" {
+		d.addReply(stored)
+	} else {
+		key := messageKey(msg)
+		d.conversations[key] = append(d.conversations[key], stored)
+		d.countMessage(stored, 1)
+	}
+	if msg.ExpiresAt != nil {
+		heap.Push(&d.expiries, stored)
+	}
+	d.updateSummaries(msg)
+	return msg, nil
+}
+
+// GetChatMessage returns a chat message to a participant of its conversation
+func (d *Database) GetChatMessage(ctx context.Context, participant, messageUUID string) (proto.ChatMessage, error) {
+	type result struct {
+		msg proto.ChatMessage
+		err error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+		if !d.isParticipant(stored.msg, participant) {
+			r <- result{err: errors.Wrap(ErrNotParticipant, messageUUID)}
+			return
+		}
+		r <- result{msg: stored.msg}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, ctx.Err()
+	}
+}
+
+// ListConversation returns up to limit messages exchanged between viewer and withEmail, newest first,
+// starting right before the before position (0 starts from the newest message)
+// next is the position to pass to get the following page, 0 when there are no more messages
+// messages hidden by the viewer are left out, deleted ones are kept as tombstones
+func (d *Database) ListConversation(ctx context.Context, viewer, withEmail string, before uint64, limit int) ([]proto.ChatMessage, uint64, error) {
+	p := make(chan page, 1)
+	d.actionCh <- func() {
+		p <- listPage(d.conversations[ConversationKey(viewer, withEmail)], viewer, before, limit)
+	}
+	select {
+	case res := <-p:
+		return res.messages, res.next, nil
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// page is a slice of a conversation, next is the position
+// the following page starts before
+type page struct {
+	messages []proto.ChatMessage
+	next     uint64
+}
+
+// listPage returns up to limit messages of a conversation ordered by arrival, newest first,
+// starting right before the before position and leaving out the ones hidden by viewer
+func listPage(stored []*storedMessage, viewer string, before uint64, limit int) page {
+	return filterPage(stored, before, limit, func(stored *storedMessage) bool {
+		return !stored.hiddenFor[viewer]
+	})
+}
+
+// filterPage returns up to limit messages of a list ordered by arrival, newest first,
+// starting right before the before position and keeping only the ones keep accepts
+func filterPage(stored []*storedMessage, before uint64, limit int, keep func(*storedMessage) bool) page {
+	end := len(stored)
+	if before != 0 {
+		end = sort.Search(len(stored), func(i int) bool {
+			return stored[i].seq >= before
+		})
+	}
+
+	var res page
+	i := end - 1
+	for ; i >= 0 && len(res.messages) < limit; i-- {
+		if !keep(stored[i]) {
+			continue
+		}
+		res.messages = append(res.messages, stored[i].msg)
+	}
+	// older messages are left, the next page starts before the last visited one
+	if i >= 0 {
+		res.next = stored[i+1].seq
+	}
+	return res
+}
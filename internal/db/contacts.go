@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"sort"
+)
+
+// Contacts returns the users email has a conversation or shares a room with
+func (d *Database) Contacts(ctx context.Context, email string) ([]string, error) {
+	c := make(chan []string, 1)
+	d.actionCh <- func() {
+		set := make(map[string]bool)
+		for withEmail := range d.summaries[email] {
+			set[withEmail] = true
+		}
+		for roomID := range d.memberRooms[email] {
+			for member := range d.rooms[roomID].members {
+				set[member] = true
+			}
+		}
+		delete(set, email)
+
+		res := make([]string, 0, len(set))
+		for contact := range set {
+			res = append(res, contact)
+		}
+		sort.Strings(res)
+		c <- res
+	}
+	select {
+	case res := <-c:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// storedCursor is the read cursor of a user in a conversation along with the number of messages
+// left to read there. A user that read nothing yet has a cursor without MessageUUID once counted
+type storedCursor struct {
+	// position of the last read message
+	seq uint64
+	// messages of others in the history past seq, kept up to date as the history changes
+	unread int
+	cursor proto.ReadCursor
+}
+
+// SetReadCursor moves the read cursor of email in their conversation with withEmail, or in the room
+// roomID, to messageUUID which must belong to its history. Cursors only move forward, moving one
+// back returns it as it is and changed is false
+func (d *Database) SetReadCursor(ctx context.Context, email, withEmail, roomID, messageUUID string, at time.Time) (proto.ReadCursor, bool, error) {
+	type result struct {
+		cursor  proto.ReadCursor
+		changed bool
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		key, err := d.conversationKey(email, withEmail, roomID)
+		if err != nil {
+			r <- result{err: err}
+			return
+		}
+		// thread replies are not part of the history
+		stored, ok := d.messages[messageUUID]
+		if !ok || stored.msg.ParentMessageUUID != "" || messageKey(stored.msg) != key {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+
+		current := d.readCursor(email, withEmail, roomID)
+		changed := current.cursor.MessageUUID == "" || stored.seq > current.seq
+		if changed {
+			// the messages read by the move are no longer counted
+			current.unread -= d.countUnread(key, email, current.seq, stored.seq)
+			current.seq = stored.seq
+			current.cursor.MessageUUID = messageUUID
+			current.cursor.UpdatedAt = at
+		}
+
+		cursor := current.cursor
+		cursor.UnreadCount = current.unread
+		r <- result{cursor: cursor, changed: changed}
+	}
+	select {
+	case res := <-r:
+		return res.cursor, res.changed, res.err
+	case <-ctx.Done():
+		return proto.ReadCursor{}, false, ctx.Err()
+	}
+}
+
+// readCursor returns the read cursor of email in their conversation with withEmail, or in
+// the room roomID. The unread messages of a user without one are counted once, the count
+// is kept up to date from then on. Must be called from within an action
+func (d *Database) readCursor(email, withEmail, roomID string) *storedCursor {
+	key := RoomKey(roomID)
+	if roomID == "" {
+		key = ConversationKey(email, withEmail)
+	}
+	cursors, ok := d.readCursors[key]
+	if !ok {
+		cursors = make(map[string]*storedCursor)
+		d.readCursors[key] = cursors
+	}
+	if stored, ok := cursors[email]; ok {
+		return stored
+	}
+
+	stored := &storedCursor{
+		unread: d.countUnread(key, email, 0, math.MaxUint64),
+		cursor: proto.ReadCursor{Email: email},
+	}
+	if roomID != "" {
+		stored.cursor.RoomID = &roomID
+	} else {
+		stored.cursor.WithEmail = &withEmail
+	}
+	cursors[email] = stored
+	return stored
+}
+
+// countUnread returns the number of messages of others email can read in the history of the
+// conversation of key between the positions after and upTo included. Must be called from within an action
+func (d *Database) countUnread(key, email string, after, upTo uint64) int {
+	// the history is ordered by position so only the part in between is walked
+	history := d.conversations[key]
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].seq > after
+	})
+	count := 0
+	for ; i < len(history) && history[i].seq <= upTo; i++ {
+		if unreadBy(history[i], email) {
+			count++
+		}
+	}
+	return count
+}
+
+// countMessage adds delta to the unread counts of the read cursors past a message of the history,
+// the message is counted for every reader it is unread by. Must be called from within an action
+func (d *Database) countMessage(stored *storedMessage, delta int) {
+	if stored.msg.ParentMessageUUID != "" {
+		return
+	}
+	for reader, cursor := range d.readCursors[messageKey(stored.msg)] {
+		if stored.seq > cursor.seq && unreadBy(stored, reader) {
+			cursor.unread += delta
+		}
+	}
+}
+
+// unreadBy reports whether a message of the history counts as unread for email until it reads past it
+func unreadBy(stored *storedMessage, email string) bool {
+	return stored.msg.FromEmail != email && !stored.msg.Deleted && !stored.hiddenFor[email]
+}
+
+// readCursorsOf returns the read cursors of email along with their unread counts,
+// ordered by the time they last moved. Must be called from within an action
+func (d *Database) readCursorsOf(email string) []proto.ReadCursor {
+	var res []proto.ReadCursor
+	for _, cursors := range d.readCursors {
+		if stored, ok := cursors[email]; ok && stored.cursor.MessageUUID != "" {
+			cursor := stored.cursor
+			cursor.UnreadCount = stored.unread
+			res = append(res, cursor)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].UpdatedAt.Before(res[j].UpdatedAt)
+	})
+	return res
+}
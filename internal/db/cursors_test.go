@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// unread returns the unread count of owner in their conversation with withEmail as ListConversations has it
+func unread(t *testing.T, d *Database, owner, withEmail string) int {
+	t.Helper()
+	conversations, err := d.ListConversations(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conversation := range conversations {
+		if conversation.WithEmail == withEmail {
+			return conversation.UnreadCount
+		}
+	}
+	t.Fatalf("%s has no conversation with %s", owner, withEmail)
+	return 0
+}
+
+// scanned counts the unread messages of email in the conversation of key walking its whole history
+func scanned(d *Database, key, email string) int {
+	r := make(chan int, 1)
+	d.actionCh <- func() {
+		var read uint64
+		if cursor, ok := d.readCursors[key][email]; ok {
+			read = cursor.seq
+		}
+		r <- d.countUnread(key, email, read, math.MaxUint64)
+	}
+	return <-r
+}
+
+func TestUnreadCountsFollowTheHistory(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	var sent []proto.ChatMessage
+	for i := 0; i < 5; i++ {
+		sent = append(sent, sendMessage(t, d, "a@x.com", "b@x.com", "", "hi"))
+	}
+	sendMessage(t, d, "b@x.com", "a@x.com", "", "own messages are read")
+	if n := unread(t, d, "b@x.com", "a@x.com"); n != 5 {
+		t.Fatalf("unread = %d, want 5", n)
+	}
+
+	err := d.HideChatMessage(ctx, "b@x.com", sent[1].MessageUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.DeleteChatMessage(ctx, "a@x.com", sent[2].MessageUUID, time.Time{}, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := unread(t, d, "b@x.com", "a@x.com"); n != 3 {
+		t.Fatalf("unread after hide and delete = %d, want 3", n)
+	}
+
+	cursor, changed, err := d.SetReadCursor(ctx, "b@x.com", "a@x.com", "", sent[3].MessageUUID, now)
+	if err != nil || !changed {
+		t.Fatalf("SetReadCursor = %v, %v", changed, err)
+	}
+	if cursor.UnreadCount != 1 {
+		t.Fatalf("unread after reading = %d, want 1", cursor.UnreadCount)
+	}
+
+	// moving back leaves the cursor and its count as they are
+	cursor, changed, err = d.SetReadCursor(ctx, "b@x.com", "a@x.com", "", sent[0].MessageUUID, now)
+	if err != nil || changed || cursor.UnreadCount != 1 || cursor.MessageUUID != sent[3].MessageUUID {
+		t.Fatalf("SetReadCursor back = %+v, %v, %v", cursor, changed, err)
+	}
+
+	// a message deleted past the cursor is no longer unread, one before it changes nothing
+	sendMessage(t, d, "a@x.com", "b@x.com", "", "later")
+	_, err = d.DeleteChatMessage(ctx, "a@x.com", sent[4].MessageUUID, time.Time{}, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.DeleteChatMessage(ctx, "a@x.com", sent[0].MessageUUID, time.Time{}, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ConversationKey("a@x.com", "b@x.com")
+	if n := unread(t, d, "b@x.com", "a@x.com"); n != 1 || n != scanned(d, key, "b@x.com") {
+		t.Fatalf("unread = %d, scanned %d, want 1", n, scanned(d, key, "b@x.com"))
+	}
+	if n := unread(t, d, "a@x.com", "b@x.com"); n != 1 || n != scanned(d, key, "a@x.com") {
+		t.Fatalf("unread of the sender = %d, scanned %d, want 1", n, scanned(d, key, "a@x.com"))
+	}
+}
+
+func TestUnreadCountsOfRoomMembers(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	_, err := d.CreateRoom(ctx, proto.Room{RoomID: "room", Members: []string{"a@x.com", "b@x.com", "c@x.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := sendMessage(t, d, "a@x.com", "", "room", "one")
+	cursor, _, err := d.SetReadCursor(ctx, "b@x.com", "", "room", first.MessageUUID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.UnreadCount != 0 {
+		t.Fatalf("unread of b = %d, want 0", cursor.UnreadCount)
+	}
+
+	sendMessage(t, d, "c@x.com", "", "room", "two")
+	sendMessage(t, d, "a@x.com", "", "room", "three")
+	cursor, _, err = d.SetReadCursor(ctx, "b@x.com", "", "room", first.MessageUUID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.UnreadCount != 2 || cursor.UnreadCount != scanned(d, RoomKey("room"), "b@x.com") {
+		t.Fatalf("unread of b = %d, want 2", cursor.UnreadCount)
+	}
+
+	// c reads the first message, their own one is not counted
+	cursor, _, err = d.SetReadCursor(ctx, "c@x.com", "", "room", first.MessageUUID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.UnreadCount != 1 {
+		t.Fatalf("unread of c = %d, want 1", cursor.UnreadCount)
+	}
+
+	_, _, err = d.SetReadCursor(ctx, "x@x.com", "", "room", first.MessageUUID, now)
+	if err == nil {
+		t.Fatal("a non member moved a room cursor")
+	}
+}
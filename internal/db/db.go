@@ -2,10 +2,13 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/search"
 )
 
 type PartitionKey struct {
@@ -28,6 +31,50 @@ type Database struct {
 	quitCh   chan chan struct{}
 	actionCh chan func()
 	Schedule map[PartitionKey]map[SortKey]Task
+	// chat messages keyed by MessageUUID
+	messages map[string]*storedMessage
+	// chat messages ordered by arrival keyed by ConversationKey
+	conversations map[string][]*storedMessage
+	// thread replies ordered by arrival keyed by the parent MessageUUID
+	threads map[string][]*storedMessage
+	// text of the chat messages keyed by MessageUUID, one index per ConversationKey or RoomKey
+	// so that a search only goes through the conversations of the user searching
+	indexes map[string]*search.Index
+	// conversation summaries keyed by owner email then counterpart email
+	summaries map[string]map[string]*proto.ConversationSummary
+	// rooms keyed by RoomID
+	rooms map[string]*storedRoom
+	// RoomIDs keyed by member email
+	memberRooms map[string]map[string]bool
+	// last assigned message position
+	seq uint64
+	// attachments keyed by AttachmentID
+	attachments map[string]*storedAttachment
+	// attachments storage usage keyed by owner email
+	storage map[string]*storageUsage
+	// client submissions keyed by submissionKey, and in the order they came in
+	submissions     map[string]*submission
+	submissionOrder []*submission
+	// conversation settings keyed by ConversationKey or RoomKey
+	settings map[string]proto.ConversationSettings
+	// chat messages that expire, the first to expire first
+	expiries expiryQueue
+	// time users were blocked keyed by blocker email then blocked email
+	blocks map[string]map[string]time.Time
+	// users that muted a conversation keyed by ConversationKey or RoomKey
+	muted map[string]map[string]bool
+	// chat messages mentioning a user keyed by email, and in the order they came in
+	mentioned map[string][]*storedMessage
+	// data exports keyed by ExportID, and keyed by email in the order they were created
+	exports     map[string]*storedExport
+	userExports map[string][]*storedExport
+	// read cursors keyed by ConversationKey or RoomKey then reader email
+	readCursors map[string]map[string]*storedCursor
+	// events none of the streams of a user got yet keyed by email
+	pending map[string]*pendingQueue
+	// blobs claimed for deletion, and the time the deletion of a blob was over keyed by blob key
+	deletingBlobs map[string]bool
+	deletedBlobs  map[string]time.Time
 }
 
 func NewPartitionKey(driverName string, week int) PartitionKey {
@@ -54,13 +101,33 @@ func NewTask(operation string, startHr, duration int) Task {
 
 func NewDatabase() *Database {
 	return &Database{
-		Schedule: make(map[PartitionKey]map[SortKey]Task),
-		quitCh:   make(chan chan struct{}),
-		actionCh: make(chan func(), 1000),
+		Schedule:      make(map[PartitionKey]map[SortKey]Task),
+		quitCh:        make(chan chan struct{}),
+		actionCh:      make(chan func(), 1000),
+		messages:      make(map[string]*storedMessage),
+		conversations: make(map[string][]*storedMessage),
+		threads:       make(map[string][]*storedMessage),
+		indexes:       make(map[string]*search.Index),
+		summaries:     make(map[string]map[string]*proto.ConversationSummary),
+		rooms:         make(map[string]*storedRoom),
+		memberRooms:   make(map[string]map[string]bool),
+		attachments:   make(map[string]*storedAttachment),
+		storage:       make(map[string]*storageUsage),
+		submissions:   make(map[string]*submission),
+		settings:      make(map[string]proto.ConversationSettings),
+		blocks:        make(map[string]map[string]time.Time),
+		muted:         make(map[string]map[string]bool),
+		mentioned:     make(map[string][]*storedMessage),
+		exports:       make(map[string]*storedExport),
+		userExports:   make(map[string][]*storedExport),
+		readCursors:   make(map[string]map[string]*storedCursor),
+		pending:       make(map[string]*pendingQueue),
+		deletingBlobs: make(map[string]bool),
+		deletedBlobs:  make(map[string]time.Time),
 	}
 }
 
-func (d *Database) Run() error{
+func (d *Database) Run() error {
 	defer func() {
 		log.Println("Database closed")
 	}()
@@ -93,7 +160,6 @@ func (d *Database) CreateTask(ctx context.Context, task Task, partitionKey Parti
 				return
 			} else {
 				dbTaskSortKeyMap[sortKey] = task
-				fmt.Println(dbTaskSortKeyMap[sortKey], task)
 				e <- nil
 				return
 			}
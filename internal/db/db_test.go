@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// messages stored by the tests so far, it numbers their MessageUUIDs
+var testMessages uint64
+
+// newTestDatabase returns a running database stopped when the test ends
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	d := NewDatabase()
+	go d.Run()
+	t.Cleanup(d.Stop)
+	return d
+}
+
+// sendMessage stores a direct message of from to to, or a room message when roomID is set
+func sendMessage(t *testing.T, d *Database, from, to, roomID, text string) proto.ChatMessage {
+	t.Helper()
+	msg := proto.ChatMessage{
+		FromEmail:   from,
+		ToEmail:     to,
+		RoomID:      roomID,
+		MessageUUID: fmt.Sprintf("message-%d", atomic.AddUint64(&testMessages, 1)),
+		MessageText: text,
+	}
+	msg, err := d.CreateChatMessage(context.Background(), from, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
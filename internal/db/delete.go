@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrDeleteWindowPassed is returned when the author deletes a chat message too late
+	ErrDeleteWindowPassed = errors.New("chat message can no longer be deleted")
+	// ErrMessageDeleted is returned when changing a chat message that was deleted
+	ErrMessageDeleted = errors.New("chat message was deleted")
+)
+
+// DeleteChatMessage retracts a chat message for everyone, the message is kept
+// as a tombstone without text, attachments, reactions, annotations or history so conversation pages don't shift.
+// Unless moderator is set only the author can delete a message, and only if it
+// was created after notBefore
+func (d *Database) DeleteChatMessage(ctx context.Context, requester, messageUUID string, notBefore time.Time, moderator bool, at time.Time) (proto.ChatMessage, error) {
+	type result struct {
+		msg proto.ChatMessage
+		err error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+		if !moderator {
+			if stored.msg.FromEmail != requester {
+				r <- result{err: errors.Wrap(ErrNotAuthor, messageUUID)}
+				return
+			}
+			if stored.createdAt.Before(notBefore) {
+				r <- result{err: errors.Wrap(ErrDeleteWindowPassed, messageUUID)}
+				return
+			}
+		}
+		if stored.msg.Deleted {
+			r <- result{msg: stored.msg}
+			return
+		}
+
+		d.tombstone(stored, at)
+		r <- result{msg: stored.msg}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, ctx.Err()
+	}
+}
+
+// HideChatMessage hides a chat message from the conversation of viewer only
+func (d *Database) HideChatMessage(ctx context.Context, viewer, messageUUID string) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			e <- errors.Wrap(ErrMessageNotFound, messageUUID)
+			return
+		}
+		if !d.isParticipant(stored.msg, viewer) {
+			e <- errors.Wrap(ErrNotParticipant, messageUUID)
+			return
+		}
+		if stored.hiddenFor == nil {
+			stored.hiddenFor = make(map[string]bool)
+		}
+		// a hidden message no longer waits to be read
+		cursor, ok := d.readCursors[messageKey(stored.msg)][viewer]
+		if ok && stored.msg.ParentMessageUUID == "" && stored.seq > cursor.seq && unreadBy(stored, viewer) {
+			cursor.unread--
+		}
+		stored.hiddenFor[viewer] = true
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tombstone empties a chat message and takes it out of the summaries, attachments
+// and indexes, the message itself stays in place. Must be called from within an action
+func (d *Database) tombstone(stored *storedMessage, at time.Time) {
+	d.countMessage(stored, -1)
+	for _, s := range d.messageSummaries(stored.msg) {
+		if s.LastMessageUUID == stored.msg.MessageUUID {
+			s.LastMessagePreview = ""
+		}
+	}
+
+	d.linkAttachments(stored.msg, false)
+	d.unindexMessage(stored.msg)
+	d.unindexMentions(stored)
+
+	stored.history = nil
+	stored.msg.MessageText = ""
+	stored.msg.AttachmentIDs = nil
+	stored.msg.Reactions = nil
+	stored.msg.Annotations = nil
+	stored.msg.Mentions = nil
+	stored.msg.Deleted = true
+	stored.msg.UpdatedAt = &at
+}
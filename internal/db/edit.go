@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrNotAuthor is returned when a chat message is changed by someone else than its author
+	ErrNotAuthor = errors.New("not the author of the chat message")
+	// ErrNotSender is returned when a chat message is sent on behalf of someone else than the caller
+	ErrNotSender = errors.New("not the sender of the chat message")
+	// ErrVersionConflict is returned when a chat message changed since the version the caller knows
+	ErrVersionConflict = errors.New("chat message version conflict")
+	// ErrNotParticipant is returned when a chat message is read by someone outside the conversation
+	ErrNotParticipant = errors.New("not a participant of the conversation")
+)
+
+// EditChatMessage replaces the text and moderation annotations of a chat message if version is
+// the stored one, the previous revision is kept in the message history and the version is bumped.
+// The mentions are parsed again, the users the edit newly mentions are returned
+func (d *Database) EditChatMessage(ctx context.Context, author, messageUUID, text string, annotations []*proto.Annotation, version string, at time.Time) (proto.ChatMessage, []string, error) {
+	type result struct {
+		msg       proto.ChatMessage
+		mentioned []string
+		err       error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+		if stored.msg.FromEmail != author {
+			r <- result{err: errors.Wrap(ErrNotAuthor, messageUUID)}
+			return
+		}
+		if stored.msg.Deleted {
+			r <- result{err: errors.Wrap(ErrMessageDeleted, messageUUID)}
+			return
+		}
+		if stored.msg.Version != version {
+			r <- result{err: errors.Wrapf(ErrVersionConflict, "%s is at version %s", messageUUID, stored.msg.Version)}
+			return
+		}
+
+		stored.history = append(stored.history, stored.msg)
+		stored.msg.MessageText = text
+		stored.msg.Annotations = annotations
+		d.indexMessage(stored.msg)
+		stored.msg.Version = nextVersion(stored.msg.Version)
+		stored.msg.UpdatedAt = &at
+
+		previous := make(map[string]bool)
+		for _, email := range mentionedEmails(stored.msg) {
+			previous[email] = true
+		}
+		d.unindexMentions(stored)
+		stored.msg.Mentions = d.messageMentions(stored.msg)
+		d.indexMentions(stored)
+		var mentioned []string
+		for _, email := range mentionedEmails(stored.msg) {
+			if !previous[email] {
+				mentioned = append(mentioned, email)
+			}
+		}
+
+		// keep the inbox previews in line with the edit
+		for _, s := range d.messageSummaries(stored.msg) {
+			if s.LastMessageUUID == messageUUID {
+				s.LastMessagePreview = preview(text)
+			}
+		}
+
+		r <- result{msg: stored.msg, mentioned: mentioned}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.mentioned, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, nil, ctx.Err()
+	}
+}
+
+// GetMessageHistory returns every revision of a chat message, oldest first
+// and ending with the current one, to a participant of the conversation
+func (d *Database) GetMessageHistory(ctx context.Context, participant, messageUUID string) ([]proto.ChatMessage, error) {
+	type result struct {
+		revisions []proto.ChatMessage
+		err       error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+		if !d.isParticipant(stored.msg, participant) {
+			r <- result{err: errors.Wrap(ErrNotParticipant, messageUUID)}
+			return
+		}
+
+		revisions := make([]proto.ChatMessage, 0, len(stored.history)+1)
+		revisions = append(revisions, stored.history...)
+		revisions = append(revisions, stored.msg)
+		r <- result{revisions: revisions}
+	}
+	select {
+	case res := <-r:
+		return res.revisions, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// nextVersion bumps a chat message version, unknown versions restart from 1
+func nextVersion(version string) string {
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		v = 0
+	}
+	return strconv.Itoa(v + 1)
+}
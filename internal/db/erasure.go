@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// ErasedEmail replaces the email of erased users in the room messages and rooms they leave behind
+	ErasedEmail = "erased"
+)
+
+// Erasure is what erasing a user removed from the database
+type Erasure struct {
+	// direct messages purged and room messages anonymized
+	Messages int
+	// attachments the user uploaded
+	Attachments int
+	// blobs nothing else refers to anymore, they can be deleted from the blob store
+	BlobKeys []string
+}
+
+// EraseUser removes everything stored about email. Direct messages to and from them are purged
+// along with their threads, their room messages are kept as anonymized tombstones so the threads
+// of others hold together, and their attachments, exports, memberships, reactions, settings,
+// read cursors, blocks and mutes are dropped. Erasing a user that is already gone is a no-op.
+// Once started the erasure is waited for even if ctx is done meanwhile, the blobs it
+// releases are only known from its result
+func (d *Database) EraseUser(ctx context.Context, email string, at time.Time) (Erasure, error) {
+	if err := ctx.Err(); err != nil {
+		return Erasure{}, err
+	}
+	r := make(chan Erasure, 1)
+	d.actionCh <- func() {
+		var res Erasure
+
+		var direct, authored []*storedMessage
+		for _, stored := range d.messages {
+			msg := stored.msg
+			switch {
+			case msg.RoomID == "" && (msg.FromEmail == email || msg.ToEmail == email):
+				direct = append(direct, stored)
+			case msg.RoomID != "" && msg.FromEmail == email:
+				authored = append(authored, stored)
+			}
+		}
+		sort.Slice(direct, func(i, j int) bool {
+			return direct[i].seq < direct[j].seq
+		})
+		sort.Slice(authored, func(i, j int) bool {
+			return authored[i].seq < authored[j].seq
+		})
+
+		// replies go along with their parent
+		var purged []proto.ChatMessage
+		for _, stored := range direct {
+			if _, ok := d.messages[stored.msg.MessageUUID]; ok {
+				purged = d.purgeMessage(stored, purged)
+			}
+		}
+		res.Messages += len(purged)
+
+		for _, stored := range authored {
+			if !stored.msg.Deleted {
+				d.tombstone(stored, at)
+			}
+			stored.msg.FromEmail = ErasedEmail
+			stored.msg.SK = strings.Replace(stored.msg.SK, email, ErasedEmail, 1)
+			res.Messages++
+		}
+
+		// what the user left on the messages of others
+		for _, stored := range d.messages {
+			for _, reaction := range stored.msg.Reactions {
+				if hasEmail(reaction.Emails, email) {
+					stored.msg.Reactions, _ = withReaction(stored.msg.Reactions, email, reaction.Emoji, false)
+				}
+			}
+			delete(stored.hiddenFor, email)
+			stored.msg.Mentions = withoutMentionOf(stored.msg.Mentions, email)
+		}
+		delete(d.mentioned, email)
+
+		delete(d.summaries, email)
+		for _, inbox := range d.summaries {
+			delete(inbox, email)
+		}
+
+		for roomID := range d.memberRooms[email] {
+			if room, ok := d.rooms[roomID]; ok {
+				delete(room.members, email)
+				room.room.UpdatedAt = at
+			}
+		}
+		delete(d.memberRooms, email)
+		for _, room := range d.rooms {
+			if room.room.CreatedBy == email {
+				room.room.CreatedBy = ErasedEmail
+			}
+		}
+
+		for key, settings := range d.settings {
+			if hasEmail(settings.Emails, email) {
+				delete(d.settings, key)
+				continue
+			}
+			if settings.UpdatedBy == email {
+				settings.UpdatedBy = ErasedEmail
+				d.settings[key] = settings
+			}
+		}
+
+		delete(d.pending, email)
+
+		delete(d.blocks, email)
+		for blocker, blocked := range d.blocks {
+			delete(blocked, email)
+			if len(blocked) == 0 {
+				delete(d.blocks, blocker)
+			}
+		}
+		for key, cursors := range d.readCursors {
+			for reader, stored := range cursors {
+				if reader == email || (stored.cursor.WithEmail != nil && *stored.cursor.WithEmail == email) {
+					delete(cursors, reader)
+				}
+			}
+			if len(cursors) == 0 {
+				delete(d.readCursors, key)
+			}
+		}
+		for key, muted := range d.muted {
+			delete(muted, email)
+			if len(muted) == 0 {
+				delete(d.muted, key)
+			}
+		}
+
+		for key, sub := range d.submissions {
+			if sub.msg.FromEmail == email {
+				delete(d.submissions, key)
+			}
+		}
+		order := d.submissionOrder[:0]
+		for _, sub := range d.submissionOrder {
+			if sub.msg.FromEmail != email {
+				order = append(order, sub)
+			}
+		}
+		for i := len(order); i < len(d.submissionOrder); i++ {
+			d.submissionOrder[i] = nil
+		}
+		d.submissionOrder = order
+
+		// blobs are addressed by content so others may store the same one
+		released := make(map[string]bool)
+		for id, stored := range d.attachments {
+			if stored.attachment.OwnerEmail != email {
+				continue
+			}
+			released[stored.blobKey] = true
+			if stored.thumbnailKey != "" {
+				released[stored.thumbnailKey] = true
+			}
+			delete(d.attachments, id)
+			res.Attachments++
+		}
+		delete(d.storage, email)
+
+		for _, stored := range d.userExports[email] {
+			delete(d.exports, stored.export.ExportID)
+			if stored.blobKey != "" {
+				released[stored.blobKey] = true
+			}
+		}
+		delete(d.userExports, email)
+
+		for key := range released {
+			if !d.blobReferenced(key) {
+				res.BlobKeys = append(res.BlobKeys, key)
+			}
+		}
+		sort.Strings(res.BlobKeys)
+
+		r <- res
+	}
+	return <-r, nil
+}
+
+// withoutMentionOf returns mentions without the ones of email
+func withoutMentionOf(mentions []*proto.Mention, email string) []*proto.Mention {
+	var res []*proto.Mention
+	for _, mention := range mentions {
+		if mention.Email != email {
+			res = append(res, mention)
+		}
+	}
+	return res
+}
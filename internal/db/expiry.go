@@ -0,0 +1,220 @@
+package db
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// expiryQueue is a min-heap of the chat messages that expire,
+// ordered by expiry time then arrival
+type expiryQueue []*storedMessage
+
+func (q expiryQueue) Len() int { return len(q) }
+
+func (q expiryQueue) Less(i, j int) bool {
+	if q[i].msg.ExpiresAt.Equal(*q[j].msg.ExpiresAt) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].msg.ExpiresAt.Before(*q[j].msg.ExpiresAt)
+}
+
+func (q expiryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *expiryQueue) Push(x interface{}) {
+	*q = append(*q, x.(*storedMessage))
+}
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	stored := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return stored
+}
+
+// GetConversationSettings returns the settings of the conversation between requester and withEmail,
+// or of a room when roomID is set, to a participant of the conversation
+func (d *Database) GetConversationSettings(ctx context.Context, requester, withEmail, roomID string) (proto.ConversationSettings, error) {
+	type result struct {
+		settings proto.ConversationSettings
+		err      error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		_, settings, err := d.conversationSettings(requester, withEmail, roomID)
+		r <- result{settings: settings, err: err}
+	}
+	select {
+	case res := <-r:
+		return res.settings, res.err
+	case <-ctx.Done():
+		return proto.ConversationSettings{}, ctx.Err()
+	}
+}
+
+// SetMessageTTL sets the lifetime of the messages sent from now on to the conversation between
+// requester and withEmail, or to a room when roomID is set. 0 keeps messages until they are deleted,
+// messages already sent keep the expiry time they were sent with
+func (d *Database) SetMessageTTL(ctx context.Context, requester, withEmail, roomID string, ttl time.Duration, at time.Time) (proto.ConversationSettings, error) {
+	type result struct {
+		settings proto.ConversationSettings
+		err      error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		key, settings, err := d.conversationSettings(requester, withEmail, roomID)
+		if err != nil {
+			r <- result{err: err}
+			return
+		}
+		settings.MessageTTL = int64(ttl / time.Second)
+		settings.UpdatedBy = requester
+		settings.UpdatedAt = &at
+		d.settings[key] = settings
+		r <- result{settings: settings}
+	}
+	select {
+	case res := <-r:
+		return res.settings, res.err
+	case <-ctx.Done():
+		return proto.ConversationSettings{}, ctx.Err()
+	}
+}
+
+// conversationSettings returns the key and settings of the conversation between requester and withEmail,
+// or of a room when roomID is set, conversations that were never set up get the defaults.
+// Must be called from within an action
+func (d *Database) conversationSettings(requester, withEmail, roomID string) (string, proto.ConversationSettings, error) {
+	key, err := d.conversationKey(requester, withEmail, roomID)
+	if err != nil {
+		return "", proto.ConversationSettings{}, err
+	}
+	if settings, ok := d.settings[key]; ok {
+		return key, settings, nil
+	}
+
+	if roomID != "" {
+		return key, proto.ConversationSettings{RoomID: roomID}, nil
+	}
+	defaults := proto.ConversationSettings{Emails: []string{requester, withEmail}}
+	sort.Strings(defaults.Emails)
+	return key, defaults, nil
+}
+
+// conversationKey returns the key of the conversation between requester and withEmail,
+// or of a room requester is a member of when roomID is set. Must be called from within an action
+func (d *Database) conversationKey(requester, withEmail, roomID string) (string, error) {
+	if roomID == "" {
+		return ConversationKey(requester, withEmail), nil
+	}
+	room, ok := d.rooms[roomID]
+	if !ok {
+		return "", errors.Wrap(ErrRoomNotFound, roomID)
+	}
+	if !room.members[requester] {
+		return "", errors.Wrap(ErrNotMember, roomID)
+	}
+	return RoomKey(roomID), nil
+}
+
+// messageTTL returns the lifetime of the messages sent to the conversation of key,
+// 0 when they don't expire. Must be called from within an action
+func (d *Database) messageTTL(key string) time.Duration {
+	return time.Duration(d.settings[key].MessageTTL) * time.Second
+}
+
+// ExpireMessages purges the chat messages that expired at now and returns them, the replies of
+// an expired thread parent go along with it. The pending events about them are dropped.
+// Only the expired messages are visited
+func (d *Database) ExpireMessages(ctx context.Context, now time.Time) ([]proto.ChatMessage, error) {
+	r := make(chan []proto.ChatMessage, 1)
+	d.actionCh <- func() {
+		var expired []proto.ChatMessage
+		for len(d.expiries) > 0 && !d.expiries[0].msg.ExpiresAt.After(now) {
+			stored := heap.Pop(&d.expiries).(*storedMessage)
+			// purged along with its thread parent
+			if d.messages[stored.msg.MessageUUID] != stored {
+				continue
+			}
+			expired = d.purgeMessage(stored, expired)
+		}
+		if len(expired) > 0 {
+			purged := make(map[string]bool, len(expired))
+			for _, msg := range expired {
+				purged[msg.MessageUUID] = true
+			}
+			d.dropPendingEvents(purged)
+		}
+		r <- expired
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// purgeMessage removes a chat message and its thread replies from every index,
+// they are appended to purged. Must be called from within an action
+func (d *Database) purgeMessage(stored *storedMessage, purged []proto.ChatMessage) []proto.ChatMessage {
+	msg := stored.msg
+	delete(d.messages, msg.MessageUUID)
+
+	if msg.ParentMessageUUID != "" {
+		// the thread of a purged parent is already gone
+		if parent, ok := d.messages[msg.ParentMessageUUID]; ok {
+			d.threads[msg.ParentMessageUUID] = removeStored(d.threads[msg.ParentMessageUUID], stored)
+			if parent.msg.ReplyCount > 0 {
+				parent.msg.ReplyCount--
+			}
+		}
+	} else {
+		key := messageKey(msg)
+		d.countMessage(stored, -1)
+		d.conversations[key] = removeStored(d.conversations[key], stored)
+		if len(d.conversations[key]) == 0 {
+			delete(d.conversations, key)
+		}
+	}
+
+	// tombstones were already taken out of the summaries, attachments and indexes
+	if !msg.Deleted {
+		for _, s := range d.messageSummaries(msg) {
+			if s.LastMessageUUID == msg.MessageUUID {
+				s.LastMessagePreview = ""
+			}
+		}
+		d.linkAttachments(msg, false)
+		d.unindexMessage(msg)
+		d.unindexMentions(stored)
+	}
+	purged = append(purged, msg)
+
+	replies := d.threads[msg.MessageUUID]
+	delete(d.threads, msg.MessageUUID)
+	for _, reply := range replies {
+		purged = d.purgeMessage(reply, purged)
+	}
+	return purged
+}
+
+// removeStored removes a message from a list ordered by arrival
+func removeStored(list []*storedMessage, stored *storedMessage) []*storedMessage {
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].seq >= stored.seq
+	})
+	if i == len(list) || list[i] != stored {
+		return list
+	}
+	copy(list[i:], list[i+1:])
+	list[len(list)-1] = nil
+	return list[:len(list)-1]
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// Statuses of a data export
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+var (
+	// ErrExportNotFound is returned when a data export is missing or belongs to someone else
+	ErrExportNotFound = errors.New("data export not found")
+	// ErrExportNotDone is returned when the archive of a data export is downloaded before it is written
+	ErrExportNotDone = errors.New("data export is not done")
+)
+
+type storedExport struct {
+	export proto.DataExport
+	// key of the archive in the blob store, set once done
+	blobKey string
+}
+
+// UserData is everything stored about a user, gathered for data exports
+type UserData struct {
+	// messages the user sent and direct messages sent to them, in the order they came in
+	Messages []proto.ChatMessage
+	// previous revisions of the messages the user edited, oldest first
+	Revisions []proto.ChatMessage
+	// reactions of the user, one per message and emoji
+	Reactions []UserReaction
+	// attachments the user uploaded, oldest first
+	Attachments []proto.Attachment
+	// rooms the user is a member of
+	Rooms []proto.Room
+	// settings of the conversations of the user that changed from the defaults
+	Settings []proto.ConversationSettings
+	// users the user blocked ordered by email
+	Blocked []proto.BlockedUser
+	// conversations the user muted
+	Muted []MutedConversation
+	// read cursors of the user, the least recently moved first
+	ReadCursors []proto.ReadCursor
+}
+
+// UserReaction is an emoji a user reacted to a message with
+type UserReaction struct {
+	MessageUUID string `json:"messageUUID"`
+	Emoji       string `json:"emoji"`
+}
+
+// MutedConversation is a conversation a user muted, either
+// the one with WithEmail or the one of a room
+type MutedConversation struct {
+	WithEmail string `json:"withEmail,omitempty"`
+	RoomID    string `json:"roomID,omitempty"`
+}
+
+// CreateExport records a pending data export of the data of email. A user exports
+// one at a time, while an export is pending or running it is returned instead
+// and created is false
+func (d *Database) CreateExport(ctx context.Context, email, exportID string, at time.Time) (proto.DataExport, bool, error) {
+	type result struct {
+		export  proto.DataExport
+		created bool
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		for _, stored := range d.userExports[email] {
+			if stored.export.Status == ExportPending || stored.export.Status == ExportRunning {
+				r <- result{export: stored.export}
+				return
+			}
+		}
+
+		stored := &storedExport{export: proto.DataExport{
+			ExportID:  exportID,
+			Email:     email,
+			Status:    ExportPending,
+			CreatedAt: at,
+		}}
+		d.exports[exportID] = stored
+		d.userExports[email] = append(d.userExports[email], stored)
+		r <- result{export: stored.export, created: true}
+	}
+	select {
+	case res := <-r:
+		return res.export, res.created, nil
+	case <-ctx.Done():
+		return proto.DataExport{}, false, ctx.Err()
+	}
+}
+
+// GetExport returns a data export to the user it belongs to
+func (d *Database) GetExport(ctx context.Context, requester, exportID string) (proto.DataExport, error) {
+	export, _, err := d.exportArchive(ctx, requester, exportID, false)
+	return export, err
+}
+
+// ExportArchive returns a done data export along with the key of its archive
+// in the blob store to the user it belongs to
+func (d *Database) ExportArchive(ctx context.Context, requester, exportID string) (proto.DataExport, string, error) {
+	return d.exportArchive(ctx, requester, exportID, true)
+}
+
+func (d *Database) exportArchive(ctx context.Context, requester, exportID string, done bool) (proto.DataExport, string, error) {
+	type result struct {
+		export  proto.DataExport
+		blobKey string
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		// exports of other users are not found so their IDs can't be probed
+		stored, ok := d.exports[exportID]
+		if !ok || stored.export.Email != requester {
+			r <- result{err: errors.Wrap(ErrExportNotFound, exportID)}
+			return
+		}
+		if done && stored.export.Status != ExportDone {
+			r <- result{err: errors.Wrapf(ErrExportNotDone, "%s is %s", exportID, stored.export.Status)}
+			return
+		}
+		r <- result{export: stored.export, blobKey: stored.blobKey}
+	}
+	select {
+	case res := <-r:
+		return res.export, res.blobKey, res.err
+	case <-ctx.Done():
+		return proto.DataExport{}, "", ctx.Err()
+	}
+}
+
+// StartExport marks a data export as running with total records to write
+func (d *Database) StartExport(ctx context.Context, exportID string, total int) error {
+	return d.updateExport(ctx, exportID, func(stored *storedExport) {
+		stored.export.Status = ExportRunning
+		stored.export.TotalRecords = total
+	})
+}
+
+// ExportProgress records the number of records of a data export written so far
+func (d *Database) ExportProgress(ctx context.Context, exportID string, written int) error {
+	return d.updateExport(ctx, exportID, func(stored *storedExport) {
+		stored.export.WrittenRecords = written
+	})
+}
+
+// CompleteExport marks a data export as done, its archive is stored under blobKey
+func (d *Database) CompleteExport(ctx context.Context, exportID, blobKey string, size int64, at time.Time) error {
+	return d.updateExport(ctx, exportID, func(stored *storedExport) {
+		stored.blobKey = blobKey
+		stored.export.Status = ExportDone
+		stored.export.WrittenRecords = stored.export.TotalRecords
+		stored.export.Size = size
+		stored.export.CompletedAt = &at
+	})
+}
+
+// FailExport marks a data export as failed for reason
+func (d *Database) FailExport(ctx context.Context, exportID, reason string, at time.Time) error {
+	return d.updateExport(ctx, exportID, func(stored *storedExport) {
+		stored.export.Status = ExportFailed
+		stored.export.Failure = &reason
+		stored.export.CompletedAt = &at
+	})
+}
+
+// updateExport runs fn on a stored data export
+func (d *Database) updateExport(ctx context.Context, exportID string, fn func(*storedExport)) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		stored, ok := d.exports[exportID]
+		if !ok {
+			e <- errors.Wrap(ErrExportNotFound, exportID)
+			return
+		}
+		fn(stored)
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// UserData gathers everything stored about email
+func (d *Database) UserData(ctx context.Context, email string) (UserData, error) {
+	r := make(chan UserData, 1)
+	d.actionCh <- func() {
+		var data UserData
+
+		var stored []*storedMessage
+		for _, s := range d.messages {
+			msg := s.msg
+			if msg.FromEmail == email || (msg.RoomID == "" && msg.ToEmail == email) {
+				stored = append(stored, s)
+			}
+		}
+		sort.Slice(stored, func(i, j int) bool {
+			return stored[i].seq < stored[j].seq
+		})
+		for _, s := range stored {
+			data.Messages = append(data.Messages, s.msg)
+			if s.msg.FromEmail == email {
+				data.Revisions = append(data.Revisions, s.history...)
+			}
+		}
+
+		// reactions are kept on the messages they were added to
+		var reacted []*storedMessage
+		for _, s := range d.messages {
+			for _, reaction := range s.msg.Reactions {
+				if hasEmail(reaction.Emails, email) {
+					reacted = append(reacted, s)
+					break
+				}
+			}
+		}
+		sort.Slice(reacted, func(i, j int) bool {
+			return reacted[i].seq < reacted[j].seq
+		})
+		for _, s := range reacted {
+			for _, reaction := range s.msg.Reactions {
+				if hasEmail(reaction.Emails, email) {
+					data.Reactions = append(data.Reactions, UserReaction{
+						MessageUUID: s.msg.MessageUUID,
+						Emoji:       reaction.Emoji,
+					})
+				}
+			}
+		}
+
+		for _, a := range d.attachments {
+			if a.attachment.OwnerEmail == email {
+				data.Attachments = append(data.Attachments, a.attachment)
+			}
+		}
+		sort.Slice(data.Attachments, func(i, j int) bool {
+			return data.Attachments[i].CreatedAt.Before(data.Attachments[j].CreatedAt)
+		})
+
+		var roomIDs []string
+		for roomID := range d.memberRooms[email] {
+			roomIDs = append(roomIDs, roomID)
+		}
+		sort.Strings(roomIDs)
+		for _, roomID := range roomIDs {
+			if room, ok := d.rooms[roomID]; ok {
+				data.Rooms = append(data.Rooms, room.toRoom())
+			}
+		}
+
+		// the conversations of the user are the ones in their inbox and their rooms
+		var counterparts []string
+		for withEmail := range d.summaries[email] {
+			counterparts = append(counterparts, withEmail)
+		}
+		sort.Strings(counterparts)
+		for _, withEmail := range counterparts {
+			key := ConversationKey(email, withEmail)
+			if settings, ok := d.settings[key]; ok {
+				data.Settings = append(data.Settings, settings)
+			}
+			if d.muted[key][email] {
+				data.Muted = append(data.Muted, MutedConversation{WithEmail: withEmail})
+			}
+		}
+		for _, roomID := range roomIDs {
+			key := RoomKey(roomID)
+			if settings, ok := d.settings[key]; ok {
+				data.Settings = append(data.Settings, settings)
+			}
+			if d.muted[key][email] {
+				data.Muted = append(data.Muted, MutedConversation{RoomID: roomID})
+			}
+		}
+
+		for blocked, at := range d.blocks[email] {
+			data.Blocked = append(data.Blocked, proto.BlockedUser{Email: blocked, BlockedAt: at})
+		}
+		sort.Slice(data.Blocked, func(i, j int) bool {
+			return data.Blocked[i].Email < data.Blocked[j].Email
+		})
+
+		data.ReadCursors = d.readCursorsOf(email)
+
+		r <- data
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return UserData{}, ctx.Err()
+	}
+}
+
+// hasEmail reports whether emails holds email
+func hasEmail(emails []string, email string) bool {
+	for _, e := range emails {
+		if e == email {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// number of characters kept in a conversation summary preview
+	previewLength = 100
+)
+
+// summary returns the summary owner keeps of the conversation with withEmail,
+// creating it when missing. Must be called from within an action
+func (d *Database) summary(owner, withEmail string) *proto.ConversationSummary {
+	inbox, ok := d.summaries[owner]
+	if !ok {
+		inbox = make(map[string]*proto.ConversationSummary)
+		d.summaries[owner] = inbox
+	}
+	s, ok := inbox[withEmail]
+	if !ok {
+		s = &proto.ConversationSummary{WithEmail: withEmail}
+		inbox[withEmail] = s
+	}
+	return s
+}
+
+// messageSummaries returns the summaries both participants keep of the conversation
+// of a direct message, room messages have none. Must be called from within an action
+func (d *Database) messageSummaries(msg proto.ChatMessage) []*proto.ConversationSummary {
+	if msg.RoomID != "" {
+		return nil
+	}
+	return []*proto.ConversationSummary{
+		d.summary(msg.FromEmail, msg.ToEmail),
+		d.summary(msg.ToEmail, msg.FromEmail),
+	}
+}
+
+// updateSummaries records msg as the last activity of the conversation for
+// both participants. Must be called from within an action
+func (d *Database) updateSummaries(msg proto.ChatMessage) {
+	at := time.Now().UTC()
+	if msg.UpdatedAt != nil {
+		at = *msg.UpdatedAt
+	}
+
+	for _, s := range d.messageSummaries(msg) {
+		s.LastMessageUUID = msg.MessageUUID
+		s.LastMessageFrom = msg.FromEmail
+		s.LastMessagePreview = preview(msg.MessageText)
+		s.LastActivityAt = at
+	}
+}
+
+// ListConversations returns the conversation summaries of owner, most recent activity first,
+// unread counts come from the read cursors of owner
+func (d *Database) ListConversations(ctx context.Context, owner string) ([]proto.ConversationSummary, error) {
+	s := make(chan []proto.ConversationSummary, 1)
+	d.actionCh <- func() {
+		inbox := d.summaries[owner]
+		res := make([]proto.ConversationSummary, 0, len(inbox))
+		for withEmail, summary := range inbox {
+			conversation := *summary
+			conversation.UnreadCount = d.readCursor(owner, withEmail, "").unread
+			res = append(res, conversation)
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].LastActivityAt.After(res[j].LastActivityAt)
+		})
+		s <- res
+	}
+	select {
+	case res := <-s:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// preview shortens a message text to be shown in a conversation summary
+func preview(text string) string {
+	runes := []rune(text)
+	if len(runes) <= previewLength {
+		return text
+	}
+	return string(runes[:previewLength]) + "…"
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// @email at the start of the text or after a character that can't be part of an email
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@.+-])(@[\w.%+-]+@[\w-]+(?:\.[\w-]+)*\.[A-Za-z]{2,})\b`)
+)
+
+// parseMentions returns the @email mentions of text in the order they appear
+func parseMentions(text string) []*proto.Mention {
+	var mentions []*proto.Mention
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3]
+		mentions = append(mentions, &proto.Mention{
+			Email:  text[start+1 : end],
+			Offset: utf8.RuneCountInString(text[:start]),
+			Length: utf8.RuneCountInString(text[start:end]),
+		})
+	}
+	return mentions
+}
+
+// messageMentions returns the mentions of the text of msg that reach someone, leaving out
+// the sender, users that can't read the conversation and users that blocked the sender.
+// Must be called from within an action
+func (d *Database) messageMentions(msg proto.ChatMessage) []*proto.Mention {
+	var mentions []*proto.Mention
+	for _, mention := range parseMentions(msg.MessageText) {
+		if mention.Email == msg.FromEmail || !d.isParticipant(msg, mention.Email) || d.isBlocked(mention.Email, msg.FromEmail) {
+			continue
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
+
+// mentionedEmails returns the distinct users mentioned by msg
+func mentionedEmails(msg proto.ChatMessage) []string {
+	seen := make(map[string]bool)
+	var emails []string
+	for _, mention := range msg.Mentions {
+		if !seen[mention.Email] {
+			seen[mention.Email] = true
+			emails = append(emails, mention.Email)
+		}
+	}
+	return emails
+}
+
+// indexMentions adds a message to the mentions of the users it mentions.
+// Must be called from within an action
+func (d *Database) indexMentions(stored *storedMessage) {
+	for _, email := range mentionedEmails(stored.msg) {
+		d.mentioned[email] = insertStored(d.mentioned[email], stored)
+	}
+}
+
+// unindexMentions removes a message from the mentions of the users it mentions.
+// Must be called from within an action
+func (d *Database) unindexMentions(stored *storedMessage) {
+	for _, email := range mentionedEmails(stored.msg) {
+		d.mentioned[email] = removeStored(d.mentioned[email], stored)
+		if len(d.mentioned[email]) == 0 {
+			delete(d.mentioned, email)
+		}
+	}
+}
+
+// insertStored adds a message to a list ordered by arrival
+func insertStored(list []*storedMessage, stored *storedMessage) []*storedMessage {
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].seq >= stored.seq
+	})
+	if i < len(list) && list[i] == stored {
+		return list
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = stored
+	return list
+}
+
+// ListMentions returns up to limit messages mentioning viewer, newest first, starting right before
+// the before position (0 starts from the newest message). next is the position to pass to get the
+// following page, 0 when there are no more messages. Messages hidden by the viewer or of
+// conversations the viewer left are left out
+func (d *Database) ListMentions(ctx context.Context, viewer string, before uint64, limit int) ([]proto.ChatMessage, uint64, error) {
+	p := make(chan page, 1)
+	d.actionCh <- func() {
+		p <- filterPage(d.mentioned[viewer], before, limit, func(stored *storedMessage) bool {
+			return !stored.hiddenFor[viewer] && d.isParticipant(stored.msg, viewer)
+		})
+	}
+	select {
+	case res := <-p:
+		return res.messages, res.next, nil
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
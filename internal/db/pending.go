@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rumsrami/example-service/internal/event"
+)
+
+// pendingQueue holds the events published to a user that none of their streams got yet
+type pendingQueue struct {
+	// events in the order they were queued, oldest first
+	events []event.Event
+}
+
+// QueueEvent appends ev to the pending queue of email, it must have an ID.
+// The queue keeps up to limit events, the oldest ones are dropped past that
+func (d *Database) QueueEvent(ctx context.Context, email string, ev event.Event, limit int) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		queue, ok := d.pending[email]
+		if !ok {
+			queue = &pendingQueue{}
+			d.pending[email] = queue
+		}
+		queue.events = append(queue.events, ev)
+		if len(queue.events) > limit {
+			queue.events = append(queue.events[:0:0], queue.events[len(queue.events)-limit:]...)
+		}
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PendingEvents returns the events queued for email that none of their streams got yet, oldest first
+func (d *Database) PendingEvents(ctx context.Context, email string) ([]event.Event, error) {
+	r := make(chan []event.Event, 1)
+	d.actionCh <- func() {
+		queue, ok := d.pending[email]
+		if !ok {
+			r <- nil
+			return
+		}
+		r <- append([]event.Event(nil), queue.events...)
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AckEvent records that one of the streams of email got the event id, it leaves
+// the pending queue. The events queued before it stay until they are acked too,
+// streams get the events of their user and room topics in no particular order
+func (d *Database) AckEvent(ctx context.Context, email string, id string) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		queue, ok := d.pending[email]
+		if !ok {
+			e <- nil
+			return
+		}
+		for i, ev := range queue.events {
+			if ev.ID == id {
+				queue.events = append(queue.events[:i:i], queue.events[i+1:]...)
+				break
+			}
+		}
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dropPendingEvents removes the events about the given chat messages from every pending queue,
+// streams are not replayed what was purged. Must be called from within an action
+func (d *Database) dropPendingEvents(messageUUIDs map[string]bool) {
+	for _, queue := range d.pending {
+		kept := queue.events[:0]
+		for _, ev := range queue.events {
+			if !messageUUIDs[eventMessageUUID(ev)] {
+				kept = append(kept, ev)
+			}
+		}
+		for i := len(kept); i < len(queue.events); i++ {
+			queue.events[i] = event.Event{}
+		}
+		queue.events = kept
+	}
+}
+
+// eventMessageUUID returns the chat message an event carries or is about, empty for the other
+// events. Read cursors and expiries only refer to messages and stay queued
+func eventMessageUUID(ev event.Event) string {
+	var data struct {
+		MessageUUID string `json:"messageUUID"`
+		Reply       *struct {
+			MessageUUID string `json:"messageUUID"`
+		} `json:"reply"`
+	}
+	switch ev.Type {
+	case event.Message, event.Edited, event.Deleted, event.Mention, event.Receipt, event.Reaction, event.Reply:
+	default:
+		return ""
+	}
+	if json.Unmarshal(ev.Data, &data) != nil {
+		return ""
+	}
+	if data.Reply != nil {
+		return data.Reply.MessageUUID
+	}
+	return data.MessageUUID
+}
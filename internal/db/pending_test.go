@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// queue queues an event of type with data for email under id
+func queue(t *testing.T, d *Database, email, eventType string, data interface{}, id string) {
+	t.Helper()
+	ev, err := event.New(eventType, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.ID = id
+	err = d.QueueEvent(context.Background(), email, ev, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpiredMessagesLeaveThePendingQueues(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	kept := sendMessage(t, d, "a@x.com", "b@x.com", "", "sent before the ttl")
+	_, err := d.SetMessageTTL(ctx, "a@x.com", "b@x.com", "", time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := sendMessage(t, d, "a@x.com", "b@x.com", "", "expires")
+	reply, err := d.CreateChatMessage(ctx, "b@x.com", proto.ChatMessage{
+		FromEmail:         "b@x.com",
+		ToEmail:           "a@x.com",
+		MessageUUID:       "reply-1",
+		ParentMessageUUID: parent.MessageUUID,
+		MessageText:       "expires along",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue(t, d, "b@x.com", event.Message, kept, "kept")
+	queue(t, d, "b@x.com", event.Message, parent, "parent")
+	queue(t, d, "a@x.com", event.Reply, proto.ThreadReply{Reply: &reply, ReplyCount: 1}, "reply")
+	queue(t, d, "a@x.com", event.Receipt, proto.ChatReceipt{MessageUUID: parent.MessageUUID, Delivered: true}, "receipt")
+	queue(t, d, "a@x.com", event.ReadCursor, proto.ReadCursor{Email: "b@x.com", MessageUUID: parent.MessageUUID}, "cursor")
+
+	expired, err := d.ExpireMessages(ctx, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 2 {
+		t.Fatalf("%d messages expired, want 2", len(expired))
+	}
+
+	for email, want := range map[string][]string{"b@x.com": {"kept"}, "a@x.com": {"cursor"}} {
+		events, err := d.PendingEvents(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, ev := range events {
+			ids = append(ids, ev.ID)
+		}
+		if len(ids) != len(want) || ids[0] != want[0] {
+			t.Fatalf("pending events of %s %v, want %v", email, ids, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// AddReaction adds the reaction of email to a chat message, adding
+// the same emoji twice is a no-op. changed reports whether the reactions changed
+func (d *Database) AddReaction(ctx context.Context, email, messageUUID, emoji string) (proto.ChatMessage, bool, error) {
+	return d.react(ctx, email, messageUUID, emoji, true)
+}
+
+// RemoveReaction removes the reaction of email from a chat message, removing
+// a missing reaction is a no-op. changed reports whether the reactions changed
+func (d *Database) RemoveReaction(ctx context.Context, email, messageUUID, emoji string) (proto.ChatMessage, bool, error) {
+	return d.react(ctx, email, messageUUID, emoji, false)
+}
+
+func (d *Database) react(ctx context.Context, email, messageUUID, emoji string, add bool) (proto.ChatMessage, bool, error) {
+	type result struct {
+		msg     proto.ChatMessage
+		changed bool
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.messages[messageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, messageUUID)}
+			return
+		}
+		if !d.isParticipant(stored.msg, email) {
+			r <- result{err: errors.Wrap(ErrNotParticipant, messageUUID)}
+			return
+		}
+		if stored.msg.Deleted {
+			r <- result{err: errors.Wrap(ErrMessageDeleted, messageUUID)}
+			return
+		}
+
+		reactions, changed := withReaction(stored.msg.Reactions, email, emoji, add)
+		stored.msg.Reactions = reactions
+		r <- result{msg: stored.msg, changed: changed}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.changed, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, false, ctx.Err()
+	}
+}
+
+// withReaction returns reactions with the reaction of email added or removed.
+// Stored messages are handed out by value so reactions are never changed in
+// place, a new slice is returned instead whenever something changes
+func withReaction(reactions []*proto.Reaction, email, emoji string, add bool) ([]*proto.Reaction, bool) {
+	res := make([]*proto.Reaction, 0, len(reactions)+1)
+	found := false
+	for _, reaction := range reactions {
+		if reaction.Emoji != emoji {
+			res = append(res, reaction)
+			continue
+		}
+		found = true
+
+		emails := make([]string, 0, len(reaction.Emails)+1)
+		for _, e := range reaction.Emails {
+			if e != email {
+				emails = append(emails, e)
+			}
+		}
+		reacted := len(emails) < len(reaction.Emails)
+		if reacted == add {
+			return reactions, false
+		}
+		if add {
+			emails = append(emails, email)
+		}
+		if len(emails) > 0 {
+			res = append(res, &proto.Reaction{Emoji: emoji, Count: len(emails), Emails: emails})
+		}
+	}
+
+	if !found {
+		if !add {
+			return reactions, false
+		}
+		res = append(res, &proto.Reaction{Emoji: emoji, Count: 1, Emails: []string{email}})
+	}
+	if len(res) == 0 {
+		res = nil
+	}
+	return res, true
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrMessageNotFound is returned when no chat message has the requested MessageUUID
+	ErrMessageNotFound = errors.New("chat message not found")
+	// ErrNotRecipient is returned when a receipt is sent by someone else than the recipient
+	ErrNotRecipient = errors.New("not the recipient of the chat message")
+)
+
+// MarkDelivered flags the messages sent to recipient as delivered
+// and returns the ones whose state changed
+func (d *Database) MarkDelivered(ctx context.Context, recipient string, messageUUIDs []string) ([]proto.ChatMessage, error) {
+	type result struct {
+		changed []proto.ChatMessage
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		// validate all the messages before changing any of them
+		for _, id := range messageUUIDs {
+			stored, ok := d.messages[id]
+			if !ok {
+				r <- result{err: errors.Wrap(ErrMessageNotFound, id)}
+				return
+			}
+			if stored.msg.ToEmail != recipient {
+				r <- result{err: errors.Wrap(ErrNotRecipient, id)}
+				return
+			}
+		}
+
+		var res result
+		for _, id := range messageUUIDs {
+			stored := d.messages[id]
+			if !stored.msg.Delivered {
+				stored.msg.Delivered = true
+				res.changed = append(res.changed, stored.msg)
+			}
+		}
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res.changed, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
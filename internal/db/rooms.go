@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrRoomNotFound is returned when no room has the requested RoomID
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrRoomExists is returned when a room with the same RoomID is already stored
+	ErrRoomExists = errors.New("room already exists")
+	// ErrNotMember is returned when a room is used by someone outside of it
+	ErrNotMember = errors.New("not a member of the room")
+)
+
+// storedRoom is a group conversation and the set of its members
+type storedRoom struct {
+	room    proto.Room
+	members map[string]bool
+}
+
+// toRoom returns the room along with its members sorted by email
+func (r *storedRoom) toRoom() proto.Room {
+	room := r.room
+	room.Members = make([]string, 0, len(r.members))
+	for email := range r.members {
+		room.Members = append(room.Members, email)
+	}
+	sort.Strings(room.Members)
+	return room
+}
+
+// CreateRoom stores a new room keyed by its RoomID along with its members
+func (d *Database) CreateRoom(ctx context.Context, room proto.Room) (proto.Room, error) {
+	type result struct {
+		room proto.Room
+		err  error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		if _, ok := d.rooms[room.RoomID]; ok {
+			r <- result{err: errors.Wrap(ErrRoomExists, room.RoomID)}
+			return
+		}
+		stored := &storedRoom{room: room, members: make(map[string]bool)}
+		stored.room.Members = nil
+		d.rooms[room.RoomID] = stored
+		for _, email := range room.Members {
+			d.addMember(stored, email)
+		}
+		r <- result{room: stored.toRoom()}
+	}
+	select {
+	case res := <-r:
+		return res.room, res.err
+	case <-ctx.Done():
+		return proto.Room{}, ctx.Err()
+	}
+}
+
+// AddMember adds email to a room, requester must be a member of the room
+func (d *Database) AddMember(ctx context.Context, requester, roomID, email string, at time.Time) (proto.Room, error) {
+	return d.withRoom(ctx, roomID, func(stored *storedRoom) error {
+		if !stored.members[requester] {
+			return errors.Wrap(ErrNotMember, roomID)
+		}
+		d.addMember(stored, email)
+		stored.room.UpdatedAt = at
+		return nil
+	})
+}
+
+// RemoveMember removes email from a room, members can leave a room
+// and moderators or the room creator can remove anyone
+func (d *Database) RemoveMember(ctx context.Context, requester, roomID, email string, moderator bool, at time.Time) (proto.Room, error) {
+	return d.withRoom(ctx, roomID, func(stored *storedRoom) error {
+		if !moderator && requester != email && requester != stored.room.CreatedBy {
+			return errors.Wrap(ErrNotMember, roomID)
+		}
+		if !stored.members[email] {
+			return errors.Wrapf(ErrNotMember, "%s in %s", email, roomID)
+		}
+		delete(stored.members, email)
+		delete(d.memberRooms[email], roomID)
+		stored.room.UpdatedAt = at
+		return nil
+	})
+}
+
+// withRoom applies fn to a room and returns the room as fn left it
+func (d *Database) withRoom(ctx context.Context, roomID string, fn func(*storedRoom) error) (proto.Room, error) {
+	type result struct {
+		room proto.Room
+		err  error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.rooms[roomID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrRoomNotFound, roomID)}
+			return
+		}
+		err := fn(stored)
+		if err != nil {
+			r <- result{err: err}
+			return
+		}
+		r <- result{room: stored.toRoom()}
+	}
+	select {
+	case res := <-r:
+		return res.room, res.err
+	case <-ctx.Done():
+		return proto.Room{}, ctx.Err()
+	}
+}
+
+// RoomMembers returns the members of a room sorted by email
+func (d *Database) RoomMembers(ctx context.Context, roomID string) ([]string, error) {
+	room, err := d.withRoom(ctx, roomID, func(*storedRoom) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return room.Members, nil
+}
+
+// addMember adds email to a room. Must be called from within an action
+func (d *Database) addMember(stored *storedRoom, email string) {
+	stored.members[email] = true
+	rooms, ok := d.memberRooms[email]
+	if !ok {
+		rooms = make(map[string]bool)
+		d.memberRooms[email] = rooms
+	}
+	rooms[stored.room.RoomID] = true
+}
+
+// ListRooms returns the rooms email is a member of, most recently updated first
+func (d *Database) ListRooms(ctx context.Context, email string) ([]proto.Room, error) {
+	r := make(chan []proto.Room, 1)
+	d.actionCh <- func() {
+		res := make([]proto.Room, 0, len(d.memberRooms[email]))
+		for roomID := range d.memberRooms[email] {
+			res = append(res, d.rooms[roomID].toRoom())
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].UpdatedAt.After(res[j].UpdatedAt)
+		})
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ListRoomMessages returns up to limit messages of a room to one of its members, newest first,
+// paging works the same way as ListConversation
+func (d *Database) ListRoomMessages(ctx context.Context, member, roomID string, before uint64, limit int) ([]proto.ChatMessage, uint64, error) {
+	type result struct {
+		page page
+		err  error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		stored, ok := d.rooms[roomID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrRoomNotFound, roomID)}
+			return
+		}
+		if !stored.members[member] {
+			r <- result{err: errors.Wrap(ErrNotMember, roomID)}
+			return
+		}
+		r <- result{page: listPage(d.conversations[RoomKey(roomID)], member, before, limit)}
+	}
+	select {
+	case res := <-r:
+		return res.page.messages, res.page.next, res.err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/search"
+)
+
+// SearchMessages returns up to limit messages of the conversations viewer takes part in
+// matching every search term, best matches first. withEmail restricts the search to the
+// conversation with that user, after and before to the messages sent in between unless they are zero
+func (d *Database) SearchMessages(ctx context.Context, viewer string, terms []string, withEmail string, after, before time.Time, limit int) ([]proto.SearchResult, error) {
+	r := make(chan []proto.SearchResult, 1)
+	d.actionCh <- func() {
+		type match struct {
+			stored *storedMessage
+			score  float64
+		}
+
+		var matches []match
+		for _, key := range d.searchKeys(viewer, withEmail) {
+			index, ok := d.indexes[key]
+			if !ok {
+				continue
+			}
+			for _, hit := range index.Search(terms) {
+				stored, ok := d.messages[hit.ID]
+				if !ok || stored.hiddenFor[viewer] {
+					continue
+				}
+				if !after.IsZero() && !stored.createdAt.After(after) {
+					continue
+				}
+				if !before.IsZero() && !stored.createdAt.Before(before) {
+					continue
+				}
+				matches = append(matches, match{stored: stored, score: hit.Score})
+			}
+		}
+
+		// newer messages first among equally good matches
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].score != matches[j].score {
+				return matches[i].score > matches[j].score
+			}
+			return matches[i].stored.seq > matches[j].stored.seq
+		})
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+
+		res := make([]proto.SearchResult, len(matches))
+		for i, m := range matches {
+			msg := m.stored.msg
+			res[i] = proto.SearchResult{Message: &msg, Score: m.score}
+		}
+		r <- res
+	}
+	select {
+	case res := <-r:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// searchKeys returns the keys of the conversations viewer searches, the one with withEmail
+// when it is set and all of theirs otherwise. Must be called from within an action
+func (d *Database) searchKeys(viewer, withEmail string) []string {
+	if withEmail != "" {
+		return []string{ConversationKey(viewer, withEmail)}
+	}
+
+	keys := make([]string, 0, len(d.summaries[viewer])+len(d.memberRooms[viewer]))
+	for email := range d.summaries[viewer] {
+		keys = append(keys, ConversationKey(viewer, email))
+	}
+	for roomID := range d.memberRooms[viewer] {
+		keys = append(keys, RoomKey(roomID))
+	}
+	return keys
+}
+
+// indexMessage indexes the text of msg in the index of its conversation, replacing
+// what was indexed for it before. Must be called from within an action
+func (d *Database) indexMessage(msg proto.ChatMessage) {
+	key := messageKey(msg)
+	index, ok := d.indexes[key]
+	if !ok {
+		index = search.NewIndex()
+		d.indexes[key] = index
+	}
+	index.Add(msg.MessageUUID, msg.MessageText)
+}
+
+// unindexMessage drops msg from the index of its conversation. Must be called from within an action
+func (d *Database) unindexMessage(msg proto.ChatMessage) {
+	key := messageKey(msg)
+	index, ok := d.indexes[key]
+	if !ok {
+		return
+	}
+	index.Remove(msg.MessageUUID)
+	if index.Len() == 0 {
+		delete(d.indexes, key)
+	}
+}
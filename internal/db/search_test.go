@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/search"
+)
+
+func searchTexts(t *testing.T, d *Database, viewer, query, withEmail string) []string {
+	t.Helper()
+	res, err := d.SearchMessages(context.Background(), viewer, search.Terms(query), withEmail, time.Time{}, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := make([]string, len(res))
+	for i, r := range res {
+		texts[i] = r.Message.MessageText
+	}
+	return texts
+}
+
+func TestSearchOnlyGoesThroughTheConversationsOfTheViewer(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+
+	sendMessage(t, d, "a@x.com", "b@x.com", "", "delivery to depot")
+	sendMessage(t, d, "a@x.com", "c@x.com", "", "delivery secret")
+	_, err := d.CreateRoom(ctx, proto.Room{RoomID: "room", Members: []string{"a@x.com", "b@x.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendMessage(t, d, "a@x.com", "", "room", "delivery for the room")
+
+	if texts := searchTexts(t, d, "a@x.com", "deliv", ""); len(texts) != 3 {
+		t.Fatalf("a found %v, want the 3 messages", texts)
+	}
+	if texts := searchTexts(t, d, "b@x.com", "deliv", ""); len(texts) != 2 {
+		t.Fatalf("b found %v, want the direct and the room message", texts)
+	}
+	if texts := searchTexts(t, d, "x@x.com", "deliv", ""); len(texts) != 0 {
+		t.Fatalf("x found %v in conversations of others", texts)
+	}
+	texts := searchTexts(t, d, "a@x.com", "delivery", "c@x.com")
+	if len(texts) != 1 || texts[0] != "delivery secret" {
+		t.Fatalf("a found %v with c, want delivery secret", texts)
+	}
+}
+
+func TestSearchLeavesOutHiddenAndDeletedMessages(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+
+	hidden := sendMessage(t, d, "a@x.com", "b@x.com", "", "hidden depot")
+	deleted := sendMessage(t, d, "a@x.com", "b@x.com", "", "deleted depot")
+	sendMessage(t, d, "a@x.com", "b@x.com", "", "kept depot")
+
+	err := d.HideChatMessage(ctx, "b@x.com", hidden.MessageUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.DeleteChatMessage(ctx, "a@x.com", deleted.MessageUUID, time.Time{}, false, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if texts := searchTexts(t, d, "b@x.com", "depot", ""); len(texts) != 1 || texts[0] != "kept depot" {
+		t.Fatalf("b found %v, want kept depot", texts)
+	}
+	if texts := searchTexts(t, d, "a@x.com", "depot", ""); len(texts) != 2 {
+		t.Fatalf("a found %v, want hidden and kept depot", texts)
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrSubmissionInProgress is returned when a retried chat message
+	// is still being published by an earlier attempt
+	ErrSubmissionInProgress = errors.New("chat message submission in progress")
+)
+
+// submission is a chat message sent with a client idempotency key
+// along with the recipients it was published to so far
+type submission struct {
+	key string
+	msg proto.ChatMessage
+	at  time.Time
+	// recipients the message still has to be published to
+	pending []string
+	// an attempt is publishing the message until then, a retry
+	// takes over once the lease ran out without news from it
+	publishingUntil time.Time
+}
+
+// submissionKey returns the key of a client submission,
+// idempotency keys are only unique per sender
+func submissionKey(sender, idempotencyKey string) string {
+	return strings.Join([]string{sender, idempotencyKey}, "#")
+}
+
+// SubmitChatMessage stores msg unless the authenticated sender already submitted it with the same
+// idempotency key after notBefore, in which case the stored message is returned instead.
+// pending holds the recipients the message still has to be published to, the caller
+// is then expected to publish it within lease and report back with PublishedChatMessage.
+// The message is stored even when ctx is done before the result comes back,
+// a retry then takes over the publishing once the lease ran out
+func (d *Database) SubmitChatMessage(ctx context.Context, sender, idempotencyKey string, msg proto.ChatMessage, recipients []string, notBefore time.Time, lease time.Duration) (proto.ChatMessage, []string, error) {
+	type result struct {
+		msg     proto.ChatMessage
+		pending []string
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		d.expireSubmissions(notBefore)
+
+		now := time.Now().UTC()
+		key := submissionKey(sender, idempotencyKey)
+		if sub, ok := d.submissions[key]; ok {
+			if now.Before(sub.publishingUntil) {
+				r <- result{err: errors.Wrap(ErrSubmissionInProgress, idempotencyKey)}
+				return
+			}
+			sub.publishingUntil = time.Time{}
+			if len(sub.pending) > 0 {
+				sub.publishingUntil = now.Add(lease)
+			}
+			r <- result{msg: sub.msg, pending: append([]string(nil), sub.pending...)}
+			return
+		}
+
+		msg, err := d.createMessage(sender, msg)
+		if err != nil {
+			r <- result{err: err}
+			return
+		}
+		sub := &submission{
+			key:     key,
+			msg:     msg,
+			at:      now,
+			pending: append([]string(nil), recipients...),
+		}
+		if len(recipients) > 0 {
+			sub.publishingUntil = now.Add(lease)
+		}
+		d.submissions[key] = sub
+		d.submissionOrder = append(d.submissionOrder, sub)
+		r <- result{msg: msg, pending: append([]string(nil), recipients...)}
+	}
+	select {
+	case res := <-r:
+		return res.msg, res.pending, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, nil, ctx.Err()
+	}
+}
+
+// PublishedChatMessage records the recipients a submitted message was published to,
+// the others are handed to the next retry
+func (d *Database) PublishedChatMessage(ctx context.Context, sender, idempotencyKey string, published []string) error {
+	e := make(chan error, 1)
+	d.actionCh <- func() {
+		sub, ok := d.submissions[submissionKey(sender, idempotencyKey)]
+		if !ok {
+			e <- nil
+			return
+		}
+		done := make(map[string]bool, len(published))
+		for _, email := range published {
+			done[email] = true
+		}
+		pending := sub.pending[:0]
+		for _, email := range sub.pending {
+			if !done[email] {
+				pending = append(pending, email)
+			}
+		}
+		sub.pending = pending
+		sub.publishingUntil = time.Time{}
+		e <- nil
+	}
+	select {
+	case err := <-e:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expireSubmissions forgets the submissions that came in before notBefore,
+// they are ordered by arrival so only the expired ones are visited.
+// Must be called from within an action
+func (d *Database) expireSubmissions(notBefore time.Time) {
+	i := 0
+	for ; i < len(d.submissionOrder) && d.submissionOrder[i].at.Before(notBefore); i++ {
+		delete(d.submissions, d.submissionOrder[i].key)
+		d.submissionOrder[i] = nil
+	}
+	d.submissionOrder = d.submissionOrder[i:]
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+func TestSubmissionsArePublishedUnderALease(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	notBefore := time.Now().Add(-time.Hour)
+	msg := proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "stored", MessageText: "hi"}
+	recipients := []string{"b@x.com", "a@x.com"}
+
+	stored, pending, err := d.SubmitChatMessage(ctx, "a@x.com", "key", msg, recipients, notBefore, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pending, ",") != "b@x.com,a@x.com" {
+		t.Fatalf("pending %v, want every recipient", pending)
+	}
+
+	// the first attempt is still publishing
+	_, _, err = d.SubmitChatMessage(ctx, "a@x.com", "key", msg, recipients, notBefore, time.Hour)
+	if errors.Cause(err) != ErrSubmissionInProgress {
+		t.Fatalf("err = %v, want %v", err, ErrSubmissionInProgress)
+	}
+	// idempotency keys are only unique per sender
+	_, _, err = d.SubmitChatMessage(ctx, "c@x.com", "key", proto.ChatMessage{FromEmail: "c@x.com", ToEmail: "b@x.com", MessageUUID: "other", MessageText: "hi"}, recipients, notBefore, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.PublishedChatMessage(ctx, "a@x.com", "key", []string{"b@x.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, pending, err := d.SubmitChatMessage(ctx, "a@x.com", "key", msg, recipients, notBefore, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.MessageUUID != stored.MessageUUID || strings.Join(pending, ",") != "a@x.com" {
+		t.Fatalf("retry got %s for %v, want %s for a@x.com", retried.MessageUUID, pending, stored.MessageUUID)
+	}
+
+	// the retry went away without reporting back, the next one takes over once its lease ran out
+	time.Sleep(time.Millisecond)
+	_, pending, err = d.SubmitChatMessage(ctx, "a@x.com", "key", msg, recipients, notBefore, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pending, ",") != "a@x.com" {
+		t.Fatalf("pending %v, want a@x.com", pending)
+	}
+
+	err = d.PublishedChatMessage(ctx, "a@x.com", "key", pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pending, err = d.SubmitChatMessage(ctx, "a@x.com", "key", msg, recipients, notBefore, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("pending %v once published to everyone", pending)
+	}
+}
+
+func TestSubmissionsExpireAfterTheDedupeWindow(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	msg := proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "first", MessageText: "hi"}
+
+	_, _, err := d.SubmitChatMessage(ctx, "a@x.com", "key", msg, nil, time.Now().Add(-time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.MessageUUID = "second"
+	stored, _, err := d.SubmitChatMessage(ctx, "a@x.com", "key", msg, nil, time.Now().Add(time.Second), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.MessageUUID != "second" {
+		t.Fatalf("got %s, want the message submitted again", stored.MessageUUID)
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+var (
+	// ErrNestedReply is returned when replying to a thread reply,
+	// threads are only one level deep
+	ErrNestedReply = errors.New("thread replies cannot be replied to")
+)
+
+// checkParent makes sure the reply msg can be added to the thread of its parent.
+// Must be called from within an action
+func (d *Database) checkParent(msg proto.ChatMessage) error {
+	parent, ok := d.messages[msg.ParentMessageUUID]
+	// a parent from another conversation is reported as missing
+	if !ok || messageKey(parent.msg) != messageKey(msg) {
+		return errors.Wrap(ErrMessageNotFound, msg.ParentMessageUUID)
+	}
+	if parent.msg.ParentMessageUUID != "" {
+		return errors.Wrap(ErrNestedReply, msg.ParentMessageUUID)
+	}
+	if parent.msg.Deleted {
+		return errors.Wrap(ErrMessageDeleted, msg.ParentMessageUUID)
+	}
+	return nil
+}
+
+// addReply appends a reply to the thread of its parent and updates the parent counters.
+// Must be called from within an action
+func (d *Database) addReply(reply *storedMessage) {
+	parentUUID := reply.msg.ParentMessageUUID
+	d.threads[parentUUID] = append(d.threads[parentUUID], reply)
+
+	parent := d.messages[parentUUID]
+	parent.msg.ReplyCount++
+	at := reply.createdAt
+	parent.msg.LastReplyAt = &at
+}
+
+// ListThread returns the parent message of a thread along with its replies,
+// oldest first, leaving out the replies hidden by the viewer
+func (d *Database) ListThread(ctx context.Context, viewer, parentMessageUUID string) (proto.ChatMessage, []proto.ChatMessage, error) {
+	type result struct {
+		parent  proto.ChatMessage
+		replies []proto.ChatMessage
+		err     error
+	}
+	r := make(chan result, 1)
+	d.actionCh <- func() {
+		parent, ok := d.messages[parentMessageUUID]
+		if !ok {
+			r <- result{err: errors.Wrap(ErrMessageNotFound, parentMessageUUID)}
+			return
+		}
+		if !d.isParticipant(parent.msg, viewer) {
+			r <- result{err: errors.Wrap(ErrNotParticipant, parentMessageUUID)}
+			return
+		}
+
+		thread := d.threads[parentMessageUUID]
+		replies := make([]proto.ChatMessage, 0, len(thread))
+		for _, stored := range thread {
+			if stored.hiddenFor[viewer] {
+				continue
+			}
+			replies = append(replies, stored.msg)
+		}
+		r <- result{parent: parent.msg, replies: replies}
+	}
+	select {
+	case res := <-r:
+		return res.parent, res.replies, res.err
+	case <-ctx.Done():
+		return proto.ChatMessage{}, nil, ctx.Err()
+	}
+}
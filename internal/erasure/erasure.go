@@ -0,0 +1,455 @@
+package erasure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/platform/blob"
+	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/schedule"
+)
+
+const (
+	// journals of the erasures in progress
+	pendingDir = "pending"
+	// completion records of the finished erasures, kept for audits
+	doneDir = "done"
+	// journals being written before they are moved in place
+	tmpDir = "tmp"
+	// time allowed to resume an erasure interrupted by a restart
+	resumeTimeout = time.Minute
+	// time allowed to record the deletion of a blob even if the erasure went away in the meantime
+	deletedTimeout = 5 * time.Second
+	// Errors
+	errErasure = "user erasure error"
+)
+
+// Steps of an erasure in the order they run, each of them can run again safely
+const (
+	// the scheduled messages of the user are cancelled
+	StepScheduled = "scheduled"
+	// the database forgets the user
+	StepData = "data"
+	// the blobs only the user referred to are deleted
+	StepBlobs = "blobs"
+	// every instance is told, they erase the user from their own stores and close their streams
+	StepNotify = "notify"
+)
+
+var steps = []string{StepScheduled, StepData, StepBlobs, StepNotify}
+
+// Statuses of an erasure
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+)
+
+// Topic is the topic erasures are announced on to every instance
+const Topic = "users.erasure"
+
+// journal is the state of an erasure kept on disk, it is written after every step
+// so that an erasure interrupted halfway resumes from the last completed one
+type journal struct {
+	Erasure proto.UserErasure `json:"erasure"`
+	// blobs the data step released and the blobs step has to delete
+	BlobKeys []string `json:"blobKeys,omitempty"`
+}
+
+// Eraser erases users across the database, the blob store and the scheduled messages
+// and tells every instance through the broker, which erase the user from their own stores
+// too as each of them keeps its own database. Erasures are journaled under a directory,
+// the journals left by an instance that died are resumed when it starts again and
+// the completion records of the finished ones are kept there for audits
+type Eraser struct {
+	dir       string
+	db        *db.Database
+	blobs     blob.Store
+	scheduler *schedule.Scheduler
+	mb        broker.MessageBroker
+	notify    func(proto.UserErasure) error
+	onErased  func(proto.UserErasure)
+
+	// erasures run one at a time
+	mu     sync.Mutex
+	quitCh chan chan struct{}
+}
+
+// NewEraser returns an eraser keeping its journals under dir
+func NewEraser(dir string, database *db.Database, blobs blob.Store, scheduler *schedule.Scheduler, mb broker.MessageBroker) (*Eraser, error) {
+	for _, sub := range []string{pendingDir, doneDir, tmpDir} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0700)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create erasure directory")
+		}
+	}
+
+	return &Eraser{
+		dir:       dir,
+		db:        database,
+		blobs:     blobs,
+		scheduler: scheduler,
+		mb:        mb,
+		notify:    func(proto.UserErasure) error { return nil },
+		onErased:  func(proto.UserErasure) {},
+		quitCh:    make(chan chan struct{}),
+	}, nil
+}
+
+// OnNotify sets the function telling the clients of the erased user, it runs in the
+// notify step before the erasure is announced. It must be set before Run is called
+func (e *Eraser) OnNotify(fn func(proto.UserErasure) error) {
+	e.notify = fn
+}
+
+// OnErased sets the function called on every instance when an erasure is announced,
+// once the user is erased from the stores of the instance. It must be set before Run is called
+func (e *Eraser) OnErased(fn func(proto.UserErasure)) {
+	e.onErased = fn
+}
+
+// Run follows the erasures announced by every instance and resumes the
+// interrupted ones of this directory until Stop is called
+func (e *Eraser) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	msgCh := make(chan []byte, 512)
+	errCh := make(chan error, 1)
+	go e.mb.Sub(ctx, Topic, msgCh, errCh)
+
+	go e.resume(ctx)
+
+	for {
+		select {
+		case err := <-errCh:
+			return errors.Wrap(err, errErasure)
+		case m, open := <-msgCh:
+			if !open {
+				return errors.New(errErasure)
+			}
+			e.receive(m)
+		case q := <-e.quitCh:
+			close(q)
+			return nil
+		}
+	}
+}
+
+// Stop stops following erasures
+func (e *Eraser) Stop() {
+	q := make(chan struct{})
+	e.quitCh <- q
+	// This blocks until Run() closes q and returns
+	<-q
+}
+
+// Erase erases email on behalf of requestedBy and returns the completion record.
+// An unfinished erasure of email is resumed instead of starting another one,
+// when an error is returned calling Erase again picks up where it stopped
+func (e *Eraser) Erase(ctx context.Context, email, requestedBy string) (proto.UserErasure, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	journals, err := e.pending()
+	if err != nil {
+		return proto.UserErasure{}, err
+	}
+	for _, j := range journals {
+		if j.Erasure.Email == email {
+			return e.run(ctx, j)
+		}
+	}
+
+	erasureID, err := newID()
+	if err != nil {
+		return proto.UserErasure{}, err
+	}
+	j := &journal{Erasure: proto.UserErasure{
+		ErasureID:      erasureID,
+		Email:          email,
+		RequestedBy:    requestedBy,
+		Status:         StatusRunning,
+		CompletedSteps: []string{},
+		StartedAt:      time.Now().UTC(),
+	}}
+	err = e.write(pendingDir, j)
+	if err != nil {
+		return proto.UserErasure{}, err
+	}
+	return e.run(ctx, j)
+}
+
+// resume finishes the erasures an earlier run left halfway
+func (e *Eraser) resume(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	journals, err := e.pending()
+	if err != nil {
+		log.Printf("%v : %v", errErasure, err)
+		return
+	}
+	for _, j := range journals {
+		rctx, cancel := context.WithTimeout(ctx, resumeTimeout)
+		_, err := e.run(rctx, j)
+		cancel()
+		if err != nil {
+			log.Printf("%v : cannot resume %s : %v", errErasure, j.Erasure.ErasureID, err)
+		}
+	}
+}
+
+// run runs the steps of an erasure that are not completed yet,
+// the erasure is moved to the completion records once done
+func (e *Eraser) run(ctx context.Context, j *journal) (proto.UserErasure, error) {
+	for _, step := range steps {
+		if completed(j.Erasure, step) {
+			continue
+		}
+		err := e.step(ctx, j, step)
+		if err != nil {
+			return j.Erasure, errors.Wrapf(err, "%s step of %s", step, j.Erasure.ErasureID)
+		}
+		j.Erasure.CompletedSteps = append(j.Erasure.CompletedSteps, step)
+		err = e.write(pendingDir, j)
+		if err != nil {
+			return j.Erasure, err
+		}
+	}
+
+	completedAt := time.Now().UTC()
+	j.Erasure.Status = StatusDone
+	j.Erasure.CompletedAt = &completedAt
+	err := e.write(doneDir, j)
+	if err != nil {
+		return j.Erasure, err
+	}
+	err = os.Remove(filepath.Join(e.dir, pendingDir, j.Erasure.ErasureID+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return j.Erasure, errors.Wrap(err, "cannot remove erasure journal")
+	}
+
+	log.Printf("user erasure %s of %s requested by %s completed : %d messages, %d attachments, %d blobs",
+		j.Erasure.ErasureID, j.Erasure.Email, j.Erasure.RequestedBy,
+		j.Erasure.MessagesErased, j.Erasure.AttachmentsErased, j.Erasure.BlobsDeleted)
+	return j.Erasure, nil
+}
+
+// step runs one step of an erasure
+func (e *Eraser) step(ctx context.Context, j *journal, step string) error {
+	email := j.Erasure.Email
+	switch step {
+	case StepScheduled:
+		cancelled, err := e.cancelScheduled(email)
+		j.Erasure.MessagesErased += cancelled
+		if err != nil {
+			return err
+		}
+	case StepData:
+		// the released blobs are only known from here, the journal
+		// is written with them before the step counts as completed
+		erased, err := e.db.EraseUser(ctx, email, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		j.Erasure.MessagesErased += erased.Messages
+		j.Erasure.AttachmentsErased += erased.Attachments
+		j.BlobKeys = erased.BlobKeys
+	case StepBlobs:
+		for len(j.BlobKeys) > 0 {
+			deleted, err := e.deleteBlob(ctx, j.BlobKeys[0])
+			if err != nil {
+				return err
+			}
+			if deleted {
+				j.Erasure.BlobsDeleted++
+			}
+			j.BlobKeys = j.BlobKeys[1:]
+		}
+	case StepNotify:
+		err := e.notify(j.Erasure)
+		if err != nil {
+			return err
+		}
+		b, err := event.Marshal(event.Erased, j.Erasure)
+		if err != nil {
+			return err
+		}
+		return e.mb.Pub(Topic, b)
+	}
+	return nil
+}
+
+// deleteBlob deletes a released blob unless something refers to it again,
+// blobs are addressed by content so another user may have stored the same one since
+func (e *Eraser) deleteBlob(ctx context.Context, key string) (bool, error) {
+	claimed, err := e.db.ClaimBlob(ctx, key)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	err = e.blobs.Delete(ctx, key)
+	dctx, cancel := context.WithTimeout(context.Background(), deletedTimeout)
+	defer cancel()
+	derr := e.db.DeletedBlob(dctx, key, time.Now().UTC())
+	if errors.Cause(err) == blob.ErrNotFound {
+		return false, derr
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, derr
+}
+
+// cancelScheduled cancels the scheduled messages of email and returns how many it cancelled
+func (e *Eraser) cancelScheduled(email string) (int, error) {
+	scheduled, err := e.scheduler.List(email)
+	if err != nil {
+		return 0, err
+	}
+	cancelled := 0
+	for _, msg := range scheduled {
+		_, err = e.scheduler.Cancel(email, msg.MessageUUID)
+		// sent or cancelled in the meantime
+		if errors.Cause(err) == schedule.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+// eraseLocally erases an announced user from the stores of this instance, every instance
+// keeps its own database so the instance that ran the erasure only cleared its own.
+// Erasing again what is already gone does nothing
+func (e *Eraser) eraseLocally(erasure proto.UserErasure) {
+	ctx, cancel := context.WithTimeout(context.Background(), resumeTimeout)
+	defer cancel()
+
+	_, err := e.cancelScheduled(erasure.Email)
+	if err != nil {
+		log.Printf("%v : cannot cancel scheduled messages of %s : %v", errErasure, erasure.ErasureID, err)
+	}
+	erased, err := e.db.EraseUser(ctx, erasure.Email, time.Now().UTC())
+	if err != nil {
+		log.Printf("%v : cannot erase %s : %v", errErasure, erasure.ErasureID, err)
+		return
+	}
+	for _, key := range erased.BlobKeys {
+		_, err := e.deleteBlob(ctx, key)
+		if err != nil {
+			log.Printf("%v : cannot delete blob of %s : %v", errErasure, erasure.ErasureID, err)
+		}
+	}
+}
+
+// receive erases an announced user from this instance and
+// hands the erasure over to the OnErased function
+func (e *Eraser) receive(m []byte) {
+	ev, err := event.Unmarshal(m)
+	if err != nil {
+		log.Printf("%v : cannot read erasure event : %v", errErasure, err)
+		return
+	}
+	var erasure proto.UserErasure
+	err = json.Unmarshal(ev.Data, &erasure)
+	if err != nil {
+		log.Printf("%v : cannot read erasure : %v", errErasure, err)
+		return
+	}
+	e.eraseLocally(erasure)
+	e.onErased(erasure)
+}
+
+// pending returns the journals of the unfinished erasures, the oldest first
+func (e *Eraser) pending() ([]*journal, error) {
+	matches, err := filepath.Glob(filepath.Join(e.dir, pendingDir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list erasure journals")
+	}
+
+	journals := make([]*journal, 0, len(matches))
+	for _, path := range matches {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read erasure journal")
+		}
+		var j journal
+		err = json.Unmarshal(b, &j)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode erasure journal")
+		}
+		journals = append(journals, &j)
+	}
+	sort.Slice(journals, func(i, k int) bool {
+		return journals[i].Erasure.StartedAt.Before(journals[k].Erasure.StartedAt)
+	})
+	return journals, nil
+}
+
+// write stores a journal under sub, it is written aside first so that journals are always complete
+func (e *Eraser) write(sub string, j *journal) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode erasure journal")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(e.dir, tmpDir), "erasure-")
+	if err != nil {
+		return errors.Wrap(err, "cannot write erasure journal")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "cannot write erasure journal")
+	}
+	err = tmp.Close()
+	if err != nil {
+		return errors.Wrap(err, "cannot write erasure journal")
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(e.dir, sub, j.Erasure.ErasureID+".json"))
+	if err != nil {
+		return errors.Wrap(err, "cannot store erasure journal")
+	}
+	return nil
+}
+
+// completed reports whether step of an erasure is completed
+func completed(erasure proto.UserErasure, step string) bool {
+	for _, s := range erasure.CompletedSteps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// newID returns a random erasure ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create erasure id")
+	}
+	return hex.EncodeToString(b), nil
+}
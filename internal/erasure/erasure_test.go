@@ -0,0 +1,322 @@
+package erasure
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/platform/blob"
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/schedule"
+)
+
+// recordingBroker keeps what is published
+type recordingBroker struct {
+	mu        sync.Mutex
+	published map[string][][]byte
+}
+
+func (b *recordingBroker) Pub(topic string, message interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published[topic] = append(b.published[topic], message.([]byte))
+	return nil
+}
+
+func (b *recordingBroker) Sub(ctx context.Context, topic string, receive chan []byte, errCh chan error) {
+	<-ctx.Done()
+}
+
+// testEraser is an eraser over a running database and stores in a temporary directory
+type testEraser struct {
+	*Eraser
+	db        *db.Database
+	blobs     *blob.FileStore
+	scheduler *schedule.Scheduler
+	mb        *recordingBroker
+	notified  []string
+}
+
+func newTestEraser(t *testing.T) *testEraser {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "erasure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	database := db.NewDatabase()
+	go database.Run()
+	t.Cleanup(database.Stop)
+
+	blobs, err := blob.NewFileStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler, err := schedule.NewScheduler(filepath.Join(dir, "scheduled"), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &recordingBroker{published: make(map[string][][]byte)}
+	e, err := NewEraser(filepath.Join(dir, "erasures"), database, blobs, scheduler, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te := &testEraser{Eraser: e, db: database, blobs: blobs, scheduler: scheduler, mb: mb}
+	e.OnNotify(func(erasure proto.UserErasure) error {
+		te.notified = append(te.notified, erasure.Email)
+		return nil
+	})
+	return te
+}
+
+// upload stores content as an attachment of owner the way uploads do and returns its blob key
+func (te *testEraser) upload(t *testing.T, owner, attachmentID, content string) string {
+	t.Helper()
+	ctx := context.Background()
+	storedAt := time.Now().UTC()
+	key, size, err := te.blobs.Put(ctx, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment := proto.Attachment{AttachmentID: attachmentID, OwnerEmail: owner, Size: size}
+	err = te.db.CreateAttachment(ctx, attachment, key, 0, storedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// stored reports whether the blob store still has key
+func (te *testEraser) stored(t *testing.T, key string) bool {
+	t.Helper()
+	r, err := te.blobs.Get(context.Background(), key)
+	if errors.Cause(err) == blob.ErrNotFound {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	return true
+}
+
+// journals returns the names of the journals under sub
+func (te *testEraser) journals(t *testing.T, sub string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(te.dir, sub, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(matches))
+	for i, path := range matches {
+		names[i] = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	return names
+}
+
+func TestEraseRunsEveryStep(t *testing.T) {
+	te := newTestEraser(t)
+	ctx := context.Background()
+
+	sendAt := time.Now().Add(time.Hour)
+	err := te.scheduler.Schedule(proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "later", SendAt: &sendAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = te.db.CreateChatMessage(ctx, "a@x.com", proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "now", MessageText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := te.upload(t, "a@x.com", "a-own", "only a has this")
+	shared := te.upload(t, "a@x.com", "a-shared", "both have this")
+	te.upload(t, "b@x.com", "b-shared", "both have this")
+
+	erasure, err := te.Erase(ctx, "a@x.com", "admin@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erasure.Status != StatusDone || erasure.CompletedAt == nil {
+		t.Fatalf("erasure = %+v, want it done", erasure)
+	}
+	if strings.Join(erasure.CompletedSteps, ",") != strings.Join(steps, ",") {
+		t.Fatalf("completed steps %v, want %v", erasure.CompletedSteps, steps)
+	}
+	if erasure.MessagesErased != 2 || erasure.AttachmentsErased != 2 || erasure.BlobsDeleted != 1 {
+		t.Fatalf("erased %d messages, %d attachments and %d blobs, want 2, 2 and 1",
+			erasure.MessagesErased, erasure.AttachmentsErased, erasure.BlobsDeleted)
+	}
+
+	if te.stored(t, own) {
+		t.Fatal("the blob only the user referred to was kept")
+	}
+	if !te.stored(t, shared) {
+		t.Fatal("the blob another user refers to was deleted")
+	}
+	if scheduled, _ := te.scheduler.List("a@x.com"); len(scheduled) != 0 {
+		t.Fatalf("%d scheduled messages left", len(scheduled))
+	}
+
+	if pending := te.journals(t, pendingDir); len(pending) != 0 {
+		t.Fatalf("journals %v left pending", pending)
+	}
+	if done := te.journals(t, doneDir); len(done) != 1 || done[0] != erasure.ErasureID {
+		t.Fatalf("completion records %v, want %s", done, erasure.ErasureID)
+	}
+
+	if len(te.notified) != 1 || te.notified[0] != "a@x.com" {
+		t.Fatalf("notified %v, want a@x.com", te.notified)
+	}
+	published := te.mb.published[Topic]
+	if len(published) != 1 {
+		t.Fatalf("%d erasures announced, want 1", len(published))
+	}
+	ev, err := event.Unmarshal(published[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var announced proto.UserErasure
+	err = json.Unmarshal(ev.Data, &announced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != event.Erased || announced.ErasureID != erasure.ErasureID {
+		t.Fatalf("announced %s of %s, want %s of %s", ev.Type, announced.ErasureID, event.Erased, erasure.ErasureID)
+	}
+}
+
+func TestEraseResumesFromTheJournal(t *testing.T) {
+	te := newTestEraser(t)
+	ctx := context.Background()
+
+	released := te.upload(t, "a@x.com", "a-1", "released")
+	reused := te.upload(t, "a@x.com", "a-2", "stored again since")
+	_, err := te.db.EraseUser(ctx, "a@x.com", time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another user stored the content of a released blob before the blobs step ran
+	te.upload(t, "b@x.com", "b-1", "stored again since")
+
+	// an earlier run stopped after the data step
+	j := &journal{
+		Erasure: proto.UserErasure{
+			ErasureID:         "interrupted",
+			Email:             "a@x.com",
+			RequestedBy:       "admin@x.com",
+			Status:            StatusRunning,
+			CompletedSteps:    []string{StepScheduled, StepData},
+			AttachmentsErased: 2,
+			StartedAt:         time.Now().UTC(),
+		},
+		BlobKeys: []string{released, reused},
+	}
+	err = te.write(pendingDir, j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	erasure, err := te.Erase(ctx, "a@x.com", "admin@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erasure.ErasureID != "interrupted" {
+		t.Fatalf("started erasure %s instead of resuming", erasure.ErasureID)
+	}
+	if erasure.AttachmentsErased != 2 || erasure.BlobsDeleted != 1 {
+		t.Fatalf("erased %d attachments and %d blobs, want 2 and 1", erasure.AttachmentsErased, erasure.BlobsDeleted)
+	}
+	if te.stored(t, released) {
+		t.Fatal("the released blob was kept")
+	}
+	if !te.stored(t, reused) {
+		t.Fatal("the blob referred to again was deleted")
+	}
+	if len(te.notified) != 1 {
+		t.Fatalf("notified %d times, want once", len(te.notified))
+	}
+}
+
+func TestUploadsOfDeletedBlobsAreRejected(t *testing.T) {
+	te := newTestEraser(t)
+	ctx := context.Background()
+
+	te.upload(t, "a@x.com", "a-1", "same content")
+
+	// b stores the same content before the erasure deletes it, but records it after
+	storedAt := time.Now().UTC()
+	key, size, err := te.blobs.Put(ctx, strings.NewReader("same content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = te.Erase(ctx, "a@x.com", "admin@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = te.db.CreateAttachment(ctx, proto.Attachment{AttachmentID: "b-1", OwnerEmail: "b@x.com", Size: size}, key, 0, storedAt)
+	if errors.Cause(err) != db.ErrBlobDeleted {
+		t.Fatalf("err = %v, want %v", err, db.ErrBlobDeleted)
+	}
+
+	// storing it again is fine
+	te.upload(t, "b@x.com", "b-1", "same content")
+	if !te.stored(t, key) {
+		t.Fatal("the content stored again is missing")
+	}
+}
+
+func TestAnnouncedErasuresEraseTheUserOnEveryInstance(t *testing.T) {
+	ran, other := newTestEraser(t), newTestEraser(t)
+	ctx := context.Background()
+
+	// the other instance has its own database and stores
+	_, err := other.db.CreateChatMessage(ctx, "a@x.com", proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "m1", MessageText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := other.upload(t, "a@x.com", "a-1", "kept by the other instance")
+	sendAt := time.Now().Add(time.Hour)
+	err = other.scheduler.Schedule(proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "later", SendAt: &sendAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forgotten []string
+	other.OnErased(func(erasure proto.UserErasure) {
+		forgotten = append(forgotten, erasure.Email)
+	})
+
+	_, err = ran.Erase(ctx, "a@x.com", "admin@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.receive(ran.mb.published[Topic][0])
+
+	if len(forgotten) != 1 || forgotten[0] != "a@x.com" {
+		t.Fatalf("forgotten %v, want a@x.com", forgotten)
+	}
+	_, err = other.db.GetChatMessage(ctx, "b@x.com", "m1")
+	if errors.Cause(err) != db.ErrMessageNotFound {
+		t.Fatalf("message of the erased user: err = %v, want %v", err, db.ErrMessageNotFound)
+	}
+	_, _, err = other.db.GetAttachment(ctx, "a@x.com", "a-1")
+	if errors.Cause(err) != db.ErrAttachmentNotFound {
+		t.Fatalf("attachment of the erased user: err = %v, want %v", err, db.ErrAttachmentNotFound)
+	}
+	if other.stored(t, key) {
+		t.Fatal("the blob of the erased user was kept")
+	}
+	if scheduled, _ := other.scheduler.List("a@x.com"); len(scheduled) != 0 {
+		t.Fatalf("%d scheduled messages left", len(scheduled))
+	}
+}
@@ -0,0 +1,106 @@
+package event
+
+import (
+	"encoding/json"
+)
+
+// Types of the events published on the users chat topics,
+// the type is used as the SSE event name on /stream
+const (
+	Message = "message"
+	Receipt = "receipt"
+	Edited  = "edited"
+	Deleted = "deleted"
+	// published to the other devices of a reader when their read cursor
+	// moves, and to the other participant of direct conversations
+	ReadCursor = "readCursor"
+	// messages purged once the lifetime of their conversation passed
+	Expired = "expired"
+	// reactions added or removed, with the updated aggregate
+	Reaction = "reaction"
+	// new thread replies along with the updated counters of their parent
+	Reply = "reply"
+	// published to the sender of a scheduled message that can't be sent once due
+	ScheduledFailed = "scheduledFailed"
+	// ephemeral, never stored
+	Typing   = "typing"
+	Presence = "presence"
+	// membership events, published on the topic of the member that
+	// joined or left so that their streams follow the room topic
+	RoomJoined = "roomJoined"
+	RoomLeft   = "roomLeft"
+	// published on the room topic when its members change
+	RoomUpdated = "roomUpdated"
+	// published to the conversation when its settings change
+	ConversationUpdated = "conversationUpdated"
+	// published to the topic of the users a message mentions, even
+	// when they muted its conversation
+	Mention = "mention"
+	// published to the topic of an erased user, their streams end
+	// once they get it, and announced to every instance
+	Erased = "erased"
+	// published by a stream to the topic of its user once subscribed, the stream
+	// knows its subscription is live when it gets it back. Never sent to clients
+	Subscribed = "subscribed"
+)
+
+// Event is the envelope of everything published on the users chat topics
+type Event struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+	// users that muted the conversation of a message event
+	Muted []string `json:"muted,omitempty"`
+	// identifies the event in the pending queues of the users it was
+	// published to, empty for events that are never queued
+	ID string `json:"id,omitempty"`
+}
+
+// New wraps data in an event envelope of the given type along
+// with the users that muted the conversation it belongs to
+func New(eventType string, data interface{}, muted []string) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Type:  eventType,
+		Data:  b,
+		Muted: muted,
+	}, nil
+}
+
+// Marshal wraps data in an event envelope of the given type
+func Marshal(eventType string, data interface{}) ([]byte, error) {
+	e, err := New(eventType, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// Ephemeral reports whether events of eventType only matter to the streams connected
+// when they are published, they are not queued for the users that are not
+func Ephemeral(eventType string) bool {
+	switch eventType {
+	case Typing, Presence, Erased, Subscribed:
+		return true
+	}
+	return false
+}
+
+// MutedBy reports whether email muted the conversation of the event
+func (e Event) MutedBy(email string) bool {
+	for _, muted := range e.Muted {
+		if muted == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshal reads an event envelope
+func Unmarshal(b []byte) (Event, error) {
+	var e Event
+	err := json.Unmarshal(b, &e)
+	return e, err
+}
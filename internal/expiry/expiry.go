@@ -0,0 +1,83 @@
+package expiry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// time allowed to purge and report the expired messages of one sweep
+	sweepTimeout = 10 * time.Second
+	// Errors
+	errSweep = "expired messages sweep error"
+)
+
+// Sweeper purges the chat messages of conversations with a message lifetime once they expire
+// and hands them to a function that tells the clients. The database keeps the messages ordered
+// by expiry time so a sweep only visits the expired ones
+type Sweeper struct {
+	db       *db.Database
+	interval time.Duration
+	expired  func(context.Context, []proto.ChatMessage)
+
+	quitCh chan chan struct{}
+}
+
+// NewSweeper returns a sweeper purging the expired messages of database every interval
+func NewSweeper(database *db.Database, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		db:       database,
+		interval: interval,
+		expired:  func(context.Context, []proto.ChatMessage) {},
+		quitCh:   make(chan chan struct{}),
+	}
+}
+
+// OnExpired sets the function the purged messages are handed to,
+// it must be set before Run is called
+func (s *Sweeper) OnExpired(fn func(context.Context, []proto.ChatMessage)) {
+	s.expired = fn
+}
+
+// Run purges the expired messages every interval until Stop is called
+func (s *Sweeper) Run() error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(time.Now().UTC())
+		case q := <-s.quitCh:
+			close(q)
+			return nil
+		}
+	}
+}
+
+// Stop stops purging messages
+func (s *Sweeper) Stop() {
+	q := make(chan struct{})
+	s.quitCh <- q
+	// This blocks until Run() closes q and returns
+	<-q
+}
+
+// sweep purges the messages expired at now
+func (s *Sweeper) sweep(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), sweepTimeout)
+	defer cancel()
+
+	messages, err := s.db.ExpireMessages(ctx, now)
+	if err != nil {
+		log.Printf("%v : %v", errSweep, err)
+		return
+	}
+	if len(messages) > 0 {
+		s.expired(ctx, messages)
+	}
+}
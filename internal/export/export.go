@@ -0,0 +1,253 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/platform/blob"
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/schedule"
+)
+
+const (
+	// exports waiting for a worker, exports past that are rejected
+	queueSize = 64
+	// the progress of an export is recorded every that many records
+	progressInterval = 100
+	// time allowed to record the outcome of an export
+	recordTimeout = 5 * time.Second
+	// reason given to users, the cause is only logged
+	failureReason = "the archive could not be written"
+	// Errors
+	errExport = "data export error"
+)
+
+// Types of the archive records
+const (
+	// first record, describes the export
+	HeaderRecord     = "export"
+	MessageRecord    = "message"
+	RevisionRecord   = "revision"
+	ScheduledRecord  = "scheduledMessage"
+	ReactionRecord   = "reaction"
+	AttachmentRecord = "attachment"
+	RoomRecord       = "room"
+	SettingsRecord   = "conversationSettings"
+	BlockedRecord    = "blockedUser"
+	MutedRecord      = "mutedConversation"
+	ReadCursorRecord = "readCursor"
+)
+
+// Record is one line of an archive
+type Record struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// header is the data of the first record of an archive
+type header struct {
+	ExportID   string    `json:"exportID"`
+	Email      string    `json:"email"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// Job is a pending data export
+type Job struct {
+	ExportID string
+	Email    string
+}
+
+// Exporter writes the data of users into NDJSON archives kept in the blob store,
+// one record per line. Exports run in the background on a bounded pool of workers
+// and their progress and outcome is recorded in the database
+type Exporter struct {
+	db        *db.Database
+	blobs     blob.Store
+	scheduler *schedule.Scheduler
+	workers   int
+
+	jobs   chan Job
+	quitCh chan chan struct{}
+}
+
+// NewExporter returns an exporter running workers exports at once, the scheduled
+// messages of users are exported along with what database holds about them
+func NewExporter(database *db.Database, blobs blob.Store, scheduler *schedule.Scheduler, workers int) *Exporter {
+	return &Exporter{
+		db:        database,
+		blobs:     blobs,
+		scheduler: scheduler,
+		workers:   workers,
+		jobs:      make(chan Job, queueSize),
+		quitCh:    make(chan chan struct{}),
+	}
+}
+
+// Enqueue hands an export over to the workers, false is returned when the queue is full
+func (e *Exporter) Enqueue(job Job) bool {
+	select {
+	case e.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes the queued exports until Stop is called,
+// the exports in progress are cancelled before it returns
+func (e *Exporter) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-e.jobs:
+					e.process(ctx, job)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	q := <-e.quitCh
+	cancel()
+	wg.Wait()
+	close(q)
+	return nil
+}
+
+// Stop stops the workers
+func (e *Exporter) Stop() {
+	q := make(chan struct{})
+	e.quitCh <- q
+	// This blocks until Run() closes q and returns
+	<-q
+}
+
+// process exports the data of a user and records the outcome
+func (e *Exporter) process(ctx context.Context, job Job) {
+	key, size, err := e.export(ctx, job)
+
+	rctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	if err != nil {
+		log.Printf("%v : %s : %v", errExport, job.ExportID, err)
+		err = e.db.FailExport(rctx, job.ExportID, failureReason, now)
+	} else {
+		err = e.db.CompleteExport(rctx, job.ExportID, key, size, now)
+	}
+	if err != nil {
+		log.Printf("%v : %s : %v", errExport, job.ExportID, err)
+	}
+}
+
+// export gathers the data of a user and stores it as an archive,
+// the key and size of the archive are returned
+func (e *Exporter) export(ctx context.Context, job Job) (string, int64, error) {
+	data, err := e.db.UserData(ctx, job.Email)
+	if err != nil {
+		return "", 0, err
+	}
+	scheduled, err := e.scheduler.List(job.Email)
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := header{ExportID: job.ExportID, Email: job.Email, ExportedAt: time.Now().UTC()}
+	records := archiveRecords(h, data, scheduled)
+	err = e.db.StartExport(ctx, job.ExportID, len(records))
+	if err != nil {
+		return "", 0, err
+	}
+
+	// records are streamed to the blob store as they are encoded
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := e.write(ctx, job.ExportID, pw, records)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	key, size, err := e.blobs.Put(ctx, pr)
+	// unblocks the writer when the blob store gave up early
+	pr.CloseWithError(errors.New("archive is closed"))
+	werr := <-written
+	if err != nil {
+		return "", 0, err
+	}
+	if werr != nil {
+		return "", 0, werr
+	}
+	return key, size, nil
+}
+
+// write encodes records to w one per line, recording the progress along the way
+func (e *Exporter) write(ctx context.Context, exportID string, w io.Writer, records []Record) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for i, record := range records {
+		err := enc.Encode(record)
+		if err != nil {
+			return errors.Wrap(err, "cannot write record")
+		}
+		if (i+1)%progressInterval == 0 {
+			err = e.db.ExportProgress(ctx, exportID, i+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return buf.Flush()
+}
+
+// archiveRecords returns the records of an archive, starting with the header
+func archiveRecords(h header, data db.UserData, scheduled []proto.ChatMessage) []Record {
+	records := []Record{{Type: HeaderRecord, Data: h}}
+	for _, msg := range data.Messages {
+		records = append(records, Record{Type: MessageRecord, Data: msg})
+	}
+	for _, msg := range data.Revisions {
+		records = append(records, Record{Type: RevisionRecord, Data: msg})
+	}
+	for _, msg := range scheduled {
+		records = append(records, Record{Type: ScheduledRecord, Data: msg})
+	}
+	for _, reaction := range data.Reactions {
+		records = append(records, Record{Type: ReactionRecord, Data: reaction})
+	}
+	for _, attachment := range data.Attachments {
+		records = append(records, Record{Type: AttachmentRecord, Data: attachment})
+	}
+	for _, room := range data.Rooms {
+		records = append(records, Record{Type: RoomRecord, Data: room})
+	}
+	for _, settings := range data.Settings {
+		records = append(records, Record{Type: SettingsRecord, Data: settings})
+	}
+	for _, blocked := range data.Blocked {
+		records = append(records, Record{Type: BlockedRecord, Data: blocked})
+	}
+	for _, muted := range data.Muted {
+		records = append(records, Record{Type: MutedRecord, Data: muted})
+	}
+	for _, cursor := range data.ReadCursors {
+		records = append(records, Record{Type: ReadCursorRecord, Data: cursor})
+	}
+	return records
+}
@@ -0,0 +1,73 @@
+package moderation
+
+import (
+	"fmt"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// Stage is one step of the moderation pipeline, it can change the message,
+// annotate it, or reject it by returning the reason why
+type Stage interface {
+	// Name identifies the stage in rejections and annotations
+	Name() string
+	// Process moderates msg in place
+	Process(msg *proto.ChatMessage) error
+}
+
+// stageFunc is a stage made of a function
+type stageFunc struct {
+	name string
+	fn   func(*proto.ChatMessage) error
+}
+
+func (s stageFunc) Name() string {
+	return s.name
+}
+
+func (s stageFunc) Process(msg *proto.ChatMessage) error {
+	return s.fn(msg)
+}
+
+// NewStage returns a stage named name running fn
+func NewStage(name string, fn func(*proto.ChatMessage) error) Stage {
+	return stageFunc{name: name, fn: fn}
+}
+
+// Rejection is returned when a stage rejects a message
+type Rejection struct {
+	Stage  string
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("rejected by the %s stage: %s", r.Stage, r.Reason)
+}
+
+// Pipeline runs messages through its stages in order
+// before they are stored and published
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline returns a pipeline running the given stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Process runs msg through every stage, the first one rejecting it
+// stops the pipeline and a *Rejection naming it is returned
+func (p *Pipeline) Process(msg *proto.ChatMessage) error {
+	for _, stage := range p.stages {
+		err := stage.Process(msg)
+		if err != nil {
+			return &Rejection{Stage: stage.Name(), Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Annotate leaves a note of stage on msg
+func Annotate(msg *proto.ChatMessage, stage, note string) {
+	msg.Annotations = append(msg.Annotations, &proto.Annotation{Stage: stage, Note: note})
+}
@@ -0,0 +1,143 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// moderate runs text through stage and returns the moderated message
+func moderate(t *testing.T, stage Stage, text string) (*proto.ChatMessage, error) {
+	t.Helper()
+	msg := &proto.ChatMessage{MessageText: text}
+	return msg, NewPipeline(stage).Process(msg)
+}
+
+func TestPipelineStopsAtTheFirstRejection(t *testing.T) {
+	var ran []string
+	stage := func(name string, err error) Stage {
+		return NewStage(name, func(msg *proto.ChatMessage) error {
+			ran = append(ran, name)
+			msg.MessageText += name
+			return err
+		})
+	}
+	p := NewPipeline(stage("a", nil), stage("b", errors.New("no")), stage("c", nil))
+
+	msg := &proto.ChatMessage{}
+	err := p.Process(msg)
+	rejection, ok := err.(*Rejection)
+	if !ok {
+		t.Fatalf("err = %v, want a *Rejection", err)
+	}
+	if rejection.Stage != "b" || rejection.Reason != "no" {
+		t.Fatalf("rejection = %+v, want stage b rejecting with no", rejection)
+	}
+	if strings.Join(ran, ",") != "a,b" {
+		t.Fatalf("ran %v, want a,b", ran)
+	}
+	if msg.MessageText != "ab" {
+		t.Fatalf("text = %q, want the changes of a and b", msg.MessageText)
+	}
+}
+
+func TestMaxLengthCountsCharacters(t *testing.T) {
+	stage := MaxLength(3)
+	if _, err := moderate(t, stage, "héé"); err != nil {
+		t.Fatalf("3 characters rejected: %v", err)
+	}
+	if _, err := moderate(t, stage, "abcd"); err == nil {
+		t.Fatal("4 characters accepted")
+	}
+}
+
+func TestBlocklistMatchesWholeWordsAndPhrases(t *testing.T) {
+	stage := Blocklist([]string{"spam", "buy now", "  "})
+	tests := []struct {
+		text    string
+		blocked bool
+	}{
+		{"this is SPAM!", true},
+		{"spammer here", false},
+		{"please Buy   now", true},
+		{"buy it now", false},
+		{"buy", false},
+	}
+	for _, tt := range tests {
+		_, err := moderate(t, stage, tt.text)
+		if (err != nil) != tt.blocked {
+			t.Errorf("%q: err = %v, blocked = %v", tt.text, err, tt.blocked)
+		}
+	}
+}
+
+func TestURLAllowlistAllowsHostsAndSubdomains(t *testing.T) {
+	stage := URLAllowlist([]string{" Example.com ", ""})
+	tests := []struct {
+		text    string
+		allowed bool
+	}{
+		{"no links", true},
+		{"see https://example.com/a?b=c.", true},
+		{"see www.docs.example.com, then", true},
+		{"see http://badexample.com", false},
+		{"see https://example.com.evil.org", false},
+		{"see https://EXAMPLE.com and http://other.org", false},
+	}
+	for _, tt := range tests {
+		_, err := moderate(t, stage, tt.text)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%q: err = %v, allowed = %v", tt.text, err, tt.allowed)
+		}
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	tests := []struct {
+		text        string
+		want        string
+		annotations []string
+	}{
+		{
+			text:        "card 4111 1111 1111 1111 thanks",
+			want:        "card [card number] thanks",
+			annotations: []string{"1 card numbers redacted"},
+		},
+		{
+			// fails the Luhn check
+			text: "order 4111 1111 1111 1112",
+			want: "order 4111 1111 1111 1112",
+		},
+		{
+			text:        "call +44 20 7946 0958 or (555) 123-4567",
+			want:        "call [phone number] or [phone number]",
+			annotations: []string{"2 phone numbers redacted"},
+		},
+		{
+			text: "on 2020-05-17 ref 12345",
+			want: "on 2020-05-17 ref 12345",
+		},
+	}
+	for _, tt := range tests {
+		msg, err := moderate(t, RedactPII(), tt.text)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.text, err)
+		}
+		if msg.MessageText != tt.want {
+			t.Errorf("%q: redacted to %q, want %q", tt.text, msg.MessageText, tt.want)
+		}
+		var notes []string
+		for _, a := range msg.Annotations {
+			if a.Stage != PIIStage {
+				t.Errorf("%q: annotated by %s", tt.text, a.Stage)
+			}
+			notes = append(notes, a.Note)
+		}
+		if strings.Join(notes, ";") != strings.Join(tt.annotations, ";") {
+			t.Errorf("%q: annotations %v, want %v", tt.text, notes, tt.annotations)
+		}
+	}
+}
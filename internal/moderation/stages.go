@@ -0,0 +1,188 @@
+package moderation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/search"
+)
+
+// Names of the built in stages
+const (
+	MaxLengthStage    = "maxLength"
+	BlocklistStage    = "blocklist"
+	URLAllowlistStage = "urlAllowlist"
+	PIIStage          = "pii"
+)
+
+var (
+	// links starting with a scheme or www.
+	urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+	// 13 to 19 digits, optionally grouped with spaces or dashes
+	cardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// + followed by digits optionally grouped with spaces, dots, dashes or brackets
+	intlPhonePattern = regexp.MustCompile(`\+\d(?:[ .()-]{0,2}\d){6,14}\b`)
+	// 10 digits grouped as 3-3-4, the area code optionally in brackets
+	localPhonePattern = regexp.MustCompile(`(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`)
+)
+
+// MaxLength rejects messages with a text longer than max characters
+func MaxLength(max int) Stage {
+	return NewStage(MaxLengthStage, func(msg *proto.ChatMessage) error {
+		if utf8.RuneCountInString(msg.MessageText) > max {
+			return errors.Errorf("text is longer than %d characters", max)
+		}
+		return nil
+	})
+}
+
+// Blocklist rejects messages containing one of the given words or phrases,
+// they are matched as whole words regardless of case
+func Blocklist(phrases []string) Stage {
+	var blocked [][]string
+	for _, phrase := range phrases {
+		words := search.Words(phrase)
+		if len(words) > 0 {
+			blocked = append(blocked, words)
+		}
+	}
+
+	return NewStage(BlocklistStage, func(msg *proto.ChatMessage) error {
+		words := search.Words(msg.MessageText)
+		for i := range words {
+			for _, phrase := range blocked {
+				if hasPrefix(words[i:], phrase) {
+					return errors.New("text contains a blocked word")
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// hasPrefix reports whether words start with prefix
+func hasPrefix(words, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// URLAllowlist rejects messages linking to other hosts than the
+// given ones, subdomains of an allowed host are allowed too
+func URLAllowlist(hosts []string) Stage {
+	allowed := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			allowed = append(allowed, host)
+		}
+	}
+
+	isAllowed := func(host string) bool {
+		for _, a := range allowed {
+			if host == a || strings.HasSuffix(host, "."+a) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return NewStage(URLAllowlistStage, func(msg *proto.ChatMessage) error {
+		for _, link := range urlPattern.FindAllString(msg.MessageText, -1) {
+			link = strings.TrimRight(link, ".,;:!?)")
+			if !strings.Contains(link, "://") {
+				link = "http://" + link
+			}
+			u, err := url.Parse(link)
+			if err != nil || u.Hostname() == "" {
+				return errors.New("text contains an invalid link")
+			}
+			host := strings.ToLower(u.Hostname())
+			if !isAllowed(host) {
+				return errors.Errorf("links to %s are not allowed", host)
+			}
+		}
+		return nil
+	})
+}
+
+// RedactPII replaces the card numbers and phone numbers of messages and annotates them.
+// Card numbers must pass the Luhn check, phone numbers must either be in international
+// format or have 10 digits grouped as 3-3-4, so that dates and short references are kept
+func RedactPII() Stage {
+	return NewStage(PIIStage, func(msg *proto.ChatMessage) error {
+		text, cards := redact(msg.MessageText, cardPattern, "[card number]", func(digits string) bool {
+			return len(digits) >= 13 && len(digits) <= 19 && luhn(digits)
+		})
+		text, intl := redact(text, intlPhonePattern, "[phone number]", func(digits string) bool {
+			return len(digits) >= 7 && len(digits) <= 15
+		})
+		text, local := redact(text, localPhonePattern, "[phone number]", func(string) bool {
+			return true
+		})
+
+		if cards > 0 {
+			Annotate(msg, PIIStage, fmt.Sprintf("%d card numbers redacted", cards))
+		}
+		if phones := intl + local; phones > 0 {
+			Annotate(msg, PIIStage, fmt.Sprintf("%d phone numbers redacted", phones))
+		}
+		msg.MessageText = text
+		return nil
+	})
+}
+
+// redact replaces the matches of pattern whose digits pass check
+// and returns the text along with the number of replacements
+func redact(text string, pattern *regexp.Regexp, replacement string, check func(digits string) bool) (string, int) {
+	n := 0
+	text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if !check(digitsOf(match)) {
+			return match
+		}
+		n++
+		return replacement
+	})
+	return text, n
+}
+
+// digitsOf returns the digits of s
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// luhn reports whether digits pass the Luhn checksum of card numbers
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
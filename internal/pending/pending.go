@@ -0,0 +1,274 @@
+package pending
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/platform/broker"
+)
+
+const (
+	// instances share what they queue and what streams ack on this topic
+	syncTopic = "users.pending"
+	// time a replay waits for the operations announced before it to be applied,
+	// the queue of the instance is replayed as it is past that
+	syncTimeout = 5 * time.Second
+	// how often a sync marker is announced again until it comes back
+	markerInterval = 50 * time.Millisecond
+	// time allowed to apply an operation announced by another instance
+	applyTimeout = 5 * time.Second
+	// Errors
+	errPendingSync = "pending events sync error"
+)
+
+// Kinds of the operations instances announce
+const (
+	opQueue = "queue"
+	opAck   = "ack"
+	opSync  = "sync"
+)
+
+// op is an operation on the pending queues announced to every instance
+type op struct {
+	Kind     string `json:"kind"`
+	Instance string `json:"instance"`
+	// users the event is queued for, or the user that acked it
+	Emails []string     `json:"emails,omitempty"`
+	Event  *event.Event `json:"event,omitempty"`
+	// acked event
+	ID string `json:"id,omitempty"`
+	// sync marker, only the instance that announced it applies it
+	Marker string `json:"marker,omitempty"`
+}
+
+// Queues keeps the events published to users that none of their streams got yet.
+// Each instance keeps the queues in its own database, the operations on them are
+// announced on the broker and applied by every instance so that a stream connecting
+// to any of them replays the same events
+type Queues struct {
+	id    string
+	db    *db.Database
+	mb    broker.MessageBroker
+	limit int
+
+	mu sync.Mutex
+	// sync markers of this instance waiting to come back
+	markers map[string]chan struct{}
+
+	quitCh chan chan struct{}
+}
+
+// NewQueues returns queues keeping up to limit events per user
+func NewQueues(database *db.Database, mb broker.MessageBroker, limit int) (*Queues, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create pending instance id")
+	}
+
+	return &Queues{
+		id:      id,
+		db:      database,
+		mb:      mb,
+		limit:   limit,
+		markers: make(map[string]chan struct{}),
+		quitCh:  make(chan chan struct{}),
+	}, nil
+}
+
+// Run applies the operations announced by the other instances until Stop is called
+func (q *Queues) Run() error {
+	defer func() {
+		log.Println("Pending queues closed")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	msgCh := make(chan []byte, 512)
+	errCh := make(chan error, 1)
+	go q.mb.Sub(ctx, syncTopic, msgCh, errCh)
+
+	for {
+		select {
+		case err := <-errCh:
+			return errors.Wrap(err, errPendingSync)
+		case m, open := <-msgCh:
+			if !open {
+				return errors.New(errPendingSync)
+			}
+			q.receive(m)
+		case c := <-q.quitCh:
+			close(c)
+			return nil
+		}
+	}
+}
+
+// Stop stops applying the operations of the other instances and blocks until Run returns
+func (q *Queues) Stop() {
+	c := make(chan struct{})
+	q.quitCh <- c
+	<-c
+}
+
+// Queue queues ev for every given user and returns it with the ID it is queued under
+func (q *Queues) Queue(ctx context.Context, ev event.Event, emails ...string) (event.Event, error) {
+	if ev.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return event.Event{}, errors.Wrap(err, "cannot create event id")
+		}
+		ev.ID = id
+	}
+
+	for _, email := range emails {
+		err := q.db.QueueEvent(ctx, email, ev, q.limit)
+		if err != nil {
+			return event.Event{}, err
+		}
+	}
+	err := q.announce(op{Kind: opQueue, Emails: emails, Event: &ev})
+	if err != nil {
+		return event.Event{}, err
+	}
+	return ev, nil
+}
+
+// Ack records that one of the streams of email got the event id
+func (q *Queues) Ack(ctx context.Context, email, id string) error {
+	err := q.db.AckEvent(ctx, email, id)
+	if err != nil {
+		return err
+	}
+	return q.announce(op{Kind: opAck, Emails: []string{email}, ID: id})
+}
+
+// Pending returns the events queued for email that none of their streams got yet, oldest first.
+// The operations the other instances announced before the call are applied first, so the events
+// queued before a stream subscribed are replayed whichever instance published them
+func (q *Queues) Pending(ctx context.Context, email string) ([]event.Event, error) {
+	err := q.sync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return q.db.PendingEvents(ctx, email)
+}
+
+// sync announces a marker until it comes back, the broker delivers the operations
+// announced before it first. It gives up after syncTimeout
+func (q *Queues) sync(ctx context.Context) error {
+	marker, err := newID()
+	if err != nil {
+		return errors.Wrap(err, "cannot create sync marker")
+	}
+	back := make(chan struct{})
+	q.mu.Lock()
+	q.markers[marker] = back
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.markers, marker)
+		q.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(markerInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(syncTimeout)
+	defer timeout.Stop()
+
+	for {
+		err = q.announce(op{Kind: opSync, Marker: marker})
+		if err != nil {
+			return err
+		}
+		select {
+		case <-back:
+			return nil
+		case <-ticker.C:
+		case <-timeout.C:
+			log.Printf("%v : sync marker not received back", errPendingSync)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// announce publishes an operation of this instance to every instance
+func (q *Queues) announce(o op) error {
+	o.Instance = q.id
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return q.mb.Pub(syncTopic, b)
+}
+
+// receive applies an operation announced by another instance,
+// those of this instance were applied when they were announced
+func (q *Queues) receive(m []byte) {
+	var o op
+	err := json.Unmarshal(m, &o)
+	if err != nil {
+		log.Printf("%v : cannot read operation : %v", errPendingSync, err)
+		return
+	}
+
+	if o.Instance == q.id {
+		if o.Kind == opSync {
+			q.mu.Lock()
+			back, ok := q.markers[o.Marker]
+			delete(q.markers, o.Marker)
+			q.mu.Unlock()
+			if ok {
+				close(back)
+			}
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+	defer cancel()
+
+	switch o.Kind {
+	case opQueue:
+		if o.Event == nil {
+			return
+		}
+		for _, email := range o.Emails {
+			err = q.db.QueueEvent(ctx, email, *o.Event, q.limit)
+			if err != nil {
+				break
+			}
+		}
+	case opAck:
+		for _, email := range o.Emails {
+			err = q.db.AckEvent(ctx, email, o.ID)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("%v : cannot apply %s operation : %v", errPendingSync, o.Kind, err)
+	}
+}
+
+// newID returns a random id
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
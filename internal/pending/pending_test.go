@@ -0,0 +1,131 @@
+package pending
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/event"
+)
+
+// memBroker is an in memory broker shared by the instances of a test
+type memBroker struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+func (b *memBroker) Pub(topic string, message interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs[topic] {
+		ch <- message.([]byte)
+	}
+	return nil
+}
+
+func (b *memBroker) Sub(ctx context.Context, topic string, receive chan []byte, errCh chan error) {
+	b.mu.Lock()
+	b.subs[topic] = append(b.subs[topic], receive)
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	subs := b.subs[topic]
+	for i, ch := range subs {
+		if ch == receive {
+			b.subs[topic] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+	close(receive)
+}
+
+// newInstance returns the queues of an instance with its own database
+func newInstance(t *testing.T, mb *memBroker) (*Queues, *db.Database) {
+	t.Helper()
+	database := db.NewDatabase()
+	go database.Run()
+	t.Cleanup(database.Stop)
+
+	q, err := NewQueues(database, mb, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go q.Run()
+	t.Cleanup(q.Stop)
+	return q, database
+}
+
+func TestStreamsReplayTheEventsOfEveryInstance(t *testing.T) {
+	mb := &memBroker{subs: make(map[string][]chan []byte)}
+	published, publishedDB := newInstance(t, mb)
+	connected, _ := newInstance(t, mb)
+	ctx := context.Background()
+
+	// both instances follow the operations of the other
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		mb.mu.Lock()
+		n := len(mb.subs[syncTopic])
+		mb.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the instances to subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ev, err := event.New(event.Message, "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err = published.Queue(ctx, ev, "a@x.com", "b@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID == "" {
+		t.Fatal("queued event without id")
+	}
+
+	// b reconnects to the other instance
+	start := time.Now()
+	events, err := connected.Pending(ctx, "b@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != ev.ID {
+		t.Fatalf("pending events %+v, want %s", events, ev.ID)
+	}
+	if time.Since(start) >= syncTimeout {
+		t.Fatal("the sync marker never came back")
+	}
+
+	// the ack of b reaches the instance the event was published on
+	err = connected.Ack(ctx, "b@x.com", ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = published.Pending(ctx, "b@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = publishedDB.PendingEvents(ctx, "b@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("%d events left for b, want none", len(events))
+	}
+	events, err = publishedDB.PendingEvents(ctx, "a@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("%d events left for a, want 1", len(events))
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+)
+
+// ctxKey is the type of the context keys owned by this package
+type ctxKey int
+
+const claimsKey ctxKey = 1
+
+// Roles known to the service
+const (
+	RoleAdmin = "admin"
+)
+
+// Claims identifies the caller of a request, they are only ever read from a verified token
+type Claims struct {
+	Email string
+	Role  string
+}
+
+// IsAdmin reports whether the caller has the admin role
+func (c Claims) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
+// WithClaims returns a copy of ctx carrying the caller claims
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// FromContext returns the caller claims stored in ctx
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(Claims)
+	return claims, ok
+}
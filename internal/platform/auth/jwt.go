@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// path of the signing keys under the authority
+	jwksPath = ".well-known/jwks.json"
+	// the only signing algorithm accepted
+	algRS256 = "RS256"
+	// clock difference allowed with the authority
+	leeway = 30 * time.Second
+	// shortest time between two fetches of the signing keys, tokens
+	// signed with an unknown key fetch them again at most this often
+	refreshInterval = time.Minute
+	// time allowed to fetch the signing keys
+	fetchTimeout = 10 * time.Second
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, badly signed,
+	// expired, or was not issued by the authority for the audience
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Verifier verifies the JWT tokens an authority issues for an audience and reads the
+// caller claims from them. Tokens must be signed with RS256 by one of the keys the
+// authority publishes at <authority>/.well-known/jwks.json, they are fetched lazily
+type Verifier struct {
+	authority  string
+	audience   string
+	emailClaim string
+	roleClaim  string
+	jwksURL    string
+	client     *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier returns a verifier of the tokens authority issues for audience,
+// the email and role of callers are read from the emailClaim and roleClaim claims
+func NewVerifier(authority, audience, emailClaim, roleClaim string) *Verifier {
+	return &Verifier{
+		authority:  authority,
+		audience:   audience,
+		emailClaim: emailClaim,
+		roleClaim:  roleClaim,
+		jwksURL:    strings.TrimSuffix(authority, "/") + "/" + jwksPath,
+		client:     &http.Client{Timeout: fetchTimeout},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature, issuer, audience and lifetime of token
+// and returns the claims of the caller it was issued to
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "malformed token")
+	}
+
+	var h header
+	err := decodeSegment(parts[0], &h)
+	if err != nil {
+		return Claims{}, err
+	}
+	if h.Alg != algRS256 {
+		return Claims{}, errors.Wrapf(ErrInvalidToken, "unexpected signing algorithm %q", h.Alg)
+	}
+	key, err := v.key(ctx, h.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	if err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "bad signature")
+	}
+
+	var payload map[string]interface{}
+	err = decodeSegment(parts[1], &payload)
+	if err != nil {
+		return Claims{}, err
+	}
+	err = v.checkPayload(payload, time.Now())
+	if err != nil {
+		return Claims{}, err
+	}
+
+	email, _ := payload[v.emailClaim].(string)
+	if email == "" {
+		return Claims{}, errors.Wrapf(ErrInvalidToken, "no %s claim", v.emailClaim)
+	}
+	role, _ := payload[v.roleClaim].(string)
+	return Claims{Email: email, Role: role}, nil
+}
+
+// checkPayload checks the registered claims of a token at now
+func (v *Verifier) checkPayload(payload map[string]interface{}, now time.Time) error {
+	if iss, _ := payload["iss"].(string); iss != v.authority {
+		return errors.Wrapf(ErrInvalidToken, "unexpected issuer %q", iss)
+	}
+	if !hasAudience(payload["aud"], v.audience) {
+		return errors.Wrap(ErrInvalidToken, "unexpected audience")
+	}
+	exp, ok := payload["exp"].(float64)
+	if !ok {
+		return errors.Wrap(ErrInvalidToken, "no expiry")
+	}
+	if now.Add(-leeway).After(time.Unix(int64(exp), 0)) {
+		return errors.Wrap(ErrInvalidToken, "token expired")
+	}
+	if nbf, ok := payload["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.Wrap(ErrInvalidToken, "token not valid yet")
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or a list of them, names audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, _ := a.(string); s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token into v
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.Wrap(ErrInvalidToken, "malformed segment")
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return errors.Wrap(ErrInvalidToken, "malformed segment")
+	}
+	return nil
+}
+
+// key returns the signing key kid, the keys of the authority are fetched
+// again when it is unknown and they were not fetched recently
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < refreshInterval {
+		return nil, errors.Wrapf(ErrInvalidToken, "unknown signing key %q", kid)
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidToken, "unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// jwks is the set of keys an authority signs tokens with
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchKeys fetches the RSA signing keys of the authority by kid
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot fetch signing keys")
+	}
+	res, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot fetch signing keys")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("cannot fetch signing keys : status %d", res.StatusCode)
+	}
+
+	var set jwks
+	err = json.NewDecoder(res.Body).Decode(&set)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode signing keys")
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const testAudience = "https://chat.example.com"
+
+// testAuthority publishes a signing key the way an authority does
+type testAuthority struct {
+	key     *rsa.PrivateKey
+	srv     *httptest.Server
+	fetches int32
+}
+
+func newTestAuthority(t *testing.T) *testAuthority {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &testAuthority{key: key}
+	a.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+jwksPath {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&a.fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(a.srv.Close)
+	return a
+}
+
+func (a *testAuthority) authority() string {
+	return a.srv.URL + "/"
+}
+
+func (a *testAuthority) verifier() *Verifier {
+	return NewVerifier(a.authority(), testAudience, "email", "role")
+}
+
+// claims returns valid claims of email
+func (a *testAuthority) claims(email string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   a.authority(),
+		"aud":   []string{testAudience, "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": email,
+		"role":  RoleAdmin,
+	}
+}
+
+// sign returns a token of claims signed with the key of the authority under kid
+func (a *testAuthority) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := segment(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyReadsTheClaimsOfValidTokens(t *testing.T) {
+	a := newTestAuthority(t)
+	v := a.verifier()
+
+	claims, err := v.Verify(context.Background(), a.sign(t, algRS256, "k1", a.claims("a@x.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "a@x.com" || !claims.IsAdmin() {
+		t.Fatalf("claims = %+v, want a@x.com as admin", claims)
+	}
+
+	// the keys are cached
+	_, err = v.Verify(context.Background(), a.sign(t, algRS256, "k1", a.claims("b@x.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetches := atomic.LoadInt32(&a.fetches); fetches != 1 {
+		t.Fatalf("fetched the keys %d times, want once", fetches)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	a := newTestAuthority(t)
+	other := newTestAuthority(t)
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := a.claims("a@x.com")
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	valid := a.sign(t, algRS256, "k1", a.claims("a@x.com"))
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not.a-token"},
+		{"tampered", valid[:len(valid)-4] + "AAAA"},
+		{"other signer", other.sign(t, algRS256, "k1", a.claims("a@x.com"))},
+		{"unsigned", a.sign(t, "none", "k1", a.claims("a@x.com"))},
+		{"unknown key", a.sign(t, algRS256, "k2", a.claims("a@x.com"))},
+		{"other issuer", a.sign(t, algRS256, "k1", with("iss", "https://evil.example.com/"))},
+		{"other audience", a.sign(t, algRS256, "k1", with("aud", "https://evil.example.com"))},
+		{"expired", a.sign(t, algRS256, "k1", with("exp", time.Now().Add(-time.Hour).Unix()))},
+		{"no expiry", a.sign(t, algRS256, "k1", with("exp", nil))},
+		{"not valid yet", a.sign(t, algRS256, "k1", with("nbf", time.Now().Add(time.Hour).Unix()))},
+		{"no email", a.sign(t, algRS256, "k1", with("email", nil))},
+	}
+	v := a.verifier()
+	for _, tt := range tests {
+		_, err := v.Verify(context.Background(), tt.token)
+		if errors.Cause(err) != ErrInvalidToken {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, ErrInvalidToken)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package blob
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when no blob is stored under the requested key
+	ErrNotFound = errors.New("blob not found")
+)
+
+// Store keeps immutable blobs addressed by the SHA-256 of their content,
+// storing the same content twice keeps a single copy
+type Store interface {
+	// Put stores the content of r and returns its key and size
+	Put(ctx context.Context, r io.Reader) (string, int64, error)
+	// Get opens the blob stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,121 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// uploads are written there first and moved in place once hashed
+	tmpDir = "tmp"
+	// length of a hex encoded SHA-256
+	keyLength = sha256.Size * 2
+)
+
+// FileStore is a Store keeping blobs as files under a directory,
+// blobs are spread over sub directories named after the first key characters
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore keeping its blobs under dir
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(filepath.Join(dir, tmpDir), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create blob directory")
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put stores the content of r and returns its key and size
+func (s *FileStore) Put(ctx context.Context, r io.Reader) (string, int64, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(s.dir, tmpDir), "upload-")
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot create blob")
+	}
+	// removing the temporary file fails once it is moved in place
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(r, h))
+	if err != nil {
+		tmp.Close()
+		return "", 0, errors.Wrap(err, "cannot write blob")
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot write blob")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", 0, err
+	}
+
+	key := hex.EncodeToString(h.Sum(nil))
+	path := s.path(key)
+	// identical content is already stored
+	if _, err := os.Stat(path); err == nil {
+		return key, size, nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot create blob directory")
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot store blob")
+	}
+	return key, size, nil
+}
+
+// Get opens the blob stored under key
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, errors.Wrap(ErrNotFound, key)
+	}
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrap(ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open blob")
+	}
+	return f, nil
+}
+
+// Delete removes the blob stored under key
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return errors.Wrap(ErrNotFound, key)
+	}
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return errors.Wrap(ErrNotFound, key)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot delete blob")
+	}
+	return nil
+}
+
+// path returns the file a blob is stored in
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+// validKey reports whether key is a hex encoded SHA-256,
+// keys are used in paths so nothing else is accepted
+func validKey(key string) bool {
+	if len(key) != keyLength {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
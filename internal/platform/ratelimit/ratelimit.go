@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// how often buckets that filled up again are dropped
+	cleanupInterval = time.Minute
+)
+
+// Rate is the number of calls allowed per second once the burst is spent
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// bucket holds the tokens of one key, refilled lazily from the time it was last used
+type bucket struct {
+	tokens float64
+	at     time.Time
+	rate   Rate
+}
+
+// Limiter is a token bucket rate limiter keyed by caller, the rate of
+// a caller depends on their role
+type Limiter struct {
+	mu       sync.Mutex
+	rates    map[string]Rate
+	fallback Rate
+	buckets  map[string]*bucket
+	cleaned  time.Time
+}
+
+// NewLimiter returns a limiter allowing the rates keyed by role,
+// roles that are missing get the fallback rate
+func NewLimiter(rates map[string]Rate, fallback Rate) *Limiter {
+	return &Limiter{
+		rates:    rates,
+		fallback: fallback,
+		buckets:  make(map[string]*bucket),
+		cleaned:  time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key and reports whether there was one,
+// when there was not it returns how long to wait until there is
+func (l *Limiter) Allow(key, role string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	rate, ok := l.rates[role]
+	if !ok {
+		rate = l.fallback
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate {
+		b = &bucket{tokens: float64(rate.Burst), at: now, rate: rate}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rate.PerSecond <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+	wait := (1 - b.tokens) / rate.PerSecond
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// refill adds the tokens earned since the bucket was last used
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.rate.Burst), b.tokens+now.Sub(b.at).Seconds()*b.rate.PerSecond)
+	b.at = now
+}
+
+// cleanup drops the buckets that filled up again, they are
+// the same as new ones. Must be called with the lock held
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.cleaned) < cleanupInterval {
+		return
+	}
+	l.cleaned = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
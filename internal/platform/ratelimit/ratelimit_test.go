@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowSpendsTheBurstThenWaits(t *testing.T) {
+	l := NewLimiter(nil, Rate{PerSecond: 2, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a@x.com", ""); !ok {
+			t.Fatalf("call %d of the burst refused", i)
+		}
+	}
+	ok, wait := l.Allow("a@x.com", "")
+	if ok {
+		t.Fatal("call past the burst allowed")
+	}
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Fatalf("wait = %v, want up to 500ms", wait)
+	}
+}
+
+func TestAllowUsesTheRateOfTheRole(t *testing.T) {
+	l := NewLimiter(map[string]Rate{"admin": {PerSecond: 1, Burst: 5}}, Rate{PerSecond: 1, Burst: 1})
+
+	allowed := func(key, role string) int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if ok, _ := l.Allow(key, role); ok {
+				n++
+			}
+		}
+		return n
+	}
+	if n := allowed("admin@x.com", "admin"); n != 5 {
+		t.Fatalf("admin allowed %d calls, want 5", n)
+	}
+	if n := allowed("user@x.com", "user"); n != 1 {
+		t.Fatalf("user allowed %d calls, want 1", n)
+	}
+}
+
+func TestRefillAndCleanup(t *testing.T) {
+	l := NewLimiter(nil, Rate{PerSecond: 10, Burst: 1})
+	l.Allow("a@x.com", "")
+
+	b := l.buckets["a@x.com"]
+	b.refill(b.at.Add(50 * time.Millisecond))
+	if b.tokens < 0.49 || b.tokens > 0.51 {
+		t.Fatalf("tokens after 50ms = %v, want 0.5", b.tokens)
+	}
+
+	// full buckets are dropped
+	l.cleanup(l.cleaned.Add(cleanupInterval))
+	if _, ok := l.buckets["a@x.com"]; ok {
+		t.Fatal("full bucket kept")
+	}
+}
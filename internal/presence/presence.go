@@ -0,0 +1,336 @@
+package presence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// instances share the users connected to them on this topic
+	syncTopic = "presence.sync"
+	// an instance is considered gone after missing that many heartbeats
+	missedHeartbeats = 3
+	// Errors
+	errPresenceSync = "presence sync error"
+)
+
+// snapshot is what an instance shares with the others, the full set of
+// users connected to it. An empty snapshot is sent when an instance stops
+type snapshot struct {
+	Instance string    `json:"instance"`
+	Emails   []string  `json:"emails"`
+	At       time.Time `json:"at"`
+}
+
+// instance is what is known of another service instance
+type instance struct {
+	emails map[string]bool
+	seenAt time.Time
+}
+
+// Tracker tracks which users are online from the lifetime of their stream connections,
+// users connected to several devices or instances stay online until the last one goes away
+type Tracker struct {
+	id       string
+	mb       broker.MessageBroker
+	interval time.Duration
+	onChange func(proto.Presence)
+
+	mu sync.Mutex
+	// connections per email on this instance
+	local map[string]int
+	// other instances keyed by their id
+	remote map[string]*instance
+	// last time users went offline
+	lastSeen map[string]time.Time
+
+	quitCh chan chan struct{}
+	syncCh chan struct{}
+}
+
+// NewTracker returns a presence tracker sharing its state with the other
+// instances through the broker every interval
+func NewTracker(mb broker.MessageBroker, interval time.Duration) (*Tracker, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create presence instance id")
+	}
+
+	return &Tracker{
+		id:       hex.EncodeToString(b),
+		mb:       mb,
+		interval: interval,
+		onChange: func(proto.Presence) {},
+		local:    make(map[string]int),
+		remote:   make(map[string]*instance),
+		lastSeen: make(map[string]time.Time),
+		quitCh:   make(chan chan struct{}),
+		syncCh:   make(chan struct{}, 1),
+	}, nil
+}
+
+// OnChange registers the func called when a user goes online or offline,
+// it must be set before Run
+func (t *Tracker) OnChange(fn func(proto.Presence)) {
+	t.onChange = fn
+}
+
+// Run shares this instance presence with the others until Stop is called
+func (t *Tracker) Run() error {
+	defer func() {
+		log.Println("Presence tracker closed")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	syncMsgCh := make(chan []byte, 512)
+	syncErrCh := make(chan error, 1)
+	go t.mb.Sub(ctx, syncTopic, syncMsgCh, syncErrCh)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	t.publish()
+
+	for {
+		select {
+		case err := <-syncErrCh:
+			return errors.Wrap(err, errPresenceSync)
+		case m, open := <-syncMsgCh:
+			if !open {
+				return errors.New(errPresenceSync)
+			}
+			t.receive(m)
+		case <-t.syncCh:
+			t.publish()
+		case <-ticker.C:
+			t.publish()
+			t.prune()
+		case q := <-t.quitCh:
+			// let the other instances know right away that
+			// the users connected here are gone
+			t.mu.Lock()
+			t.local = make(map[string]int)
+			t.mu.Unlock()
+			t.publish()
+			close(q)
+			return nil
+		}
+	}
+}
+
+// Stop stops sharing presence and blocks until Run returns
+func (t *Tracker) Stop() {
+	q := make(chan struct{})
+	t.quitCh <- q
+	<-q
+}
+
+// Connect records a new stream connection of email on this instance
+func (t *Tracker) Connect(email string) {
+	t.mu.Lock()
+	t.local[email]++
+	wentOnline := t.local[email] == 1 && !t.onlineElsewhere(email)
+	t.mu.Unlock()
+
+	t.requestSync()
+	if wentOnline {
+		t.onChange(proto.Presence{Email: email, Online: true})
+	}
+}
+
+// Disconnect records the end of a stream connection of email on this instance
+func (t *Tracker) Disconnect(email string) {
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	if t.local[email] > 0 {
+		t.local[email]--
+	}
+	wentOffline := t.local[email] == 0 && !t.onlineElsewhere(email)
+	if t.local[email] == 0 {
+		delete(t.local, email)
+	}
+	if wentOffline {
+		t.lastSeen[email] = now
+	}
+	t.mu.Unlock()
+
+	t.requestSync()
+	if wentOffline {
+		t.onChange(proto.Presence{Email: email, Online: false, LastSeenAt: &now})
+	}
+}
+
+// Forget drops the last seen time of email, contacts are not told
+func (t *Tracker) Forget(email string) {
+	t.mu.Lock()
+	delete(t.lastSeen, email)
+	t.mu.Unlock()
+}
+
+// Get returns the presence of every email
+func (t *Tracker) Get(emails []string) []proto.Presence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]proto.Presence, len(emails))
+	for i, email := range emails {
+		res[i] = t.presence(email)
+	}
+	return res
+}
+
+// presence returns the presence of a user. Must be called with the lock held
+func (t *Tracker) presence(email string) proto.Presence {
+	p := proto.Presence{
+		Email:  email,
+		Online: t.local[email] > 0 || t.onlineElsewhere(email),
+	}
+	if seenAt, ok := t.lastSeen[email]; ok && !p.Online {
+		p.LastSeenAt = &seenAt
+	}
+	return p
+}
+
+// onlineElsewhere reports whether email is connected to another instance.
+// Must be called with the lock held
+func (t *Tracker) onlineElsewhere(email string) bool {
+	for _, in := range t.remote {
+		if in.emails[email] {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSync asks Run to share the local state without waiting for the next tick
+func (t *Tracker) requestSync() {
+	select {
+	case t.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+// publish shares the users connected to this instance
+func (t *Tracker) publish() {
+	t.mu.Lock()
+	s := snapshot{
+		Instance: t.id,
+		Emails:   make([]string, 0, len(t.local)),
+		At:       time.Now().UTC(),
+	}
+	for email := range t.local {
+		s.Emails = append(s.Emails, email)
+	}
+	t.mu.Unlock()
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("%s: cannot marshal snapshot: %v\n", errPresenceSync, err)
+		return
+	}
+	err = t.mb.Pub(syncTopic, b)
+	if err != nil {
+		log.Printf("%s: %v\n", errPresenceSync, err)
+	}
+}
+
+// receive records the snapshot of another instance, users that left it are
+// given a last seen time, their own instance lets their contacts know
+func (t *Tracker) receive(m []byte) {
+	var s snapshot
+	err := json.Unmarshal(m, &s)
+	if err != nil {
+		log.Printf("%s: cannot read snapshot: %v\n", errPresenceSync, err)
+		return
+	}
+	if s.Instance == t.id {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, ok := t.remote[s.Instance]
+	current := &instance{emails: make(map[string]bool, len(s.Emails)), seenAt: time.Now().UTC()}
+	for _, email := range s.Emails {
+		current.emails[email] = true
+	}
+	if len(s.Emails) == 0 {
+		delete(t.remote, s.Instance)
+	} else {
+		t.remote[s.Instance] = current
+	}
+
+	if ok {
+		for email := range previous.emails {
+			if !current.emails[email] {
+				t.lastSeen[email] = s.At
+			}
+		}
+	}
+}
+
+// prune forgets the instances that stopped sending heartbeats, the users only
+// connected to them go offline. The instance with the lowest id among the live
+// ones lets their contacts know so that they are told only once
+func (t *Tracker) prune() {
+	now := time.Now().UTC()
+	deadline := now.Add(-missedHeartbeats * t.interval)
+
+	var changes []proto.Presence
+
+	t.mu.Lock()
+	var gone []*instance
+	for id, in := range t.remote {
+		if in.seenAt.Before(deadline) {
+			gone = append(gone, in)
+			delete(t.remote, id)
+		}
+	}
+	leader := t.isLeader()
+	offline := make(map[string]bool)
+	for _, in := range gone {
+		for email := range in.emails {
+			if offline[email] || t.local[email] > 0 || t.onlineElsewhere(email) {
+				continue
+			}
+			offline[email] = true
+			t.lastSeen[email] = now
+			if leader {
+				seenAt := now
+				changes = append(changes, proto.Presence{Email: email, Online: false, LastSeenAt: &seenAt})
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, p := range changes {
+		t.onChange(p)
+	}
+}
+
+// isLeader reports whether this instance has the lowest id among the live ones.
+// Must be called with the lock held
+func (t *Tracker) isLeader() bool {
+	ids := []string{t.id}
+	for id := range t.remote {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids[0] == t.id
+}
@@ -1,4 +1,4 @@
-// chat 0.0.1 a4fdc6d6993d7313905a53c2e256053b6a0b3ee2
+// chat 0.0.1 08c68c55f0ddd285c5d2b32ab6af2dcadbde258a
 // --
 // This file has been generated by https://github.com/webrpc/webrpc using gen/golang
 // Do not edit by hand. Update your webrpc schema and re-generate.
@@ -30,7 +30,7 @@ func WebRPCSchemaVersion() string {
 
 // Schema hash generated from your RIDL schema
 func WebRPCSchemaHash() string {
-	return "a4fdc6d6993d7313905a53c2e256053b6a0b3ee2"
+	return "08c68c55f0ddd285c5d2b32ab6af2dcadbde258a"
 }
 
 //
@@ -45,22 +45,227 @@ type Version struct {
 }
 
 type ChatMessage struct {
-	FromEmail   string     `json:"fromEmail,omitempty"`
-	ToEmail     string     `json:"toEmail,omitempty"`
-	MessageUUID string     `json:"messageUUID,omitempty"`
-	PK          string     `json:"pK,omitempty"`
-	SK          string     `json:"sK,omitempty"`
-	MessageText string     `json:"messageText"`
-	Seen        bool       `json:"seen"`
-	Delivered   bool       `json:"delivered"`
-	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
-	Version     string     `json:"version"`
+	FromEmail         string        `json:"fromEmail,omitempty" validate:"required,email"`
+	ToEmail           string        `json:"toEmail,omitempty" validate:"required,email,nefield=FromEmail"`
+	MessageUUID       string        `json:"messageUUID,omitempty"`
+	RoomID            string        `json:"roomID,omitempty"`
+	PK                string        `json:"pK,omitempty"`
+	SK                string        `json:"sK,omitempty"`
+	MessageText       string        `json:"messageText" validate:"required_without=AttachmentIDs"`
+	Delivered         bool          `json:"delivered"`
+	UpdatedAt         *time.Time    `json:"updatedAt,omitempty"`
+	Deleted           bool          `json:"deleted"`
+	Version           string        `json:"version"`
+	ParentMessageUUID string        `json:"parentMessageUUID,omitempty"`
+	ReplyCount        int           `json:"replyCount,omitempty"`
+	LastReplyAt       *time.Time    `json:"lastReplyAt,omitempty"`
+	Reactions         []*Reaction   `json:"reactions,omitempty"`
+	SendAt            *time.Time    `json:"sendAt,omitempty"`
+	AttachmentIDs     []string      `json:"attachmentIDs,omitempty" validate:"max=10,unique,dive,uuid"`
+	ExpiresAt         *time.Time    `json:"expiresAt,omitempty"`
+	Muted             bool          `json:"muted,omitempty"`
+	Annotations       []*Annotation `json:"annotations,omitempty"`
+	Mentions          []*Mention    `json:"mentions,omitempty"`
+}
+
+type Mention struct {
+	Email  string `json:"email"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+type Annotation struct {
+	Stage string `json:"stage"`
+	Note  string `json:"note"`
+}
+
+type Reaction struct {
+	Emoji  string   `json:"emoji"`
+	Count  int      `json:"count"`
+	Emails []string `json:"emails"`
+}
+
+type MessageReaction struct {
+	MessageUUID string      `json:"messageUUID"`
+	Email       string      `json:"email"`
+	Emoji       string      `json:"emoji"`
+	Reacted     bool        `json:"reacted"`
+	Reactions   []*Reaction `json:"reactions"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+type ThreadReply struct {
+	Reply       *ChatMessage `json:"reply"`
+	ReplyCount  int          `json:"replyCount"`
+	LastReplyAt time.Time    `json:"lastReplyAt"`
+}
+
+type Attachment struct {
+	AttachmentID string     `json:"attachmentID"`
+	OwnerEmail   string     `json:"ownerEmail"`
+	FileName     string     `json:"fileName"`
+	ContentType  string     `json:"contentType"`
+	Size         int64      `json:"size"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	Thumbnail    *Thumbnail `json:"thumbnail,omitempty"`
+}
+
+type Thumbnail struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+type Usage struct {
+	UsedBytes   int64 `json:"usedBytes"`
+	QuotaBytes  int64 `json:"quotaBytes"`
+	Attachments int   `json:"attachments"`
+}
+
+type SearchResult struct {
+	Message *ChatMessage   `json:"message"`
+	Score   float64        `json:"score"`
+	Snippet []*SnippetPart `json:"snippet"`
+}
+
+type SnippetPart struct {
+	Text      string `json:"text"`
+	Highlight bool   `json:"highlight"`
+}
+
+type Room struct {
+	RoomID    string    `json:"roomID"`
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ConversationSettings struct {
+	Emails     []string   `json:"emails,omitempty"`
+	RoomID     string     `json:"roomID,omitempty"`
+	MessageTTL int64      `json:"messageTTL"`
+	UpdatedBy  string     `json:"updatedBy,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
+
+type BlockedUser struct {
+	Email     string    `json:"email"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+type DataExport struct {
+	ExportID       string     `json:"exportID"`
+	Email          string     `json:"email"`
+	Status         string     `json:"status"`
+	WrittenRecords int        `json:"writtenRecords"`
+	TotalRecords   int        `json:"totalRecords"`
+	Size           int64      `json:"size"`
+	Failure        *string    `json:"failure,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+}
+
+type UserErasure struct {
+	ErasureID         string     `json:"erasureID"`
+	Email             string     `json:"email"`
+	RequestedBy       string     `json:"requestedBy"`
+	Status            string     `json:"status"`
+	CompletedSteps    []string   `json:"completedSteps"`
+	MessagesErased    int        `json:"messagesErased"`
+	AttachmentsErased int        `json:"attachmentsErased"`
+	BlobsDeleted      int        `json:"blobsDeleted"`
+	StartedAt         time.Time  `json:"startedAt"`
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+}
+
+type ChatReceipt struct {
+	MessageUUID string    `json:"messageUUID"`
+	FromEmail   string    `json:"fromEmail"`
+	ToEmail     string    `json:"toEmail"`
+	Delivered   bool      `json:"delivered"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type ScheduledMessageFailure struct {
+	Message  *ChatMessage `json:"message"`
+	Reason   string       `json:"reason"`
+	FailedAt time.Time    `json:"failedAt"`
+}
+
+type ReadCursor struct {
+	Email       string    `json:"email"`
+	WithEmail   *string   `json:"withEmail,omitempty"`
+	RoomID      *string   `json:"roomID,omitempty"`
+	MessageUUID string    `json:"messageUUID"`
+	UnreadCount int       `json:"unreadCount"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type Typing struct {
+	FromEmail string     `json:"fromEmail"`
+	ToEmail   string     `json:"toEmail"`
+	Typing    bool       `json:"typing"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type Presence struct {
+	Email      string     `json:"email"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+type ConversationSummary struct {
+	WithEmail          string    `json:"withEmail"`
+	LastMessageUUID    string    `json:"lastMessageUUID"`
+	LastMessageFrom    string    `json:"lastMessageFrom"`
+	LastMessagePreview string    `json:"lastMessagePreview"`
+	LastActivityAt     time.Time `json:"lastActivityAt"`
+	UnreadCount        int       `json:"unreadCount"`
 }
 
 type Chat interface {
 	Ping(ctx context.Context) (bool, error)
 	Version(ctx context.Context) (*Version, error)
 	CreateChatMessage(ctx context.Context, req *ChatMessage) (bool, error)
+	ListConversation(ctx context.Context, withEmail string, cursor string, limit int) ([]*ChatMessage, string, error)
+	ListConversations(ctx context.Context) ([]*ConversationSummary, error)
+	MarkDelivered(ctx context.Context, messageUUIDs []string) (bool, error)
+	SetReadCursor(ctx context.Context, withEmail *string, roomID *string, messageUUID string) (*ReadCursor, error)
+	EditChatMessage(ctx context.Context, messageUUID string, messageText string, version string) (*ChatMessage, error)
+	GetMessageHistory(ctx context.Context, messageUUID string) ([]*ChatMessage, error)
+	DeleteChatMessage(ctx context.Context, messageUUID string) (*ChatMessage, error)
+	HideChatMessage(ctx context.Context, messageUUID string) (bool, error)
+	CreateRoom(ctx context.Context, name string, members []string) (*Room, error)
+	AddMember(ctx context.Context, roomID string, email string) (*Room, error)
+	RemoveMember(ctx context.Context, roomID string, email string) (*Room, error)
+	ListRooms(ctx context.Context) ([]*Room, error)
+	SendRoomMessage(ctx context.Context, roomID string, messageText string, parentMessageUUID *string, attachmentIDs []string) (*ChatMessage, error)
+	ListRoomMessages(ctx context.Context, roomID string, cursor string, limit int) ([]*ChatMessage, string, error)
+	SetTyping(ctx context.Context, toEmail string, typing bool) (bool, error)
+	GetPresence(ctx context.Context, emails []string) ([]*Presence, error)
+	ListThread(ctx context.Context, parentMessageUUID string) (*ChatMessage, []*ChatMessage, error)
+	AddReaction(ctx context.Context, messageUUID string, emoji string) (*ChatMessage, error)
+	RemoveReaction(ctx context.Context, messageUUID string, emoji string) (*ChatMessage, error)
+	GetAttachments(ctx context.Context, attachmentIDs []string) ([]*Attachment, error)
+	GetUsage(ctx context.Context) (*Usage, error)
+	SearchMessages(ctx context.Context, query string, withEmail *string, before *time.Time, after *time.Time, limit int) ([]*SearchResult, error)
+	ListScheduledMessages(ctx context.Context) ([]*ChatMessage, error)
+	CancelScheduledMessage(ctx context.Context, messageUUID string) (*ChatMessage, error)
+	GetConversationSettings(ctx context.Context, withEmail *string, roomID *string) (*ConversationSettings, error)
+	SetMessageTTL(ctx context.Context, withEmail *string, roomID *string, messageTTL int64) (*ConversationSettings, error)
+	BlockUser(ctx context.Context, email string) (bool, error)
+	UnblockUser(ctx context.Context, email string) (bool, error)
+	ListBlocked(ctx context.Context) ([]*BlockedUser, error)
+	MuteConversation(ctx context.Context, withEmail *string, roomID *string, muted bool) (bool, error)
+	ListMentions(ctx context.Context, cursor string, limit int) ([]*ChatMessage, string, error)
+	ExportMyData(ctx context.Context) (*DataExport, error)
+	GetExportStatus(ctx context.Context, exportID string) (*DataExport, error)
+	EraseUser(ctx context.Context, email string) (*UserErasure, error)
 }
 
 var WebRPCServices = map[string][]string{
@@ -68,6 +273,40 @@ var WebRPCServices = map[string][]string{
 		"Ping",
 		"Version",
 		"CreateChatMessage",
+		"ListConversation",
+		"ListConversations",
+		"MarkDelivered",
+		"SetReadCursor",
+		"EditChatMessage",
+		"GetMessageHistory",
+		"DeleteChatMessage",
+		"HideChatMessage",
+		"CreateRoom",
+		"AddMember",
+		"RemoveMember",
+		"ListRooms",
+		"SendRoomMessage",
+		"ListRoomMessages",
+		"SetTyping",
+		"GetPresence",
+		"ListThread",
+		"AddReaction",
+		"RemoveReaction",
+		"GetAttachments",
+		"GetUsage",
+		"SearchMessages",
+		"ListScheduledMessages",
+		"CancelScheduledMessage",
+		"GetConversationSettings",
+		"SetMessageTTL",
+		"BlockUser",
+		"UnblockUser",
+		"ListBlocked",
+		"MuteConversation",
+		"ListMentions",
+		"ExportMyData",
+		"GetExportStatus",
+		"EraseUser",
 	},
 }
 
@@ -111,6 +350,108 @@ func (s *chatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "/rpc/Chat/CreateChatMessage":
 		s.serveCreateChatMessage(ctx, w, r)
 		return
+	case "/rpc/Chat/ListConversation":
+		s.serveListConversation(ctx, w, r)
+		return
+	case "/rpc/Chat/ListConversations":
+		s.serveListConversations(ctx, w, r)
+		return
+	case "/rpc/Chat/MarkDelivered":
+		s.serveMarkDelivered(ctx, w, r)
+		return
+	case "/rpc/Chat/SetReadCursor":
+		s.serveSetReadCursor(ctx, w, r)
+		return
+	case "/rpc/Chat/EditChatMessage":
+		s.serveEditChatMessage(ctx, w, r)
+		return
+	case "/rpc/Chat/GetMessageHistory":
+		s.serveGetMessageHistory(ctx, w, r)
+		return
+	case "/rpc/Chat/DeleteChatMessage":
+		s.serveDeleteChatMessage(ctx, w, r)
+		return
+	case "/rpc/Chat/HideChatMessage":
+		s.serveHideChatMessage(ctx, w, r)
+		return
+	case "/rpc/Chat/CreateRoom":
+		s.serveCreateRoom(ctx, w, r)
+		return
+	case "/rpc/Chat/AddMember":
+		s.serveAddMember(ctx, w, r)
+		return
+	case "/rpc/Chat/RemoveMember":
+		s.serveRemoveMember(ctx, w, r)
+		return
+	case "/rpc/Chat/ListRooms":
+		s.serveListRooms(ctx, w, r)
+		return
+	case "/rpc/Chat/SendRoomMessage":
+		s.serveSendRoomMessage(ctx, w, r)
+		return
+	case "/rpc/Chat/ListRoomMessages":
+		s.serveListRoomMessages(ctx, w, r)
+		return
+	case "/rpc/Chat/SetTyping":
+		s.serveSetTyping(ctx, w, r)
+		return
+	case "/rpc/Chat/GetPresence":
+		s.serveGetPresence(ctx, w, r)
+		return
+	case "/rpc/Chat/ListThread":
+		s.serveListThread(ctx, w, r)
+		return
+	case "/rpc/Chat/AddReaction":
+		s.serveAddReaction(ctx, w, r)
+		return
+	case "/rpc/Chat/RemoveReaction":
+		s.serveRemoveReaction(ctx, w, r)
+		return
+	case "/rpc/Chat/GetAttachments":
+		s.serveGetAttachments(ctx, w, r)
+		return
+	case "/rpc/Chat/GetUsage":
+		s.serveGetUsage(ctx, w, r)
+		return
+	case "/rpc/Chat/SearchMessages":
+		s.serveSearchMessages(ctx, w, r)
+		return
+	case "/rpc/Chat/ListScheduledMessages":
+		s.serveListScheduledMessages(ctx, w, r)
+		return
+	case "/rpc/Chat/CancelScheduledMessage":
+		s.serveCancelScheduledMessage(ctx, w, r)
+		return
+	case "/rpc/Chat/GetConversationSettings":
+		s.serveGetConversationSettings(ctx, w, r)
+		return
+	case "/rpc/Chat/SetMessageTTL":
+		s.serveSetMessageTTL(ctx, w, r)
+		return
+	case "/rpc/Chat/BlockUser":
+		s.serveBlockUser(ctx, w, r)
+		return
+	case "/rpc/Chat/UnblockUser":
+		s.serveUnblockUser(ctx, w, r)
+		return
+	case "/rpc/Chat/ListBlocked":
+		s.serveListBlocked(ctx, w, r)
+		return
+	case "/rpc/Chat/MuteConversation":
+		s.serveMuteConversation(ctx, w, r)
+		return
+	case "/rpc/Chat/ListMentions":
+		s.serveListMentions(ctx, w, r)
+		return
+	case "/rpc/Chat/ExportMyData":
+		s.serveExportMyData(ctx, w, r)
+		return
+	case "/rpc/Chat/GetExportStatus":
+		s.serveGetExportStatus(ctx, w, r)
+		return
+	case "/rpc/Chat/EraseUser":
+		s.serveEraseUser(ctx, w, r)
+		return
 	default:
 		err := Errorf(ErrBadRoute, "no handler for path %q", r.URL.Path)
 		RespondWithError(w, err)
@@ -184,14 +525,2321 @@ func (s *chatServer) serveVersion(ctx context.Context, w http.ResponseWriter, r
 		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
 		RespondWithError(w, err)
 	}
-}
-
-func (s *chatServer) serveVersionJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	var err error
-	ctx = context.WithValue(ctx, MethodNameCtxKey, "Version")
+}
+
+func (s *chatServer) serveVersionJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "Version")
+
+	// Call service method
+	var ret0 *Version
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.Version(ctx)
+	}()
+	respContent := struct {
+		Ret0 *Version `json:"version"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveCreateChatMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveCreateChatMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveCreateChatMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "CreateChatMessage")
+	reqContent := struct {
+		Arg0 *ChatMessage `json:"req"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.CreateChatMessage(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"res"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListConversation(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListConversationJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListConversationJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListConversation")
+	reqContent := struct {
+		Arg0 string `json:"withEmail"`
+		Arg1 string `json:"cursor"`
+		Arg2 int    `json:"limit"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*ChatMessage
+	var ret1 string
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, ret1, err = s.Chat.ListConversation(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{ret0, ret1}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListConversations(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListConversationsJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListConversationsJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListConversations")
+
+	// Call service method
+	var ret0 []*ConversationSummary
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.ListConversations(ctx)
+	}()
+	respContent := struct {
+		Ret0 []*ConversationSummary `json:"conversations"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveMarkDelivered(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveMarkDeliveredJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveMarkDeliveredJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "MarkDelivered")
+	reqContent := struct {
+		Arg0 []string `json:"messageUUIDs"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.MarkDelivered(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveSetReadCursor(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSetReadCursorJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveSetReadCursorJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "SetReadCursor")
+	reqContent := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 string  `json:"messageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ReadCursor
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.SetReadCursor(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 *ReadCursor `json:"cursor"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveEditChatMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveEditChatMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveEditChatMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "EditChatMessage")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"messageText"`
+		Arg2 string `json:"version"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.EditChatMessage(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetMessageHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetMessageHistoryJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetMessageHistoryJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetMessageHistory")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.GetMessageHistory(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 []*ChatMessage `json:"revisions"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveDeleteChatMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveDeleteChatMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveDeleteChatMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "DeleteChatMessage")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.DeleteChatMessage(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveHideChatMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveHideChatMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveHideChatMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "HideChatMessage")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.HideChatMessage(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveCreateRoom(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveCreateRoomJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveCreateRoomJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "CreateRoom")
+	reqContent := struct {
+		Arg0 string   `json:"name"`
+		Arg1 []string `json:"members"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *Room
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.CreateRoom(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *Room `json:"room"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveAddMember(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveAddMemberJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveAddMemberJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "AddMember")
+	reqContent := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"email"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *Room
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.AddMember(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *Room `json:"room"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveRemoveMember(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveRemoveMemberJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveRemoveMemberJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "RemoveMember")
+	reqContent := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"email"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *Room
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.RemoveMember(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *Room `json:"room"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListRooms(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListRoomsJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListRoomsJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListRooms")
+
+	// Call service method
+	var ret0 []*Room
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.ListRooms(ctx)
+	}()
+	respContent := struct {
+		Ret0 []*Room `json:"rooms"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveSendRoomMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSendRoomMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveSendRoomMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "SendRoomMessage")
+	reqContent := struct {
+		Arg0 string   `json:"roomID"`
+		Arg1 string   `json:"messageText"`
+		Arg2 *string  `json:"parentMessageUUID"`
+		Arg3 []string `json:"attachmentIDs"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.SendRoomMessage(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2, reqContent.Arg3)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListRoomMessages(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListRoomMessagesJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListRoomMessagesJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListRoomMessages")
+	reqContent := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"cursor"`
+		Arg2 int    `json:"limit"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*ChatMessage
+	var ret1 string
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, ret1, err = s.Chat.ListRoomMessages(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{ret0, ret1}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveSetTyping(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSetTypingJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveSetTypingJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "SetTyping")
+	reqContent := struct {
+		Arg0 string `json:"toEmail"`
+		Arg1 bool   `json:"typing"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.SetTyping(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetPresence(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetPresenceJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetPresenceJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetPresence")
+	reqContent := struct {
+		Arg0 []string `json:"emails"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*Presence
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.GetPresence(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 []*Presence `json:"presence"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListThread(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListThreadJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListThreadJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListThread")
+	reqContent := struct {
+		Arg0 string `json:"parentMessageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	var ret1 []*ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, ret1, err = s.Chat.ListThread(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage   `json:"parent"`
+		Ret1 []*ChatMessage `json:"replies"`
+	}{ret0, ret1}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveAddReaction(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveAddReactionJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveAddReactionJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "AddReaction")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"emoji"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.AddReaction(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveRemoveReaction(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveRemoveReactionJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveRemoveReactionJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "RemoveReaction")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"emoji"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.RemoveReaction(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetAttachments(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetAttachmentsJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetAttachmentsJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetAttachments")
+	reqContent := struct {
+		Arg0 []string `json:"attachmentIDs"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*Attachment
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.GetAttachments(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 []*Attachment `json:"attachments"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetUsage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetUsageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetUsageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetUsage")
+
+	// Call service method
+	var ret0 *Usage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.GetUsage(ctx)
+	}()
+	respContent := struct {
+		Ret0 *Usage `json:"usage"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveSearchMessages(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSearchMessagesJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveSearchMessagesJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "SearchMessages")
+	reqContent := struct {
+		Arg0 string     `json:"query"`
+		Arg1 *string    `json:"withEmail"`
+		Arg2 *time.Time `json:"before"`
+		Arg3 *time.Time `json:"after"`
+		Arg4 int        `json:"limit"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*SearchResult
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.SearchMessages(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2, reqContent.Arg3, reqContent.Arg4)
+	}()
+	respContent := struct {
+		Ret0 []*SearchResult `json:"results"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListScheduledMessages(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListScheduledMessagesJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListScheduledMessagesJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListScheduledMessages")
+
+	// Call service method
+	var ret0 []*ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.ListScheduledMessages(ctx)
+	}()
+	respContent := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveCancelScheduledMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveCancelScheduledMessageJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveCancelScheduledMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "CancelScheduledMessage")
+	reqContent := struct {
+		Arg0 string `json:"messageUUID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ChatMessage
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.CancelScheduledMessage(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetConversationSettings(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetConversationSettingsJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetConversationSettingsJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetConversationSettings")
+	reqContent := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ConversationSettings
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.GetConversationSettings(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 *ConversationSettings `json:"settings"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveSetMessageTTL(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSetMessageTTLJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveSetMessageTTLJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "SetMessageTTL")
+	reqContent := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 int64   `json:"messageTTL"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 *ConversationSettings
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.SetMessageTTL(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 *ConversationSettings `json:"settings"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveBlockUser(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveBlockUserJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveBlockUserJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "BlockUser")
+	reqContent := struct {
+		Arg0 string `json:"email"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.BlockUser(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveUnblockUser(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveUnblockUserJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveUnblockUserJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "UnblockUser")
+	reqContent := struct {
+		Arg0 string `json:"email"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.UnblockUser(ctx, reqContent.Arg0)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListBlocked(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListBlockedJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListBlockedJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListBlocked")
+
+	// Call service method
+	var ret0 []*BlockedUser
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.ListBlocked(ctx)
+	}()
+	respContent := struct {
+		Ret0 []*BlockedUser `json:"users"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveMuteConversation(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveMuteConversationJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveMuteConversationJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "MuteConversation")
+	reqContent := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 bool    `json:"muted"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 bool
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.MuteConversation(ctx, reqContent.Arg0, reqContent.Arg1, reqContent.Arg2)
+	}()
+	respContent := struct {
+		Ret0 bool `json:"status"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveListMentions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListMentionsJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveListMentionsJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ListMentions")
+	reqContent := struct {
+		Arg0 string `json:"cursor"`
+		Arg1 int    `json:"limit"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
+
+	// Call service method
+	var ret0 []*ChatMessage
+	var ret1 string
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, ret1, err = s.Chat.ListMentions(ctx, reqContent.Arg0, reqContent.Arg1)
+	}()
+	respContent := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{ret0, ret1}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveExportMyData(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveExportMyDataJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveExportMyDataJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "ExportMyData")
+
+	// Call service method
+	var ret0 *DataExport
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if rr := recover(); rr != nil {
+				RespondWithError(w, ErrorInternal("internal service panic"))
+				panic(rr)
+			}
+		}()
+		ret0, err = s.Chat.ExportMyData(ctx)
+	}()
+	respContent := struct {
+		Ret0 *DataExport `json:"dataExport"`
+	}{ret0}
+
+	if err != nil {
+		RespondWithError(w, err)
+		return
+	}
+	respBody, err := json.Marshal(respContent)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to marshal json response")
+		RespondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBody)
+}
+
+func (s *chatServer) serveGetExportStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetExportStatusJSON(ctx, w, r)
+	default:
+		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+		RespondWithError(w, err)
+	}
+}
+
+func (s *chatServer) serveGetExportStatusJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "GetExportStatus")
+	reqContent := struct {
+		Arg0 string `json:"exportID"`
+	}{}
+
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = WrapError(ErrInternal, err, "failed to read request data")
+		RespondWithError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(reqBody, &reqContent)
+	if err != nil {
+		err = WrapError(ErrInvalidArgument, err, "failed to unmarshal request data")
+		RespondWithError(w, err)
+		return
+	}
 
 	// Call service method
-	var ret0 *Version
+	var ret0 *DataExport
 	func() {
 		defer func() {
 			// In case of a panic, serve a 500 error and then panic.
@@ -200,10 +2848,10 @@ func (s *chatServer) serveVersionJSON(ctx context.Context, w http.ResponseWriter
 				panic(rr)
 			}
 		}()
-		ret0, err = s.Chat.Version(ctx)
+		ret0, err = s.Chat.GetExportStatus(ctx, reqContent.Arg0)
 	}()
 	respContent := struct {
-		Ret0 *Version `json:"version"`
+		Ret0 *DataExport `json:"dataExport"`
 	}{ret0}
 
 	if err != nil {
@@ -222,7 +2870,7 @@ func (s *chatServer) serveVersionJSON(ctx context.Context, w http.ResponseWriter
 	w.Write(respBody)
 }
 
-func (s *chatServer) serveCreateChatMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+func (s *chatServer) serveEraseUser(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	header := r.Header.Get("Content-Type")
 	i := strings.Index(header, ";")
 	if i == -1 {
@@ -231,18 +2879,18 @@ func (s *chatServer) serveCreateChatMessage(ctx context.Context, w http.Response
 
 	switch strings.TrimSpace(strings.ToLower(header[:i])) {
 	case "application/json":
-		s.serveCreateChatMessageJSON(ctx, w, r)
+		s.serveEraseUserJSON(ctx, w, r)
 	default:
 		err := Errorf(ErrBadRoute, "unexpected Content-Type: %q", r.Header.Get("Content-Type"))
 		RespondWithError(w, err)
 	}
 }
 
-func (s *chatServer) serveCreateChatMessageJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+func (s *chatServer) serveEraseUserJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	var err error
-	ctx = context.WithValue(ctx, MethodNameCtxKey, "CreateChatMessage")
+	ctx = context.WithValue(ctx, MethodNameCtxKey, "EraseUser")
 	reqContent := struct {
-		Arg0 *ChatMessage `json:"req"`
+		Arg0 string `json:"email"`
 	}{}
 
 	reqBody, err := ioutil.ReadAll(r.Body)
@@ -261,7 +2909,7 @@ func (s *chatServer) serveCreateChatMessageJSON(ctx context.Context, w http.Resp
 	}
 
 	// Call service method
-	var ret0 bool
+	var ret0 *UserErasure
 	func() {
 		defer func() {
 			// In case of a panic, serve a 500 error and then panic.
@@ -270,10 +2918,10 @@ func (s *chatServer) serveCreateChatMessageJSON(ctx context.Context, w http.Resp
 				panic(rr)
 			}
 		}()
-		ret0, err = s.Chat.CreateChatMessage(ctx, reqContent.Arg0)
+		ret0, err = s.Chat.EraseUser(ctx, reqContent.Arg0)
 	}()
 	respContent := struct {
-		Ret0 bool `json:"res"`
+		Ret0 *UserErasure `json:"erasure"`
 	}{ret0}
 
 	if err != nil {
@@ -315,15 +2963,49 @@ const ChatPathPrefix = "/rpc/Chat/"
 
 type chatClient struct {
 	client HTTPClient
-	urls   [3]string
+	urls   [37]string
 }
 
 func NewChatClient(addr string, client HTTPClient) Chat {
 	prefix := urlBase(addr) + ChatPathPrefix
-	urls := [3]string{
+	urls := [37]string{
 		prefix + "Ping",
 		prefix + "Version",
 		prefix + "CreateChatMessage",
+		prefix + "ListConversation",
+		prefix + "ListConversations",
+		prefix + "MarkDelivered",
+		prefix + "SetReadCursor",
+		prefix + "EditChatMessage",
+		prefix + "GetMessageHistory",
+		prefix + "DeleteChatMessage",
+		prefix + "HideChatMessage",
+		prefix + "CreateRoom",
+		prefix + "AddMember",
+		prefix + "RemoveMember",
+		prefix + "ListRooms",
+		prefix + "SendRoomMessage",
+		prefix + "ListRoomMessages",
+		prefix + "SetTyping",
+		prefix + "GetPresence",
+		prefix + "ListThread",
+		prefix + "AddReaction",
+		prefix + "RemoveReaction",
+		prefix + "GetAttachments",
+		prefix + "GetUsage",
+		prefix + "SearchMessages",
+		prefix + "ListScheduledMessages",
+		prefix + "CancelScheduledMessage",
+		prefix + "GetConversationSettings",
+		prefix + "SetMessageTTL",
+		prefix + "BlockUser",
+		prefix + "UnblockUser",
+		prefix + "ListBlocked",
+		prefix + "MuteConversation",
+		prefix + "ListMentions",
+		prefix + "ExportMyData",
+		prefix + "GetExportStatus",
+		prefix + "EraseUser",
 	}
 	return &chatClient{
 		client: client,
@@ -361,6 +3043,427 @@ func (c *chatClient) CreateChatMessage(ctx context.Context, req *ChatMessage) (b
 	return out.Ret0, err
 }
 
+func (c *chatClient) ListConversation(ctx context.Context, withEmail string, cursor string, limit int) ([]*ChatMessage, string, error) {
+	in := struct {
+		Arg0 string `json:"withEmail"`
+		Arg1 string `json:"cursor"`
+		Arg2 int    `json:"limit"`
+	}{withEmail, cursor, limit}
+	out := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[3], in, &out)
+	return out.Ret0, out.Ret1, err
+}
+
+func (c *chatClient) ListConversations(ctx context.Context) ([]*ConversationSummary, error) {
+	out := struct {
+		Ret0 []*ConversationSummary `json:"conversations"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[4], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) MarkDelivered(ctx context.Context, messageUUIDs []string) (bool, error) {
+	in := struct {
+		Arg0 []string `json:"messageUUIDs"`
+	}{messageUUIDs}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[5], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) SetReadCursor(ctx context.Context, withEmail *string, roomID *string, messageUUID string) (*ReadCursor, error) {
+	in := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 string  `json:"messageUUID"`
+	}{withEmail, roomID, messageUUID}
+	out := struct {
+		Ret0 *ReadCursor `json:"cursor"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[6], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) EditChatMessage(ctx context.Context, messageUUID string, messageText string, version string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"messageText"`
+		Arg2 string `json:"version"`
+	}{messageUUID, messageText, version}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[7], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetMessageHistory(ctx context.Context, messageUUID string) ([]*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+	}{messageUUID}
+	out := struct {
+		Ret0 []*ChatMessage `json:"revisions"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[8], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) DeleteChatMessage(ctx context.Context, messageUUID string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+	}{messageUUID}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[9], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) HideChatMessage(ctx context.Context, messageUUID string) (bool, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+	}{messageUUID}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[10], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) CreateRoom(ctx context.Context, name string, members []string) (*Room, error) {
+	in := struct {
+		Arg0 string   `json:"name"`
+		Arg1 []string `json:"members"`
+	}{name, members}
+	out := struct {
+		Ret0 *Room `json:"room"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[11], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) AddMember(ctx context.Context, roomID string, email string) (*Room, error) {
+	in := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"email"`
+	}{roomID, email}
+	out := struct {
+		Ret0 *Room `json:"room"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[12], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) RemoveMember(ctx context.Context, roomID string, email string) (*Room, error) {
+	in := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"email"`
+	}{roomID, email}
+	out := struct {
+		Ret0 *Room `json:"room"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[13], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListRooms(ctx context.Context) ([]*Room, error) {
+	out := struct {
+		Ret0 []*Room `json:"rooms"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[14], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) SendRoomMessage(ctx context.Context, roomID string, messageText string, parentMessageUUID *string, attachmentIDs []string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string   `json:"roomID"`
+		Arg1 string   `json:"messageText"`
+		Arg2 *string  `json:"parentMessageUUID"`
+		Arg3 []string `json:"attachmentIDs"`
+	}{roomID, messageText, parentMessageUUID, attachmentIDs}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[15], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListRoomMessages(ctx context.Context, roomID string, cursor string, limit int) ([]*ChatMessage, string, error) {
+	in := struct {
+		Arg0 string `json:"roomID"`
+		Arg1 string `json:"cursor"`
+		Arg2 int    `json:"limit"`
+	}{roomID, cursor, limit}
+	out := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[16], in, &out)
+	return out.Ret0, out.Ret1, err
+}
+
+func (c *chatClient) SetTyping(ctx context.Context, toEmail string, typing bool) (bool, error) {
+	in := struct {
+		Arg0 string `json:"toEmail"`
+		Arg1 bool   `json:"typing"`
+	}{toEmail, typing}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[17], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetPresence(ctx context.Context, emails []string) ([]*Presence, error) {
+	in := struct {
+		Arg0 []string `json:"emails"`
+	}{emails}
+	out := struct {
+		Ret0 []*Presence `json:"presence"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[18], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListThread(ctx context.Context, parentMessageUUID string) (*ChatMessage, []*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"parentMessageUUID"`
+	}{parentMessageUUID}
+	out := struct {
+		Ret0 *ChatMessage   `json:"parent"`
+		Ret1 []*ChatMessage `json:"replies"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[19], in, &out)
+	return out.Ret0, out.Ret1, err
+}
+
+func (c *chatClient) AddReaction(ctx context.Context, messageUUID string, emoji string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"emoji"`
+	}{messageUUID, emoji}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[20], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) RemoveReaction(ctx context.Context, messageUUID string, emoji string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+		Arg1 string `json:"emoji"`
+	}{messageUUID, emoji}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[21], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetAttachments(ctx context.Context, attachmentIDs []string) ([]*Attachment, error) {
+	in := struct {
+		Arg0 []string `json:"attachmentIDs"`
+	}{attachmentIDs}
+	out := struct {
+		Ret0 []*Attachment `json:"attachments"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[22], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetUsage(ctx context.Context) (*Usage, error) {
+	out := struct {
+		Ret0 *Usage `json:"usage"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[23], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) SearchMessages(ctx context.Context, query string, withEmail *string, before *time.Time, after *time.Time, limit int) ([]*SearchResult, error) {
+	in := struct {
+		Arg0 string     `json:"query"`
+		Arg1 *string    `json:"withEmail"`
+		Arg2 *time.Time `json:"before"`
+		Arg3 *time.Time `json:"after"`
+		Arg4 int        `json:"limit"`
+	}{query, withEmail, before, after, limit}
+	out := struct {
+		Ret0 []*SearchResult `json:"results"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[24], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListScheduledMessages(ctx context.Context) ([]*ChatMessage, error) {
+	out := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[25], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) CancelScheduledMessage(ctx context.Context, messageUUID string) (*ChatMessage, error) {
+	in := struct {
+		Arg0 string `json:"messageUUID"`
+	}{messageUUID}
+	out := struct {
+		Ret0 *ChatMessage `json:"message"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[26], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetConversationSettings(ctx context.Context, withEmail *string, roomID *string) (*ConversationSettings, error) {
+	in := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+	}{withEmail, roomID}
+	out := struct {
+		Ret0 *ConversationSettings `json:"settings"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[27], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) SetMessageTTL(ctx context.Context, withEmail *string, roomID *string, messageTTL int64) (*ConversationSettings, error) {
+	in := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 int64   `json:"messageTTL"`
+	}{withEmail, roomID, messageTTL}
+	out := struct {
+		Ret0 *ConversationSettings `json:"settings"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[28], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) BlockUser(ctx context.Context, email string) (bool, error) {
+	in := struct {
+		Arg0 string `json:"email"`
+	}{email}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[29], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) UnblockUser(ctx context.Context, email string) (bool, error) {
+	in := struct {
+		Arg0 string `json:"email"`
+	}{email}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[30], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListBlocked(ctx context.Context) ([]*BlockedUser, error) {
+	out := struct {
+		Ret0 []*BlockedUser `json:"users"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[31], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) MuteConversation(ctx context.Context, withEmail *string, roomID *string, muted bool) (bool, error) {
+	in := struct {
+		Arg0 *string `json:"withEmail"`
+		Arg1 *string `json:"roomID"`
+		Arg2 bool    `json:"muted"`
+	}{withEmail, roomID, muted}
+	out := struct {
+		Ret0 bool `json:"status"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[32], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) ListMentions(ctx context.Context, cursor string, limit int) ([]*ChatMessage, string, error) {
+	in := struct {
+		Arg0 string `json:"cursor"`
+		Arg1 int    `json:"limit"`
+	}{cursor, limit}
+	out := struct {
+		Ret0 []*ChatMessage `json:"messages"`
+		Ret1 string         `json:"nextCursor"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[33], in, &out)
+	return out.Ret0, out.Ret1, err
+}
+
+func (c *chatClient) ExportMyData(ctx context.Context) (*DataExport, error) {
+	out := struct {
+		Ret0 *DataExport `json:"dataExport"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[34], nil, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) GetExportStatus(ctx context.Context, exportID string) (*DataExport, error) {
+	in := struct {
+		Arg0 string `json:"exportID"`
+	}{exportID}
+	out := struct {
+		Ret0 *DataExport `json:"dataExport"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[35], in, &out)
+	return out.Ret0, err
+}
+
+func (c *chatClient) EraseUser(ctx context.Context, email string) (*UserErasure, error) {
+	in := struct {
+		Arg0 string `json:"email"`
+	}{email}
+	out := struct {
+		Ret0 *UserErasure `json:"erasure"`
+	}{}
+
+	err := doJSONRequest(ctx, c.client, c.urls[36], in, &out)
+	return out.Ret0, err
+}
+
 // HTTPClient is the interface used by generated clients to send HTTP requests.
 // It is fulfilled by *(net/http).Client, which is sufficient for most users.
 // Users can provide their own implementation for special retry policies.
@@ -711,6 +3814,8 @@ func HTTPStatusFromErrorCode(code ErrorCode) int {
 		return 503 // Service Unavailable
 	case ErrDataLoss:
 		return 500 // Internal Server Error
+	case ErrRateLimited:
+		return 429 // Too Many Requests
 	case ErrNone:
 		return 200 // OK
 	default:
@@ -796,3 +3901,10 @@ var (
 
 	MethodNameCtxKey = &contextKey{"MethodName"}
 )
+
+// Errors of the Chat service
+const (
+	// RateLimited the caller went past the rate of their role, the Retry-After
+	// header and the error cause tell how many seconds to wait
+	ErrRateLimited ErrorCode = "rate limited"
+)
@@ -1,5 +1,5 @@
 /* tslint:disable */
-// chat 0.0.1 a4fdc6d6993d7313905a53c2e256053b6a0b3ee2
+// chat 0.0.1 08c68c55f0ddd285c5d2b32ab6af2dcadbde258a
 // --
 // This file has been generated by https://github.com/webrpc/webrpc using gen/typescript
 // Do not edit by hand. Update your webrpc schema and re-generate.
@@ -11,7 +11,7 @@ export const WebRPCVersion = "v1"
 export const WebRPCSchemaVersion = "0.0.1"
 
 // Schema hash generated from your RIDL schema
-export const WebRPCSchemaHash = "a4fdc6d6993d7313905a53c2e256053b6a0b3ee2"
+export const WebRPCSchemaHash = "08c68c55f0ddd285c5d2b32ab6af2dcadbde258a"
 
 
 //
@@ -28,19 +28,224 @@ export interface ChatMessage {
   fromEmail: string
   toEmail: string
   messageUUID: string
+  roomID: string
   PK: string
   SK: string
   messageText: string
-  seen: boolean
   delivered: boolean
   updatedAt?: string
+  deleted: boolean
   version: string
+  parentMessageUUID: string
+  replyCount: number
+  lastReplyAt?: string
+  reactions: Array<Reaction>
+  sendAt?: string
+  attachmentIDs: Array<string>
+  expiresAt?: string
+  muted: boolean
+  annotations: Array<Annotation>
+  mentions: Array<Mention>
+}
+
+export interface Mention {
+  email: string
+  offset: number
+  length: number
+}
+
+export interface Annotation {
+  stage: string
+  note: string
+}
+
+export interface Reaction {
+  emoji: string
+  count: number
+  emails: Array<string>
+}
+
+export interface MessageReaction {
+  messageUUID: string
+  email: string
+  emoji: string
+  reacted: boolean
+  reactions: Array<Reaction>
+  updatedAt: string
+}
+
+export interface ThreadReply {
+  reply: ChatMessage
+  replyCount: number
+  lastReplyAt: string
+}
+
+export interface Attachment {
+  attachmentID: string
+  ownerEmail: string
+  fileName: string
+  contentType: string
+  size: number
+  createdAt: string
+  width: number
+  height: number
+  thumbnail?: Thumbnail
+}
+
+export interface Thumbnail {
+  width: number
+  height: number
+  contentType: string
+  size: number
+}
+
+export interface Usage {
+  usedBytes: number
+  quotaBytes: number
+  attachments: number
+}
+
+export interface SearchResult {
+  message: ChatMessage
+  score: number
+  snippet: Array<SnippetPart>
+}
+
+export interface SnippetPart {
+  text: string
+  highlight: boolean
+}
+
+export interface Room {
+  roomID: string
+  name: string
+  members: Array<string>
+  createdBy: string
+  createdAt: string
+  updatedAt: string
+}
+
+export interface ConversationSettings {
+  emails: Array<string>
+  roomID: string
+  messageTTL: number
+  updatedBy: string
+  updatedAt?: string
+}
+
+export interface BlockedUser {
+  email: string
+  blockedAt: string
+}
+
+export interface DataExport {
+  exportID: string
+  email: string
+  status: string
+  writtenRecords: number
+  totalRecords: number
+  size: number
+  failure?: string
+  createdAt: string
+  completedAt?: string
+}
+
+export interface UserErasure {
+  erasureID: string
+  email: string
+  requestedBy: string
+  status: string
+  completedSteps: Array<string>
+  messagesErased: number
+  attachmentsErased: number
+  blobsDeleted: number
+  startedAt: string
+  completedAt?: string
+}
+
+export interface ChatReceipt {
+  messageUUID: string
+  fromEmail: string
+  toEmail: string
+  delivered: boolean
+  updatedAt: string
+}
+
+export interface ScheduledMessageFailure {
+  message: ChatMessage
+  reason: string
+  failedAt: string
+}
+
+export interface ReadCursor {
+  email: string
+  withEmail?: string
+  roomID?: string
+  messageUUID: string
+  unreadCount: number
+  updatedAt: string
+}
+
+export interface Typing {
+  fromEmail: string
+  toEmail: string
+  typing: boolean
+  expiresAt?: string
+}
+
+export interface Presence {
+  email: string
+  online: boolean
+  lastSeenAt?: string
+}
+
+export interface ConversationSummary {
+  withEmail: string
+  lastMessageUUID: string
+  lastMessageFrom: string
+  lastMessagePreview: string
+  lastActivityAt: string
+  unreadCount: number
 }
 
 export interface Chat {
   ping(headers?: object): Promise<PingReturn>
   version(headers?: object): Promise<VersionReturn>
   createChatMessage(args: CreateChatMessageArgs, headers?: object): Promise<CreateChatMessageReturn>
+  listConversation(args: ListConversationArgs, headers?: object): Promise<ListConversationReturn>
+  listConversations(headers?: object): Promise<ListConversationsReturn>
+  markDelivered(args: MarkDeliveredArgs, headers?: object): Promise<MarkDeliveredReturn>
+  setReadCursor(args: SetReadCursorArgs, headers?: object): Promise<SetReadCursorReturn>
+  editChatMessage(args: EditChatMessageArgs, headers?: object): Promise<EditChatMessageReturn>
+  getMessageHistory(args: GetMessageHistoryArgs, headers?: object): Promise<GetMessageHistoryReturn>
+  deleteChatMessage(args: DeleteChatMessageArgs, headers?: object): Promise<DeleteChatMessageReturn>
+  hideChatMessage(args: HideChatMessageArgs, headers?: object): Promise<HideChatMessageReturn>
+  createRoom(args: CreateRoomArgs, headers?: object): Promise<CreateRoomReturn>
+  addMember(args: AddMemberArgs, headers?: object): Promise<AddMemberReturn>
+  removeMember(args: RemoveMemberArgs, headers?: object): Promise<RemoveMemberReturn>
+  listRooms(headers?: object): Promise<ListRoomsReturn>
+  sendRoomMessage(args: SendRoomMessageArgs, headers?: object): Promise<SendRoomMessageReturn>
+  listRoomMessages(args: ListRoomMessagesArgs, headers?: object): Promise<ListRoomMessagesReturn>
+  setTyping(args: SetTypingArgs, headers?: object): Promise<SetTypingReturn>
+  getPresence(args: GetPresenceArgs, headers?: object): Promise<GetPresenceReturn>
+  listThread(args: ListThreadArgs, headers?: object): Promise<ListThreadReturn>
+  addReaction(args: AddReactionArgs, headers?: object): Promise<AddReactionReturn>
+  removeReaction(args: RemoveReactionArgs, headers?: object): Promise<RemoveReactionReturn>
+  getAttachments(args: GetAttachmentsArgs, headers?: object): Promise<GetAttachmentsReturn>
+  getUsage(headers?: object): Promise<GetUsageReturn>
+  searchMessages(args: SearchMessagesArgs, headers?: object): Promise<SearchMessagesReturn>
+  listScheduledMessages(headers?: object): Promise<ListScheduledMessagesReturn>
+  cancelScheduledMessage(args: CancelScheduledMessageArgs, headers?: object): Promise<CancelScheduledMessageReturn>
+  getConversationSettings(args: GetConversationSettingsArgs, headers?: object): Promise<GetConversationSettingsReturn>
+  setMessageTTL(args: SetMessageTTLArgs, headers?: object): Promise<SetMessageTTLReturn>
+  blockUser(args: BlockUserArgs, headers?: object): Promise<BlockUserReturn>
+  unblockUser(args: UnblockUserArgs, headers?: object): Promise<UnblockUserReturn>
+  listBlocked(headers?: object): Promise<ListBlockedReturn>
+  muteConversation(args: MuteConversationArgs, headers?: object): Promise<MuteConversationReturn>
+  listMentions(args: ListMentionsArgs, headers?: object): Promise<ListMentionsReturn>
+  exportMyData(headers?: object): Promise<ExportMyDataReturn>
+  getExportStatus(args: GetExportStatusArgs, headers?: object): Promise<GetExportStatusReturn>
+  eraseUser(args: EraseUserArgs, headers?: object): Promise<EraseUserReturn>
 }
 
 export interface PingArgs {
@@ -62,6 +267,269 @@ export interface CreateChatMessageArgs {
 export interface CreateChatMessageReturn {
   res: boolean  
 }
+export interface ListConversationArgs {
+  withEmail: string
+  cursor: string
+  limit: number
+}
+
+export interface ListConversationReturn {
+  messages: Array<ChatMessage>  
+  nextCursor: string  
+}
+export interface ListConversationsArgs {
+}
+
+export interface ListConversationsReturn {
+  conversations: Array<ConversationSummary>  
+}
+export interface MarkDeliveredArgs {
+  messageUUIDs: Array<string>
+}
+
+export interface MarkDeliveredReturn {
+  status: boolean  
+}
+export interface SetReadCursorArgs {
+  withEmail?: string
+  roomID?: string
+  messageUUID: string
+}
+
+export interface SetReadCursorReturn {
+  cursor: ReadCursor  
+}
+export interface EditChatMessageArgs {
+  messageUUID: string
+  messageText: string
+  version: string
+}
+
+export interface EditChatMessageReturn {
+  message: ChatMessage  
+}
+export interface GetMessageHistoryArgs {
+  messageUUID: string
+}
+
+export interface GetMessageHistoryReturn {
+  revisions: Array<ChatMessage>  
+}
+export interface DeleteChatMessageArgs {
+  messageUUID: string
+}
+
+export interface DeleteChatMessageReturn {
+  message: ChatMessage  
+}
+export interface HideChatMessageArgs {
+  messageUUID: string
+}
+
+export interface HideChatMessageReturn {
+  status: boolean  
+}
+export interface CreateRoomArgs {
+  name: string
+  members: Array<string>
+}
+
+export interface CreateRoomReturn {
+  room: Room  
+}
+export interface AddMemberArgs {
+  roomID: string
+  email: string
+}
+
+export interface AddMemberReturn {
+  room: Room  
+}
+export interface RemoveMemberArgs {
+  roomID: string
+  email: string
+}
+
+export interface RemoveMemberReturn {
+  room: Room  
+}
+export interface ListRoomsArgs {
+}
+
+export interface ListRoomsReturn {
+  rooms: Array<Room>  
+}
+export interface SendRoomMessageArgs {
+  roomID: string
+  messageText: string
+  parentMessageUUID?: string
+  attachmentIDs?: Array<string>
+}
+
+export interface SendRoomMessageReturn {
+  message: ChatMessage  
+}
+export interface ListRoomMessagesArgs {
+  roomID: string
+  cursor: string
+  limit: number
+}
+
+export interface ListRoomMessagesReturn {
+  messages: Array<ChatMessage>  
+  nextCursor: string  
+}
+export interface SetTypingArgs {
+  toEmail: string
+  typing: boolean
+}
+
+export interface SetTypingReturn {
+  status: boolean  
+}
+export interface GetPresenceArgs {
+  emails: Array<string>
+}
+
+export interface GetPresenceReturn {
+  presence: Array<Presence>  
+}
+export interface ListThreadArgs {
+  parentMessageUUID: string
+}
+
+export interface ListThreadReturn {
+  parent: ChatMessage  
+  replies: Array<ChatMessage>  
+}
+export interface AddReactionArgs {
+  messageUUID: string
+  emoji: string
+}
+
+export interface AddReactionReturn {
+  message: ChatMessage  
+}
+export interface RemoveReactionArgs {
+  messageUUID: string
+  emoji: string
+}
+
+export interface RemoveReactionReturn {
+  message: ChatMessage  
+}
+export interface GetAttachmentsArgs {
+  attachmentIDs: Array<string>
+}
+
+export interface GetAttachmentsReturn {
+  attachments: Array<Attachment>  
+}
+export interface GetUsageArgs {
+}
+
+export interface GetUsageReturn {
+  usage: Usage  
+}
+export interface SearchMessagesArgs {
+  query: string
+  withEmail?: string
+  before?: string
+  after?: string
+  limit: number
+}
+
+export interface SearchMessagesReturn {
+  results: Array<SearchResult>  
+}
+export interface ListScheduledMessagesArgs {
+}
+
+export interface ListScheduledMessagesReturn {
+  messages: Array<ChatMessage>  
+}
+export interface CancelScheduledMessageArgs {
+  messageUUID: string
+}
+
+export interface CancelScheduledMessageReturn {
+  message: ChatMessage  
+}
+export interface GetConversationSettingsArgs {
+  withEmail?: string
+  roomID?: string
+}
+
+export interface GetConversationSettingsReturn {
+  settings: ConversationSettings  
+}
+export interface SetMessageTTLArgs {
+  withEmail?: string
+  roomID?: string
+  messageTTL: number
+}
+
+export interface SetMessageTTLReturn {
+  settings: ConversationSettings  
+}
+export interface BlockUserArgs {
+  email: string
+}
+
+export interface BlockUserReturn {
+  status: boolean  
+}
+export interface UnblockUserArgs {
+  email: string
+}
+
+export interface UnblockUserReturn {
+  status: boolean  
+}
+export interface ListBlockedArgs {
+}
+
+export interface ListBlockedReturn {
+  users: Array<BlockedUser>  
+}
+export interface MuteConversationArgs {
+  withEmail?: string
+  roomID?: string
+  muted: boolean
+}
+
+export interface MuteConversationReturn {
+  status: boolean  
+}
+export interface ListMentionsArgs {
+  cursor: string
+  limit: number
+}
+
+export interface ListMentionsReturn {
+  messages: Array<ChatMessage>  
+  nextCursor: string  
+}
+export interface ExportMyDataArgs {
+}
+
+export interface ExportMyDataReturn {
+  dataExport: DataExport  
+}
+export interface GetExportStatusArgs {
+  exportID: string
+}
+
+export interface GetExportStatusReturn {
+  dataExport: DataExport  
+}
+export interface EraseUserArgs {
+  email: string
+}
+
+export interface EraseUserReturn {
+  erasure: UserErasure  
+}
 
 
   
@@ -120,6 +588,424 @@ export class Chat implements Chat {
     })
   }
   
+  listConversation = (args: ListConversationArgs, headers?: object): Promise<ListConversationReturn> => {
+    return this.fetch(
+      this.url('ListConversation'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          messages: <Array<ChatMessage>>(_data.messages),
+          nextCursor: <string>(_data.nextCursor)
+        }
+      })
+    })
+  }
+  
+  listConversations = (headers?: object): Promise<ListConversationsReturn> => {
+    return this.fetch(
+      this.url('ListConversations'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          conversations: <Array<ConversationSummary>>(_data.conversations)
+        }
+      })
+    })
+  }
+  
+  markDelivered = (args: MarkDeliveredArgs, headers?: object): Promise<MarkDeliveredReturn> => {
+    return this.fetch(
+      this.url('MarkDelivered'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  setReadCursor = (args: SetReadCursorArgs, headers?: object): Promise<SetReadCursorReturn> => {
+    return this.fetch(
+      this.url('SetReadCursor'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          cursor: <ReadCursor>(_data.cursor)
+        }
+      })
+    })
+  }
+  
+  editChatMessage = (args: EditChatMessageArgs, headers?: object): Promise<EditChatMessageReturn> => {
+    return this.fetch(
+      this.url('EditChatMessage'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  getMessageHistory = (args: GetMessageHistoryArgs, headers?: object): Promise<GetMessageHistoryReturn> => {
+    return this.fetch(
+      this.url('GetMessageHistory'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          revisions: <Array<ChatMessage>>(_data.revisions)
+        }
+      })
+    })
+  }
+  
+  deleteChatMessage = (args: DeleteChatMessageArgs, headers?: object): Promise<DeleteChatMessageReturn> => {
+    return this.fetch(
+      this.url('DeleteChatMessage'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  hideChatMessage = (args: HideChatMessageArgs, headers?: object): Promise<HideChatMessageReturn> => {
+    return this.fetch(
+      this.url('HideChatMessage'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  createRoom = (args: CreateRoomArgs, headers?: object): Promise<CreateRoomReturn> => {
+    return this.fetch(
+      this.url('CreateRoom'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          room: <Room>(_data.room)
+        }
+      })
+    })
+  }
+  
+  addMember = (args: AddMemberArgs, headers?: object): Promise<AddMemberReturn> => {
+    return this.fetch(
+      this.url('AddMember'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          room: <Room>(_data.room)
+        }
+      })
+    })
+  }
+  
+  removeMember = (args: RemoveMemberArgs, headers?: object): Promise<RemoveMemberReturn> => {
+    return this.fetch(
+      this.url('RemoveMember'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          room: <Room>(_data.room)
+        }
+      })
+    })
+  }
+  
+  listRooms = (headers?: object): Promise<ListRoomsReturn> => {
+    return this.fetch(
+      this.url('ListRooms'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          rooms: <Array<Room>>(_data.rooms)
+        }
+      })
+    })
+  }
+  
+  sendRoomMessage = (args: SendRoomMessageArgs, headers?: object): Promise<SendRoomMessageReturn> => {
+    return this.fetch(
+      this.url('SendRoomMessage'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  listRoomMessages = (args: ListRoomMessagesArgs, headers?: object): Promise<ListRoomMessagesReturn> => {
+    return this.fetch(
+      this.url('ListRoomMessages'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          messages: <Array<ChatMessage>>(_data.messages),
+          nextCursor: <string>(_data.nextCursor)
+        }
+      })
+    })
+  }
+  
+  setTyping = (args: SetTypingArgs, headers?: object): Promise<SetTypingReturn> => {
+    return this.fetch(
+      this.url('SetTyping'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  getPresence = (args: GetPresenceArgs, headers?: object): Promise<GetPresenceReturn> => {
+    return this.fetch(
+      this.url('GetPresence'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          presence: <Array<Presence>>(_data.presence)
+        }
+      })
+    })
+  }
+  
+  listThread = (args: ListThreadArgs, headers?: object): Promise<ListThreadReturn> => {
+    return this.fetch(
+      this.url('ListThread'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          parent: <ChatMessage>(_data.parent),
+          replies: <Array<ChatMessage>>(_data.replies)
+        }
+      })
+    })
+  }
+  
+  addReaction = (args: AddReactionArgs, headers?: object): Promise<AddReactionReturn> => {
+    return this.fetch(
+      this.url('AddReaction'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  removeReaction = (args: RemoveReactionArgs, headers?: object): Promise<RemoveReactionReturn> => {
+    return this.fetch(
+      this.url('RemoveReaction'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  getAttachments = (args: GetAttachmentsArgs, headers?: object): Promise<GetAttachmentsReturn> => {
+    return this.fetch(
+      this.url('GetAttachments'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          attachments: <Array<Attachment>>(_data.attachments)
+        }
+      })
+    })
+  }
+  
+  getUsage = (headers?: object): Promise<GetUsageReturn> => {
+    return this.fetch(
+      this.url('GetUsage'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          usage: <Usage>(_data.usage)
+        }
+      })
+    })
+  }
+  
+  searchMessages = (args: SearchMessagesArgs, headers?: object): Promise<SearchMessagesReturn> => {
+    return this.fetch(
+      this.url('SearchMessages'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          results: <Array<SearchResult>>(_data.results)
+        }
+      })
+    })
+  }
+  
+  listScheduledMessages = (headers?: object): Promise<ListScheduledMessagesReturn> => {
+    return this.fetch(
+      this.url('ListScheduledMessages'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          messages: <Array<ChatMessage>>(_data.messages)
+        }
+      })
+    })
+  }
+  
+  cancelScheduledMessage = (args: CancelScheduledMessageArgs, headers?: object): Promise<CancelScheduledMessageReturn> => {
+    return this.fetch(
+      this.url('CancelScheduledMessage'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          message: <ChatMessage>(_data.message)
+        }
+      })
+    })
+  }
+  
+  getConversationSettings = (args: GetConversationSettingsArgs, headers?: object): Promise<GetConversationSettingsReturn> => {
+    return this.fetch(
+      this.url('GetConversationSettings'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          settings: <ConversationSettings>(_data.settings)
+        }
+      })
+    })
+  }
+  
+  setMessageTTL = (args: SetMessageTTLArgs, headers?: object): Promise<SetMessageTTLReturn> => {
+    return this.fetch(
+      this.url('SetMessageTTL'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          settings: <ConversationSettings>(_data.settings)
+        }
+      })
+    })
+  }
+  
+  blockUser = (args: BlockUserArgs, headers?: object): Promise<BlockUserReturn> => {
+    return this.fetch(
+      this.url('BlockUser'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  unblockUser = (args: UnblockUserArgs, headers?: object): Promise<UnblockUserReturn> => {
+    return this.fetch(
+      this.url('UnblockUser'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  listBlocked = (headers?: object): Promise<ListBlockedReturn> => {
+    return this.fetch(
+      this.url('ListBlocked'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          users: <Array<BlockedUser>>(_data.users)
+        }
+      })
+    })
+  }
+  
+  muteConversation = (args: MuteConversationArgs, headers?: object): Promise<MuteConversationReturn> => {
+    return this.fetch(
+      this.url('MuteConversation'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          status: <boolean>(_data.status)
+        }
+      })
+    })
+  }
+  
+  listMentions = (args: ListMentionsArgs, headers?: object): Promise<ListMentionsReturn> => {
+    return this.fetch(
+      this.url('ListMentions'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          messages: <Array<ChatMessage>>(_data.messages),
+          nextCursor: <string>(_data.nextCursor)
+        }
+      })
+    })
+  }
+  
+  exportMyData = (headers?: object): Promise<ExportMyDataReturn> => {
+    return this.fetch(
+      this.url('ExportMyData'),
+      createHTTPRequest({}, headers)
+      ).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          dataExport: <DataExport>(_data.dataExport)
+        }
+      })
+    })
+  }
+  
+  getExportStatus = (args: GetExportStatusArgs, headers?: object): Promise<GetExportStatusReturn> => {
+    return this.fetch(
+      this.url('GetExportStatus'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          dataExport: <DataExport>(_data.dataExport)
+        }
+      })
+    })
+  }
+  
+  eraseUser = (args: EraseUserArgs, headers?: object): Promise<EraseUserReturn> => {
+    return this.fetch(
+      this.url('EraseUser'),
+      createHTTPRequest(args, headers)).then((res) => {
+      return buildResponse(res).then(_data => {
+        return {
+          erasure: <UserErasure>(_data.erasure)
+        }
+      })
+    })
+  }
+  
 }
 
   
@@ -153,3 +1039,5 @@ const buildResponse = (res: Response): Promise<any> => {
 }
 
 export type Fetch = (input: RequestInfo, init?: RequestInit) => Promise<Response>
+
+export const ErrRateLimited = 'rate limited'
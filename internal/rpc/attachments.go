@@ -0,0 +1,255 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/thumbnail"
+)
+
+const (
+	// number of bytes the content type is sniffed from
+	sniffLength = 512
+	// longest attachment file name kept
+	maxFileNameLength = 255
+	// file name of attachments uploaded without one
+	defaultFileName = "attachment"
+	// time allowed to give back reserved storage after a failed upload
+	storageTimeout = 5 * time.Second
+	// Errors
+	attachmentTooLargeErr = "attachment is too large"
+	emptyAttachmentErr    = "attachment is empty"
+	blobErr               = "blob store error"
+	thumbnailErr          = "cannot make thumbnail"
+	thumbnailQueueErr     = "thumbnail queue is full"
+)
+
+var errAttachmentTooLarge = errors.New(attachmentTooLargeErr)
+
+// UploadAttachment stores a file uploaded by the caller in the blob store,
+// size is the length announced for body or -1 when it is unknown.
+// The upload counts against the caller storage quota
+func (d *Chat) UploadAttachment(ctx context.Context, fileName string, body io.Reader, size int64) (*proto.Attachment, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > d.cfg.MaxAttachmentSize {
+		return nil, proto.Errorf(proto.ErrInvalidArgument, attachmentTooLargeErr)
+	}
+	if size == 0 {
+		return nil, proto.Errorf(proto.ErrInvalidArgument, emptyAttachmentErr)
+	}
+
+	// the upload can't go past what is reserved
+	reserved := size
+	if reserved < 0 {
+		reserved = d.cfg.MaxAttachmentSize
+	}
+	err = d.db.ReserveStorage(ctx, claims.Email, reserved, d.cfg.StorageQuota)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	created := false
+	defer func() {
+		if created {
+			return
+		}
+		rctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+		defer cancel()
+		err := d.db.ReleaseStorage(rctx, claims.Email, reserved)
+		if err != nil {
+			d.rlog.Err(err).Msg(dataErr)
+		}
+	}()
+
+	br := bufio.NewReaderSize(body, sniffLength)
+	head, _ := br.Peek(sniffLength)
+	if len(head) == 0 {
+		return nil, proto.Errorf(proto.ErrInvalidArgument, emptyAttachmentErr)
+	}
+	contentType := http.DetectContentType(head)
+
+	storedAt := time.Now().UTC()
+	blobKey, n, err := d.blobs.Put(ctx, &limitedReader{r: br, n: reserved})
+	if errors.Cause(err) == errAttachmentTooLarge {
+		return nil, proto.Errorf(proto.ErrInvalidArgument, attachmentTooLargeErr)
+	}
+	if err != nil {
+		d.rlog.Err(err).Msg(blobErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, blobErr)
+	}
+
+	attachmentID, err := newUUID()
+	if err != nil {
+		d.rlog.Err(err).Msg(internalErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, internalErr)
+	}
+
+	attachment := proto.Attachment{
+		AttachmentID: attachmentID,
+		OwnerEmail:   claims.Email,
+		FileName:     cleanFileName(fileName),
+		ContentType:  contentType,
+		Size:         n,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	err = d.db.CreateAttachment(ctx, attachment, blobKey, reserved, storedAt)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+	created = true
+
+	// images get their dimensions and thumbnail in the background
+	if thumbnail.Supported(contentType) {
+		queued := d.thumbnails.Enqueue(thumbnail.Job{AttachmentID: attachmentID, BlobKey: blobKey})
+		if !queued {
+			d.rlog.Warn().Msgf("%v : %s", thumbnailQueueErr, attachmentID)
+		}
+	}
+
+	return &attachment, nil
+}
+
+// OpenAttachment returns an attachment along with its content,
+// to its owner or the participants of a conversation it was sent to
+func (d *Chat) OpenAttachment(ctx context.Context, attachmentID string) (*proto.Attachment, io.ReadCloser, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachment, blobKey, err := d.db.GetAttachment(ctx, claims.Email, attachmentID)
+	if err != nil {
+		return nil, nil, d.dataError(err)
+	}
+
+	content, err := d.blobs.Get(ctx, blobKey)
+	if err != nil {
+		d.rlog.Err(err).Msg(blobErr)
+		return nil, nil, proto.WrapError(proto.ErrInternal, err, blobErr)
+	}
+
+	return &attachment, content, nil
+}
+
+// OpenThumbnail returns the thumbnail of an image attachment along with its content,
+// to the users that can download the attachment
+func (d *Chat) OpenThumbnail(ctx context.Context, attachmentID string) (*proto.Thumbnail, io.ReadCloser, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	thumb, thumbnailKey, err := d.db.GetThumbnail(ctx, claims.Email, attachmentID)
+	if err != nil {
+		return nil, nil, d.dataError(err)
+	}
+
+	content, err := d.blobs.Get(ctx, thumbnailKey)
+	if err != nil {
+		d.rlog.Err(err).Msg(blobErr)
+		return nil, nil, proto.WrapError(proto.ErrInternal, err, blobErr)
+	}
+
+	return &thumb, content, nil
+}
+
+// thumbnailDone records the dimensions and thumbnail of a processed image
+func (d *Chat) thumbnailDone(res thumbnail.Result) {
+	if res.Err != nil {
+		d.rlog.Err(res.Err).Msgf("%v : %s", thumbnailErr, res.AttachmentID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	err := d.db.SetAttachmentImage(ctx, res.AttachmentID, res.Width, res.Height, res.Thumbnail, res.ThumbnailKey, res.StoredAt)
+	if err != nil {
+		d.rlog.Err(err).Msg(dataErr)
+	}
+}
+
+// GetAttachments returns the details of the attachments the caller can download,
+// the others are left out
+func (d *Chat) GetAttachments(ctx context.Context, attachmentIDs []string) ([]*proto.Attachment, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.Val.Var(attachmentIDs, "required,max=100,dive,required")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	attachments, err := d.db.GetAttachments(ctx, claims.Email, attachmentIDs)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.Attachment, len(attachments))
+	for i := range attachments {
+		res[i] = &attachments[i]
+	}
+
+	return res, nil
+}
+
+// GetUsage returns the storage used by the attachments of the caller
+func (d *Chat) GetUsage(ctx context.Context) (*proto.Usage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	used, attachments, err := d.db.GetUsage(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	return &proto.Usage{
+		UsedBytes:   used,
+		QuotaBytes:  d.cfg.StorageQuota,
+		Attachments: attachments,
+	}, nil
+}
+
+// cleanFileName keeps the base name of an uploaded file
+func cleanFileName(fileName string) string {
+	fileName = strings.TrimSpace(filepath.Base(filepath.Clean("/" + fileName)))
+	if fileName == "" || fileName == "/" || fileName == "." {
+		return defaultFileName
+	}
+	if len(fileName) > maxFileNameLength {
+		fileName = fileName[:maxFileNameLength]
+	}
+	return fileName
+}
+
+// limitedReader fails once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errAttachmentTooLarge
+	}
+	return n, err
+}
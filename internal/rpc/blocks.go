@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// Errors
+	blockSelfErr = "users can't block themselves"
+)
+
+// BlockUser stops email from sending messages to the caller and hides the caller
+// presence and typing from them, email is not told about it
+func (d *Chat) BlockUser(ctx context.Context, email string) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.checkBlocked(claims.Email, email)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.db.BlockUser(ctx, claims.Email, email, time.Now().UTC())
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	// nothing is published to the blocked user, that would tell them about the block.
+	// The presence and typing of the caller are no longer published to them from now on
+	return true, nil
+}
+
+// UnblockUser lets email send messages to the caller and see their presence and typing again
+func (d *Chat) UnblockUser(ctx context.Context, email string) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.checkBlocked(claims.Email, email)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.db.UnblockUser(ctx, claims.Email, email)
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	err = d.publish(event.Presence, d.presence.Get([]string{claims.Email})[0], email)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishPresenceErr)
+	}
+
+	return true, nil
+}
+
+// ListBlocked returns the users the caller blocked ordered by email
+func (d *Chat) ListBlocked(ctx context.Context) ([]*proto.BlockedUser, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := d.db.ListBlocked(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.BlockedUser, len(blocked))
+	for i := range blocked {
+		res[i] = &blocked[i]
+	}
+
+	return res, nil
+}
+
+// MuteConversation sets whether the caller is notified of the messages of their conversation
+// with withEmail, or of a room they are a member of. Messages of muted conversations are still
+// delivered, flagged as muted
+func (d *Chat) MuteConversation(ctx context.Context, withEmail *string, roomID *string, muted bool) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	with, room, err := d.conversationArgs(claims.Email, withEmail, roomID)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.db.MuteConversation(ctx, claims.Email, with, room, muted)
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	return true, nil
+}
+
+// checkBlocked validates the email a user blocks or unblocks
+func (d *Chat) checkBlocked(blocker, email string) error {
+	err := d.Val.Var(email, "required,email")
+	if err != nil {
+		return proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	if email == blocker {
+		return proto.Errorf(proto.ErrInvalidArgument, blockSelfErr)
+	}
+	return nil
+}
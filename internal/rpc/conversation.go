@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+	// RPC errors
+	invalidCursorErr = "is not a valid cursor"
+)
+
+// ListConversation returns the messages exchanged between the caller and withEmail, newest first
+// the returned cursor points right before the last returned message so that
+// messages arriving while paging never shift the following pages
+func (d *Chat) ListConversation(ctx context.Context, withEmail string, cursor string, limit int) ([]*proto.ChatMessage, string, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = d.Val.Var(withEmail, "required,email")
+	if err != nil {
+		return nil, "", proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", proto.ErrorInvalidArgument("cursor", invalidCursorErr)
+	}
+
+	messages, next, err := d.db.ListConversation(ctx, claims.Email, withEmail, before, pageLimit(limit))
+	if err != nil {
+		return nil, "", d.dataError(err)
+	}
+
+	res := make([]*proto.ChatMessage, len(messages))
+	for i := range messages {
+		res[i] = &messages[i]
+	}
+
+	return res, encodeCursor(next), nil
+}
+
+// pageLimit bounds the page size requested by clients
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
+
+// encodeCursor turns a db position into an opaque cursor, the zero position
+// marks the last page and is encoded as an empty cursor
+func encodeCursor(position uint64) string {
+	if position == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(position, 10)))
+}
+
+// decodeCursor turns an opaque cursor back into a db position
+func decodeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+// ListConversations returns the caller's inbox, one summary per counterpart
+// ordered by last activity
+func (d *Chat) ListConversations(ctx context.Context) ([]*proto.ConversationSummary, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := d.db.ListConversations(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.ConversationSummary, len(summaries))
+	for i := range summaries {
+		res[i] = &summaries[i]
+	}
+
+	return res, nil
+}
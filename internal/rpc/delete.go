@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// DeleteChatMessage retracts a chat message for both participants and leaves a tombstone,
+// authors can delete their messages within the configured window, admins can delete any message.
+// Admins are the callers whose verified token carries the admin role
+func (d *Chat) DeleteChatMessage(ctx context.Context, messageUUID string) (*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+
+	now := time.Now().UTC()
+	msg, err := d.db.DeleteChatMessage(ctx, claims.Email, messageUUID, now.Add(-d.cfg.DeleteWindow), claims.IsAdmin(), now)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	err = d.publishConversation(event.Deleted, msg, msg)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishDeleteErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return &msg, nil
+}
+
+// HideChatMessage removes a chat message from the caller's conversation only
+func (d *Chat) HideChatMessage(ctx context.Context, messageUUID string) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if messageUUID == "" {
+		return false, proto.ErrorRequiredArgument("messageUUID")
+	}
+
+	err = d.db.HideChatMessage(ctx, claims.Email, messageUUID)
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// EditChatMessage lets the author change the text of a chat message,
+// the edit is rejected with an aborted error when version is not the stored one
+func (d *Chat) EditChatMessage(ctx context.Context, messageUUID string, messageText string, version string) (*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+	if messageText == "" {
+		return nil, proto.ErrorRequiredArgument("messageText")
+	}
+	if version == "" {
+		return nil, proto.ErrorRequiredArgument("version")
+	}
+
+	edit := proto.ChatMessage{MessageText: messageText}
+	err = d.moderate(&edit)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, mentioned, err := d.db.EditChatMessage(ctx, claims.Email, messageUUID, edit.MessageText, edit.Annotations, version, time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	err = d.publishConversation(event.Edited, msg, msg)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishEditErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+	// only the users the edit newly mentions are told
+	d.publishMentions(msg, mentioned...)
+
+	return &msg, nil
+}
+
+// GetMessageHistory returns every revision of a chat message, oldest first
+// and ending with the current one
+func (d *Chat) GetMessageHistory(ctx context.Context, messageUUID string) ([]*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+
+	revisions, err := d.db.GetMessageHistory(ctx, claims.Email, messageUUID)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.ChatMessage, len(revisions))
+	for i := range revisions {
+		res[i] = &revisions[i]
+	}
+
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// time allowed to an erasure, it carries on after the caller went away
+	// and one that is cut short is picked up by the next call
+	erasureTimeout = 5 * time.Minute
+	// Errors
+	notAdminErr       = "caller is not an admin"
+	erasureErr        = "user erasure did not complete, call again to resume it"
+	publishErasureErr = "cannot publish erasure event"
+)
+
+// EraseUser removes everything stored about email across the database, the blob store and
+// the scheduled messages, closes their streams and tells every instance to forget them.
+// Erasures are journaled, calling it again after a failure resumes the same erasure and
+// the completion record returned is kept for audits. Only callers whose verified token
+// carries the admin role can erase users
+func (d *Chat) EraseUser(ctx context.Context, email string) (*proto.UserErasure, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.IsAdmin() {
+		return nil, proto.Errorf(proto.ErrPermissionDenied, notAdminErr)
+	}
+
+	if email == "" {
+		return nil, proto.ErrorRequiredArgument("email")
+	}
+	err = d.Val.Var(email, "email")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	ectx, cancel := context.WithTimeout(context.Background(), erasureTimeout)
+	defer cancel()
+
+	erasure, err := d.eraser.Erase(ectx, email, claims.Email)
+	if err != nil {
+		d.rlog.Err(err).Msgf("%v : %s", erasureErr, email)
+		return nil, proto.WrapError(proto.ErrInternal, err, erasureErr)
+	}
+
+	return &erasure, nil
+}
+
+// notifyErased ends the streams of an erased user on every instance
+func (d *Chat) notifyErased(erasure proto.UserErasure) error {
+	return d.publish(event.Erased, erasure, erasure.Email)
+}
+
+// userErased drops what this instance keeps about an erased user
+func (d *Chat) userErased(erasure proto.UserErasure) {
+	d.presence.Forget(erasure.Email)
+	d.typing.forget(erasure.Email)
+}
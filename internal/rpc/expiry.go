@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// shortest message lifetime accepted
+	minMessageTTL = time.Minute
+	// Errors
+	conversationArgsErr  = "either withEmail or roomID is required"
+	selfConversationErr  = "withEmail must be another user"
+	publishSettingsErr   = "cannot publish conversation settings"
+	publishExpirationErr = "cannot publish chat message expiration"
+)
+
+// GetConversationSettings returns the settings of the caller conversation
+// with withEmail, or of a room the caller is a member of
+func (d *Chat) GetConversationSettings(ctx context.Context, withEmail *string, roomID *string) (*proto.ConversationSettings, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	with, room, err := d.conversationArgs(claims.Email, withEmail, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := d.db.GetConversationSettings(ctx, claims.Email, with, room)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	return &settings, nil
+}
+
+// SetMessageTTL sets the lifetime in seconds of the messages sent from now on to the caller
+// conversation with withEmail, or to a room the caller is a member of, 0 keeps them.
+// Every participant can change it, they are all told through a conversationUpdated event
+func (d *Chat) SetMessageTTL(ctx context.Context, withEmail *string, roomID *string, messageTTL int64) (*proto.ConversationSettings, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	with, room, err := d.conversationArgs(claims.Email, withEmail, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageTTL != 0 {
+		err = d.Val.Var(messageTTL, fmt.Sprintf("min=%d,max=%d", int64(minMessageTTL/time.Second), int64(d.cfg.MaxMessageTTL/time.Second)))
+		if err != nil {
+			return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+		}
+	}
+
+	settings, err := d.db.SetMessageTTL(ctx, claims.Email, with, room, time.Duration(messageTTL)*time.Second, time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	if room != "" {
+		err = d.publishRoom(event.ConversationUpdated, settings, room)
+	} else {
+		err = d.publish(event.ConversationUpdated, settings, settings.Emails...)
+	}
+	if err != nil {
+		d.rlog.Err(err).Msg(publishSettingsErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return &settings, nil
+}
+
+// conversationArgs checks that exactly one of withEmail and roomID is given
+// and returns them, the one not given is empty
+func (d *Chat) conversationArgs(email string, withEmail *string, roomID *string) (string, string, error) {
+	if (withEmail == nil) == (roomID == nil) {
+		return "", "", proto.Errorf(proto.ErrInvalidArgument, conversationArgsErr)
+	}
+
+	if roomID != nil {
+		if *roomID == "" {
+			return "", "", proto.ErrorRequiredArgument("roomID")
+		}
+		return "", *roomID, nil
+	}
+
+	err := d.Val.Var(*withEmail, "required,email")
+	if err != nil {
+		return "", "", proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	if *withEmail == email {
+		return "", "", proto.Errorf(proto.ErrInvalidArgument, selfConversationErr)
+	}
+	return *withEmail, "", nil
+}
+
+// messagesExpired tells the participants of the conversations of
+// purged messages to remove them, the events only identify the messages
+func (d *Chat) messagesExpired(ctx context.Context, messages []proto.ChatMessage) {
+	for _, msg := range messages {
+		expired := proto.ChatMessage{
+			FromEmail:         msg.FromEmail,
+			ToEmail:           msg.ToEmail,
+			MessageUUID:       msg.MessageUUID,
+			RoomID:            msg.RoomID,
+			ParentMessageUUID: msg.ParentMessageUUID,
+			ExpiresAt:         msg.ExpiresAt,
+		}
+		err := d.publishConversation(event.Expired, expired, expired)
+		if err != nil {
+			d.rlog.Err(err).Msgf("%v : %s", publishExpirationErr, msg.MessageUUID)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/export"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// Errors
+	exportQueueFullErr = "too many data exports in progress, try again later"
+)
+
+// ExportMyData starts exporting everything stored about the caller into an NDJSON archive,
+// progress is polled with GetExportStatus. While an export of the caller is pending or
+// running it is returned instead of starting another one
+func (d *Chat) ExportMyData(ctx context.Context) (*proto.DataExport, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exportID, err := newUUID()
+	if err != nil {
+		d.rlog.Err(err).Msg(internalErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, internalErr)
+	}
+
+	dataExport, created, err := d.db.CreateExport(ctx, claims.Email, exportID, time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+	if !created {
+		return &dataExport, nil
+	}
+
+	if !d.exporter.Enqueue(export.Job{ExportID: exportID, Email: claims.Email}) {
+		err = d.db.FailExport(ctx, exportID, exportQueueFullErr, time.Now().UTC())
+		if err != nil {
+			return nil, d.dataError(err)
+		}
+		return nil, proto.Errorf(proto.ErrResourceExhausted, exportQueueFullErr)
+	}
+
+	return &dataExport, nil
+}
+
+// GetExportStatus returns a data export of the caller along with its progress
+func (d *Chat) GetExportStatus(ctx context.Context, exportID string) (*proto.DataExport, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if exportID == "" {
+		return nil, proto.ErrorRequiredArgument("exportID")
+	}
+
+	dataExport, err := d.db.GetExport(ctx, claims.Email, exportID)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	return &dataExport, nil
+}
+
+// OpenExport returns a done data export of the caller along with its archive
+func (d *Chat) OpenExport(ctx context.Context, exportID string) (*proto.DataExport, io.ReadCloser, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataExport, blobKey, err := d.db.ExportArchive(ctx, claims.Email, exportID)
+	if err != nil {
+		return nil, nil, d.dataError(err)
+	}
+
+	content, err := d.blobs.Get(ctx, blobKey)
+	if err != nil {
+		d.rlog.Err(err).Msg(blobErr)
+		return nil, nil, proto.WrapError(proto.ErrInternal, err, blobErr)
+	}
+
+	return &dataExport, content, nil
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// ListMentions returns the messages mentioning the caller, newest first
+func (d *Chat) ListMentions(ctx context.Context, cursor string, limit int) ([]*proto.ChatMessage, string, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", proto.ErrorInvalidArgument("cursor", invalidCursorErr)
+	}
+
+	messages, next, err := d.db.ListMentions(ctx, claims.Email, before, pageLimit(limit))
+	if err != nil {
+		return nil, "", d.dataError(err)
+	}
+
+	res := make([]*proto.ChatMessage, len(messages))
+	for i := range messages {
+		res[i] = &messages[i]
+	}
+
+	return res, encodeCursor(next), nil
+}
+
+// publishMentions tells the given users they are mentioned by msg. The event goes to
+// their own topic and is never marked as muted, so muting a conversation doesn't
+// silence mentions. The message is stored already, failures are only logged
+func (d *Chat) publishMentions(msg proto.ChatMessage, emails ...string) {
+	if len(emails) == 0 {
+		return
+	}
+	err := d.publish(event.Mention, msg, emails...)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishMentionErr)
+	}
+}
+
+// mentionedAmong returns the users mentioned by msg that are in emails,
+// every mentioned user when emails is nil
+func mentionedAmong(msg proto.ChatMessage, emails []string) []string {
+	var mentioned []string
+	seen := make(map[string]bool)
+	for _, mention := range msg.Mentions {
+		if seen[mention.Email] {
+			continue
+		}
+		seen[mention.Email] = true
+		if emails == nil {
+			mentioned = append(mentioned, mention.Email)
+			continue
+		}
+		for _, email := range emails {
+			if email == mention.Email {
+				mentioned = append(mentioned, mention.Email)
+				break
+			}
+		}
+	}
+	return mentioned
+}
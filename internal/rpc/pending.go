@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rumsrami/example-service/internal/event"
+)
+
+// PendingEvents returns the events published to the caller that none of their streams
+// got yet, oldest first, whichever instance published them. Streams replay them once connected
+func (d *Chat) PendingEvents(ctx context.Context) ([]event.Event, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := d.pending.Pending(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	return events, nil
+}
+
+// AckEvent records that one of the streams of the caller got the event id
+func (d *Chat) AckEvent(ctx context.Context, id string) error {
+	claims, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = d.pending.Ack(ctx, claims.Email, id)
+	if err != nil {
+		return d.dataError(err)
+	}
+
+	return nil
+}
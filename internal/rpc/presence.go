@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// bounds the number of users asked for in one GetPresence call
+	maxPresenceEmails = 200
+	// time allowed to tell the contacts of a user about a presence change
+	presenceTimeout = 5 * time.Second
+)
+
+// GetPresence returns whether the given users are online, and when they were last seen if
+// they are not. Like presence events, it only tells about the contacts of the caller that
+// didn't block them, the other users are left out
+func (d *Chat) GetPresence(ctx context.Context, emails []string) ([]*proto.Presence, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.Val.Var(emails, fmt.Sprintf("required,max=%d,dive,required,email", maxPresenceEmails))
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	contacts, err := d.db.Contacts(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+	blockers, err := d.db.Blockers(ctx, claims.Email, emails)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	known := make(map[string]bool, len(contacts))
+	for _, contact := range contacts {
+		known[contact] = true
+	}
+	visible := make([]string, 0, len(emails))
+	for _, email := range emails {
+		if known[email] && !blockers[email] {
+			visible = append(visible, email)
+		}
+	}
+
+	presence := d.presence.Get(visible)
+
+	res := make([]*proto.Presence, len(presence))
+	for i := range presence {
+		res[i] = &presence[i]
+	}
+
+	return res, nil
+}
+
+// presenceChanged lets the contacts of a user know they went online or offline,
+// leaving out the ones they blocked
+func (d *Chat) presenceChanged(p proto.Presence) {
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+
+	contacts, err := d.db.Contacts(ctx, p.Email)
+	if err != nil {
+		d.rlog.Err(err).Msg(dataErr)
+		return
+	}
+
+	blocked, err := d.db.ListBlocked(ctx, p.Email)
+	if err != nil {
+		d.rlog.Err(err).Msg(dataErr)
+		return
+	}
+	hidden := make(map[string]bool, len(blocked))
+	for _, user := range blocked {
+		hidden[user.Email] = true
+	}
+	visible := contacts[:0]
+	for _, contact := range contacts {
+		if !hidden[contact] {
+			visible = append(visible, contact)
+		}
+	}
+
+	err = d.publish(event.Presence, p, visible...)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishPresenceErr)
+	}
+}
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// longest emoji accepted, in runes, leaves room for modifiers and joined sequences
+	maxEmojiLength = 16
+	// code points emoji sequences are made of besides the emoji themselves
+	zeroWidthJoiner     = '\u200d'
+	variationSelector16 = '\ufe0f'
+	keycapMark          = '\u20e3'
+	tagCancel           = '\U000e007f'
+	// RPC errors
+	notEmojiErr = "is not a single emoji"
+)
+
+// AddReaction reacts to a chat message with an emoji on behalf of the caller,
+// adding the same reaction again is a no-op
+func (d *Chat) AddReaction(ctx context.Context, messageUUID string, emoji string) (*proto.ChatMessage, error) {
+	return d.react(ctx, messageUUID, emoji, true)
+}
+
+// RemoveReaction removes the emoji reaction of the caller from a chat message,
+// removing a missing reaction is a no-op
+func (d *Chat) RemoveReaction(ctx context.Context, messageUUID string, emoji string) (*proto.ChatMessage, error) {
+	return d.react(ctx, messageUUID, emoji, false)
+}
+
+// react changes the reactions of a chat message and lets the conversation know
+func (d *Chat) react(ctx context.Context, messageUUID string, emoji string, add bool) (*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+	err = d.Val.Var(emoji, "required,max="+strconv.Itoa(maxEmojiLength))
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	if !singleEmoji(emoji) {
+		return nil, proto.ErrorInvalidArgument("emoji", notEmojiErr)
+	}
+
+	var (
+		msg     proto.ChatMessage
+		changed bool
+	)
+	if add {
+		msg, changed, err = d.db.AddReaction(ctx, claims.Email, messageUUID, emoji)
+	} else {
+		msg, changed, err = d.db.RemoveReaction(ctx, claims.Email, messageUUID, emoji)
+	}
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	if !changed {
+		return &msg, nil
+	}
+
+	reaction := proto.MessageReaction{
+		MessageUUID: msg.MessageUUID,
+		Email:       claims.Email,
+		Emoji:       emoji,
+		Reacted:     add,
+		Reactions:   msg.Reactions,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	err = d.publishConversation(event.Reaction, reaction, msg)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishReactionErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return &msg, nil
+}
+
+// singleEmoji reports whether s is one emoji as users see it: a flag, a keycap, or emoji
+// joined by zero width joiners, each of them optionally followed by the emoji variation
+// selector, a skin tone and the tags of subdivision flags
+func singleEmoji(s string) bool {
+	r := []rune(s)
+	switch {
+	case len(r) == 2 && regionalIndicator(r[0]) && regionalIndicator(r[1]):
+		return true
+	case len(r) >= 2 && len(r) <= 3 && strings.ContainsRune("0123456789#*", r[0]) && r[len(r)-1] == keycapMark:
+		return len(r) == 2 || r[1] == variationSelector16
+	}
+
+	for i := 0; ; i++ {
+		if i >= len(r) || !pictographic(r[i]) {
+			return false
+		}
+		i++
+		if i < len(r) && r[i] == variationSelector16 {
+			i++
+		}
+		if i < len(r) && skinTone(r[i]) {
+			i++
+		}
+		if i < len(r) && tag(r[i]) {
+			for i < len(r) && tag(r[i]) {
+				i++
+			}
+			if i >= len(r) || r[i] != tagCancel {
+				return false
+			}
+			i++
+		}
+		if i == len(r) {
+			return true
+		}
+		if r[i] != zeroWidthJoiner {
+			return false
+		}
+	}
+}
+
+// pictographic reports whether r is an emoji that can stand on its own
+func pictographic(r rune) bool {
+	switch {
+	case regionalIndicator(r), skinTone(r):
+		return false
+	case r >= 0x1f000 && r <= 0x1faff:
+		return true
+	case r >= 0x2600 && r <= 0x27bf:
+		return true
+	}
+	switch r {
+	case 0x00a9, 0x00ae, 0x203c, 0x2049, 0x2122, 0x2139, 0x2328, 0x23cf, 0x24c2,
+		0x25b6, 0x25c0, 0x2934, 0x2935, 0x2b50, 0x2b55, 0x3030, 0x303d, 0x3297, 0x3299:
+		return true
+	}
+	return r >= 0x2194 && r <= 0x2199 || r >= 0x21a9 && r <= 0x21aa ||
+		r >= 0x231a && r <= 0x231b || r >= 0x23e9 && r <= 0x23f3 ||
+		r >= 0x23f8 && r <= 0x23fa || r >= 0x25aa && r <= 0x25ab ||
+		r >= 0x25fb && r <= 0x25fe || r >= 0x2b05 && r <= 0x2b07 ||
+		r >= 0x2b1b && r <= 0x2b1c
+}
+
+// regionalIndicator reports whether r is one of the letters flags are made of
+func regionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// skinTone reports whether r is an emoji skin tone modifier
+func skinTone(r rune) bool {
+	return r >= 0x1f3fb && r <= 0x1f3ff
+}
+
+// tag reports whether r is a tag spelling the region of a subdivision flag
+func tag(r rune) bool {
+	return r >= 0xe0020 && r <= 0xe007e
+}
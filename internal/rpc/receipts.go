@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// MarkDelivered flags messages sent to the caller as delivered
+// and lets the senders know through their chat topic
+func (d *Chat) MarkDelivered(ctx context.Context, messageUUIDs []string) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.Val.Var(messageUUIDs, "required,dive,required")
+	if err != nil {
+		return false, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	changed, err := d.db.MarkDelivered(ctx, claims.Email, messageUUIDs)
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	return true, d.publishReceipts(changed)
+}
+
+// SetReadCursor moves the read cursor of the caller in their conversation with withEmail, or in
+// a room they are a member of, to messageUUID. Everything up to it is read so a whole backlog is
+// read in one call. The cursor is published to the other devices of the caller and, in direct
+// conversations, to the other participant. Cursors only move forward, an older message leaves it in place
+func (d *Chat) SetReadCursor(ctx context.Context, withEmail *string, roomID *string, messageUUID string) (*proto.ReadCursor, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	with, room, err := d.conversationArgs(claims.Email, withEmail, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+
+	cursor, changed, err := d.db.SetReadCursor(ctx, claims.Email, with, room, messageUUID, time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+	if !changed {
+		return &cursor, nil
+	}
+
+	emails := []string{claims.Email}
+	if with != "" {
+		emails = append(emails, with)
+	}
+	err = d.publish(event.ReadCursor, cursor, emails...)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishReadCursorErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return &cursor, nil
+}
+
+// publishReceipts publishes a receipt for every message to its sender topic
+func (d *Chat) publishReceipts(messages []proto.ChatMessage) error {
+	now := time.Now().UTC()
+	for _, msg := range messages {
+		receipt := proto.ChatReceipt{
+			MessageUUID: msg.MessageUUID,
+			FromEmail:   msg.FromEmail,
+			ToEmail:     msg.ToEmail,
+			Delivered:   msg.Delivered,
+			UpdatedAt:   now,
+		}
+		err := d.publish(event.Receipt, receipt, msg.FromEmail)
+		if err != nil {
+			d.rlog.Err(err).Msg(publishReceiptErr)
+			return proto.WrapError(proto.ErrInternal, err, brokerErr)
+		}
+	}
+	return nil
+}
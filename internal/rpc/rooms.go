@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// CreateRoom creates a group conversation between the caller and members
+func (d *Chat) CreateRoom(ctx context.Context, name string, members []string) (*proto.Room, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.Val.Var(name, "required,max=100")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	err = d.Val.Var(members, "dive,required,email")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	roomID, err := newUUID()
+	if err != nil {
+		d.rlog.Err(err).Msg(internalErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, internalErr)
+	}
+	now := time.Now().UTC()
+
+	room, err := d.db.CreateRoom(ctx, proto.Room{
+		RoomID:    roomID,
+		Name:      name,
+		Members:   append([]string{claims.Email}, members...),
+		CreatedBy: claims.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	// let the members streams follow the new room
+	err = d.publish(event.RoomJoined, room, room.Members...)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishRoomErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return &room, nil
+}
+
+// AddMember adds a user to a room the caller is a member of
+func (d *Chat) AddMember(ctx context.Context, roomID string, email string) (*proto.Room, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if roomID == "" {
+		return nil, proto.ErrorRequiredArgument("roomID")
+	}
+	err = d.Val.Var(email, "required,email")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	room, err := d.db.AddMember(ctx, claims.Email, roomID, email, time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	err = d.publishMembership(event.RoomJoined, room, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// RemoveMember removes a user from a room, members can remove themselves
+// while the room creator and admins can remove anyone. Admins are the
+// callers whose verified token carries the admin role
+func (d *Chat) RemoveMember(ctx context.Context, roomID string, email string) (*proto.Room, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if roomID == "" {
+		return nil, proto.ErrorRequiredArgument("roomID")
+	}
+	err = d.Val.Var(email, "required,email")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	room, err := d.db.RemoveMember(ctx, claims.Email, roomID, email, claims.IsAdmin(), time.Now().UTC())
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	err = d.publishMembership(event.RoomLeft, room, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// ListRooms returns the rooms the caller is a member of
+func (d *Chat) ListRooms(ctx context.Context) ([]*proto.Room, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms, err := d.db.ListRooms(ctx, claims.Email)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.Room, len(rooms))
+	for i := range rooms {
+		res[i] = &rooms[i]
+	}
+
+	return res, nil
+}
+
+// SendRoomMessage stores a message and publishes it to the room topic,
+// the message is a thread reply when parentMessageUUID is set
+func (d *Chat) SendRoomMessage(ctx context.Context, roomID string, messageText string, parentMessageUUID *string, attachmentIDs []string) (*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if roomID == "" {
+		return nil, proto.ErrorRequiredArgument("roomID")
+	}
+	if messageText == "" && len(attachmentIDs) == 0 {
+		return nil, proto.ErrorRequiredArgument("messageText")
+	}
+	err = d.Val.Var(attachmentIDs, "max=10,unique,dive,uuid")
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	messageUUID, err := newUUID()
+	if err != nil {
+		d.rlog.Err(err).Msg(internalErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, internalErr)
+	}
+	now := time.Now().UTC()
+
+	msg := proto.ChatMessage{
+		FromEmail:   claims.Email,
+		RoomID:      roomID,
+		MessageUUID: messageUUID,
+		PK:          roomKeyPrefix + roomID,
+		SK:          fromKeyPrefix + claims.Email,
+		MessageText: messageText,
+		UpdatedAt:   &now,
+		Version:     initialVersion,
+	}
+	if parentMessageUUID != nil {
+		msg.ParentMessageUUID = *parentMessageUUID
+	}
+	if len(attachmentIDs) > 0 {
+		msg.AttachmentIDs = attachmentIDs
+	}
+
+	err = d.moderate(&msg)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err = d.db.CreateChatMessage(ctx, claims.Email, msg)
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	err = d.publishRoomMessage(ctx, msg)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishChatMessageErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+	d.publishMentions(msg, mentionedAmong(msg, nil)...)
+
+	return &msg, nil
+}
+
+// ListRoomMessages returns the messages of a room the caller is a member of, newest first
+func (d *Chat) ListRoomMessages(ctx context.Context, roomID string, cursor string, limit int) ([]*proto.ChatMessage, string, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if roomID == "" {
+		return nil, "", proto.ErrorRequiredArgument("roomID")
+	}
+
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", proto.ErrorInvalidArgument("cursor", invalidCursorErr)
+	}
+
+	messages, next, err := d.db.ListRoomMessages(ctx, claims.Email, roomID, before, pageLimit(limit))
+	if err != nil {
+		return nil, "", d.dataError(err)
+	}
+
+	res := make([]*proto.ChatMessage, len(messages))
+	for i := range messages {
+		res[i] = &messages[i]
+	}
+
+	return res, encodeCursor(next), nil
+}
+
+// publishMembership tells the member streams to follow or leave the room
+// and lets the room know its members changed
+func (d *Chat) publishMembership(eventType string, room proto.Room, email string) error {
+	err := d.publish(eventType, room, email)
+	if err == nil {
+		err = d.publishRoom(event.RoomUpdated, room, room.RoomID)
+	}
+	if err != nil {
+		d.rlog.Err(err).Msg(publishRoomErr)
+		return proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+	return nil
+}
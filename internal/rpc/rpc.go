@@ -2,13 +2,29 @@ package rpc
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/erasure"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/expiry"
+	"github.com/rumsrami/example-service/internal/export"
+	"github.com/rumsrami/example-service/internal/moderation"
+	"github.com/rumsrami/example-service/internal/pending"
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/platform/blob"
 	"github.com/rumsrami/example-service/internal/platform/broker"
+	"github.com/rumsrami/example-service/internal/presence"
 	"github.com/rumsrami/example-service/internal/proto"
-	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/schedule"
+	"github.com/rumsrami/example-service/internal/thumbnail"
 )
 
 const (
@@ -20,8 +36,32 @@ const (
 	brokerErr             = "broker error"
 	internalErr           = "internal error"
 	reqValidationErr      = "invalid request body"
+	unauthenticatedErr    = "caller is not authenticated"
+	notSenderErr          = "fromEmail must be the caller"
 	chatTopicPrefix       = "users.chat."
+	roomTopicPrefix       = "users.chat.room."
+	toKeyPrefix           = "TO#"
+	fromKeyPrefix         = "FROM#"
+	roomKeyPrefix         = "ROOM#"
+	initialVersion        = "1"
 	publishChatMessageErr = "cannot publish chat message after creation"
+	publishReceiptErr     = "cannot publish chat receipt"
+	publishReadCursorErr  = "cannot publish read cursor"
+	publishEditErr        = "cannot publish chat message edit"
+	publishDeleteErr      = "cannot publish chat message deletion"
+	publishRoomErr        = "cannot publish room event"
+	publishTypingErr      = "cannot publish typing event"
+	publishPresenceErr    = "cannot publish presence event"
+	publishReactionErr    = "cannot publish reaction event"
+	publishMentionErr     = "cannot publish mention event"
+	// time allowed to record what a submitted message was published to
+	// even if the caller went away in the meantime
+	submissionTimeout = 5 * time.Second
+	// time a submitted message is left to the attempt publishing it
+	// before a retry takes over
+	publishLease = 30 * time.Second
+	// time allowed to queue an event for a user
+	queueTimeout = 5 * time.Second
 )
 
 // Shutdowner ....
@@ -29,6 +69,22 @@ type Shutdowner interface {
 	SignalShutdown()
 }
 
+// Config holds the chat settings
+type Config struct {
+	// how long after sending a message its author can delete it for everyone
+	DeleteWindow time.Duration
+	// how long a typing indicator lasts unless it is refreshed
+	TypingTimeout time.Duration
+	// how long a client MessageUUID is remembered to drop retried messages
+	DedupeWindow time.Duration
+	// largest attachment accepted, in bytes
+	MaxAttachmentSize int64
+	// storage every user can fill with attachments, in bytes
+	StorageQuota int64
+	// longest lifetime a conversation can give its messages
+	MaxMessageTTL time.Duration
+}
+
 // Chat represents an RPC server
 type Chat struct {
 	app   Shutdowner
@@ -37,20 +93,70 @@ type Chat struct {
 	rlog  zerolog.Logger
 	Val   *validator.Validate
 	mb    broker.MessageBroker
+	blobs blob.Store
+	cfg   Config
+	// typing indicators
+	typing *typingTracker
+	// users online status
+	presence *presence.Tracker
+	// image attachments processing
+	thumbnails *thumbnail.Generator
+	// messages held until they are due
+	scheduler *schedule.Scheduler
+	// stages new messages and edits go through
+	moderation *moderation.Pipeline
+	// data exports running in the background
+	exporter *export.Exporter
+	// user erasures
+	eraser *erasure.Eraser
+	// events published to users that none of their streams got yet
+	pending *pending.Queues
 }
 
 // NewChat ...
-func NewChat(app Shutdowner, build string, db *db.Database, appLog zerolog.Logger, val *validator.Validate, mb broker.MessageBroker) *Chat {
+func NewChat(app Shutdowner, build string, db *db.Database, appLog zerolog.Logger, val *validator.Validate, mb broker.MessageBroker, blobs blob.Store, cfg Config, tracker *presence.Tracker, thumbnails *thumbnail.Generator, scheduler *schedule.Scheduler, sweeper *expiry.Sweeper, pipeline *moderation.Pipeline, exporter *export.Exporter, eraser *erasure.Eraser, queues *pending.Queues) *Chat {
 	rpcLogger := appLog.With().Str(packageNameKey, packageName).Logger()
 
-	return &Chat{
-		app:   app,
-		build: build,
-		db:    db,
-		rlog:  rpcLogger,
-		Val:   val,
-		mb:    mb,
+	d := &Chat{
+		app:        app,
+		build:      build,
+		db:         db,
+		rlog:       rpcLogger,
+		Val:        val,
+		mb:         mb,
+		blobs:      blobs,
+		cfg:        cfg,
+		presence:   tracker,
+		thumbnails: thumbnails,
+		scheduler:  scheduler,
+		moderation: pipeline,
+		exporter:   exporter,
+		eraser:     eraser,
+		pending:    queues,
 	}
+
+	// expired typing indicators are cleared on the recipient side
+	d.typing = newTypingTracker(cfg.TypingTimeout, func(key typingKey) {
+		_ = d.publishTyping(key, false)
+	})
+
+	// contacts are told when a user goes online or offline
+	tracker.OnChange(d.presenceChanged)
+
+	// image dimensions and thumbnails are stored once ready
+	thumbnails.OnDone(d.thumbnailDone)
+
+	// scheduled messages are sent once due
+	scheduler.OnDue(d.dispatchScheduled)
+
+	// clients remove the messages once they expire
+	sweeper.OnExpired(d.messagesExpired)
+
+	// erased users are disconnected and forgotten by every instance
+	eraser.OnNotify(d.notifyErased)
+	eraser.OnErased(d.userErased)
+
+	return d
 }
 
 // Ping is a health check that returns an empty message.
@@ -68,23 +174,261 @@ func (d *Chat) Version(ctx context.Context) (*proto.Version, error) {
 	}, nil
 }
 
-// CreateChatMessage ...
+// CreateChatMessage validates, moderates, stores and publishes a chat message
+// from the caller to both the sender and the recipient topics, or holds it until SendAt when set.
+// A MessageUUID sent by the client is used as an idempotency key, retrying
+// with the same one within the dedupe window neither stores nor publishes the message again
 func (d *Chat) CreateChatMessage(ctx context.Context, req *proto.ChatMessage) (bool, error) {
-	// 1 - Add the chat message to dynamo db
-	// 2 - publish to topic
-	/*
-	err = d.mb.Pub(fmt.Sprintf("%s%s", chatTopicPrefix, req.ToEmail), byteMessage)
+	claims, err := caller(ctx)
 	if err != nil {
-		c.rlog.Err(err).Msg(publishChatMessageErr)
-		return false, proto.WrapError(proto.ErrInternal, err, internalErr)
+		return false, err
 	}
-	
-	err = d.mb.Pub(fmt.Sprintf("%s%s", chatTopicPrefix, req.FromEmail), byteMessage)
+
+	if req == nil {
+		return false, proto.ErrorRequiredArgument("req")
+	}
+
+	err = d.Val.Struct(req)
+	if err != nil {
+		return false, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	// users only send messages as themselves
+	if req.FromEmail != claims.Email {
+		return false, proto.Errorf(proto.ErrPermissionDenied, notSenderErr)
+	}
+
+	err = d.Val.Var(req.MessageUUID, "omitempty,uuid")
+	if err != nil {
+		return false, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	err = d.Val.Var(req.ParentMessageUUID, "omitempty,uuid")
 	if err != nil {
-		c.rlog.Err(err).Msg(publishChatMessageErr)
+		return false, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	if req.MessageText == "" && len(req.AttachmentIDs) == 0 {
+		return false, proto.ErrorRequiredArgument("messageText")
+	}
+	idempotencyKey := req.MessageUUID
+
+	// server side fields, never trust the client with those
+	messageUUID, err := newUUID()
+	if err != nil {
+		d.rlog.Err(err).Msg(internalErr)
 		return false, proto.WrapError(proto.ErrInternal, err, internalErr)
 	}
-	
-	*/
-	return false, nil
+	now := time.Now().UTC()
+
+	msg := *req
+	msg.RoomID = ""
+	msg.MessageUUID = messageUUID
+	msg.PK = fmt.Sprintf("%s%s", toKeyPrefix, msg.ToEmail)
+	msg.SK = fmt.Sprintf("%s%s", fromKeyPrefix, msg.FromEmail)
+	msg.UpdatedAt = &now
+	msg.Delivered = false
+	msg.Version = initialVersion
+	msg.Deleted = false
+	msg.ReplyCount = 0
+	msg.LastReplyAt = nil
+	msg.Reactions = nil
+	msg.ExpiresAt = nil
+	msg.Muted = false
+	msg.Annotations = nil
+	msg.Mentions = nil
+
+	err = d.moderate(&msg)
+	if err != nil {
+		return false, err
+	}
+
+	// scheduled messages are held until they are due
+	if msg.SendAt != nil && msg.SendAt.After(now) {
+		return d.scheduleChatMessage(msg, idempotencyKey)
+	}
+	msg.SendAt = nil
+
+	recipients := []string{msg.ToEmail, msg.FromEmail}
+
+	// 1 - Add the chat message to the db, a retry gets back the stored
+	// message and the recipients it was not published to yet
+	if idempotencyKey != "" {
+		msg, recipients, err = d.db.SubmitChatMessage(ctx, claims.Email, idempotencyKey, msg, recipients, now.Add(-d.cfg.DedupeWindow), publishLease)
+	} else {
+		msg, err = d.db.CreateChatMessage(ctx, claims.Email, msg)
+	}
+	if err != nil {
+		return false, d.dataError(err)
+	}
+
+	// the message ends the sender typing indicator, clients clear it
+	// when the message comes in so there is nothing to publish
+	d.typing.set(typingKey{from: msg.FromEmail, to: msg.ToEmail}, false)
+
+	// 2 - publish to topic
+	published, err := d.publishMessage(ctx, msg, recipients...)
+	// a retry only mentions the users it is the first to publish the message to
+	d.publishMentions(msg, mentionedAmong(msg, published)...)
+
+	if idempotencyKey != "" {
+		sctx, cancel := context.WithTimeout(context.Background(), submissionTimeout)
+		serr := d.db.PublishedChatMessage(sctx, msg.FromEmail, idempotencyKey, published)
+		cancel()
+		if serr != nil {
+			d.rlog.Err(serr).Msg(dataErr)
+		}
+	}
+
+	if err != nil {
+		d.rlog.Err(err).Msg(publishChatMessageErr)
+		return false, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return true, nil
+}
+
+// publish wraps data in an event envelope and publishes it
+// to the chat topic of every given user
+func (d *Chat) publish(eventType string, data interface{}, emails ...string) error {
+	ev, err := event.New(eventType, data, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		err = d.publishUser(email, ev)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishUser queues ev for a user before publishing it to their chat topic, streams
+// that are not connected get it once they are. Ephemeral events are only published
+func (d *Chat) publishUser(email string, ev event.Event) error {
+	if !event.Ephemeral(ev.Type) {
+		ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+		defer cancel()
+
+		var err error
+		ev, err = d.pending.Queue(ctx, ev, email)
+		if err != nil {
+			return err
+		}
+	}
+
+	byteMessage, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return d.mb.Pub(userTopic(email), byteMessage)
+}
+
+// publishRoom wraps data in an event envelope and publishes it to a room topic
+func (d *Chat) publishRoom(eventType string, data interface{}, roomID string) error {
+	ev, err := event.New(eventType, data, nil)
+	if err != nil {
+		return err
+	}
+	return d.publishRoomEvent(roomID, ev)
+}
+
+// publishRoomEvent queues ev for every member of a room before publishing it to the
+// room topic, the streams of the members that are not connected get it once they are
+func (d *Chat) publishRoomEvent(roomID string, ev event.Event) error {
+	members, err := d.roomMembers(roomID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+	defer cancel()
+	ev, err = d.pending.Queue(ctx, ev, members...)
+	if err != nil {
+		return err
+	}
+
+	byteMessage, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return d.mb.Pub(roomTopic(roomID), byteMessage)
+}
+
+// roomMembers returns the members of a room the events of the room are queued for
+func (d *Chat) roomMembers(roomID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+	defer cancel()
+	return d.db.RoomMembers(ctx, roomID)
+}
+
+// publishConversation publishes an event about msg to its conversation,
+// the room topic for room messages and both users topics otherwise
+func (d *Chat) publishConversation(eventType string, data interface{}, msg proto.ChatMessage) error {
+	if msg.RoomID != "" {
+		return d.publishRoom(eventType, data, msg.RoomID)
+	}
+	return d.publish(eventType, data, msg.ToEmail, msg.FromEmail)
+}
+
+// userTopic returns the chat topic of a user
+func userTopic(email string) string {
+	return fmt.Sprintf("%s%s", chatTopicPrefix, email)
+}
+
+// roomTopic returns the chat topic of a room
+func roomTopic(roomID string) string {
+	return fmt.Sprintf("%s%s", roomTopicPrefix, roomID)
+}
+
+// moderate runs msg through the moderation stages,
+// rejections are reported as invalid messageText naming the stage
+func (d *Chat) moderate(msg *proto.ChatMessage) error {
+	err := d.moderation.Process(msg)
+	if err != nil {
+		return proto.ErrorInvalidArgument("messageText", err.Error())
+	}
+	return nil
+}
+
+// dataError maps db errors to webrpc errors
+func (d *Chat) dataError(err error) error {
+	switch errors.Cause(err) {
+	case db.ErrMessageNotFound, db.ErrRoomNotFound, db.ErrAttachmentNotFound, db.ErrExportNotFound:
+		return proto.WrapError(proto.ErrNotFound, err, dataErr)
+	case db.ErrNotRecipient, db.ErrNotAuthor, db.ErrNotSender, db.ErrNotParticipant, db.ErrNotMember, db.ErrBlocked:
+		return proto.WrapError(proto.ErrPermissionDenied, err, dataErr)
+	case db.ErrVersionConflict, db.ErrSubmissionInProgress, db.ErrBlobDeleted:
+		return proto.WrapError(proto.ErrAborted, err, dataErr)
+	case db.ErrDeleteWindowPassed, db.ErrMessageDeleted, db.ErrNestedReply, db.ErrExportNotDone:
+		return proto.WrapError(proto.ErrFailedPrecondition, err, dataErr)
+	case db.ErrQuotaExceeded:
+		return proto.WrapError(proto.ErrResourceExhausted, err, dataErr)
+	case context.Canceled:
+		return proto.WrapError(proto.ErrCanceled, err, dataErr)
+	case context.DeadlineExceeded:
+		return proto.WrapError(proto.ErrDeadlineExceeded, err, dataErr)
+	}
+	d.rlog.Err(err).Msg(dataErr)
+	return proto.WrapError(proto.ErrInternal, err, dataErr)
+}
+
+// caller returns the claims of the authenticated caller
+func caller(ctx context.Context) (auth.Claims, error) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok || claims.Email == "" {
+		return auth.Claims{}, proto.Errorf(proto.ErrUnauthenticated, unauthenticatedErr)
+	}
+	return claims, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
 }
@@ -0,0 +1,494 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/erasure"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/expiry"
+	"github.com/rumsrami/example-service/internal/export"
+	"github.com/rumsrami/example-service/internal/moderation"
+	"github.com/rumsrami/example-service/internal/pending"
+	"github.com/rumsrami/example-service/internal/platform/auth"
+	"github.com/rumsrami/example-service/internal/platform/blob"
+	"github.com/rumsrami/example-service/internal/presence"
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/schedule"
+	"github.com/rumsrami/example-service/internal/thumbnail"
+)
+
+// recordingBroker keeps the events published to every topic,
+// publishing to the topics in failing returns an error instead
+type recordingBroker struct {
+	mu        sync.Mutex
+	published map[string][]event.Event
+	failing   map[string]bool
+}
+
+func (b *recordingBroker) Pub(topic string, message interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failing[topic] {
+		return errors.New("broker unavailable")
+	}
+	var ev event.Event
+	err := json.Unmarshal(message.([]byte), &ev)
+	if err != nil {
+		return err
+	}
+	b.published[topic] = append(b.published[topic], ev)
+	return nil
+}
+
+func (b *recordingBroker) Sub(ctx context.Context, topic string, receive chan []byte, errCh chan error) {
+	<-ctx.Done()
+}
+
+// events returns the types of the events published to the chat topic of email
+func (b *recordingBroker) events(email string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var types []string
+	for _, ev := range b.published[userTopic(email)] {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+type noShutdown struct{}
+
+func (noShutdown) SignalShutdown() {}
+
+// newTestChat returns a chat over a running database and stores in a temporary directory
+func newTestChat(t *testing.T) (*Chat, *db.Database, *recordingBroker) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	database := db.NewDatabase()
+	go database.Run()
+	t.Cleanup(database.Stop)
+
+	mb := &recordingBroker{published: make(map[string][]event.Event), failing: make(map[string]bool)}
+	blobs, err := blob.NewFileStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := presence.NewTracker(mb, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler, err := schedule.NewScheduler(filepath.Join(dir, "scheduled"), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eraser, err := erasure.NewEraser(filepath.Join(dir, "erasures"), database, blobs, scheduler, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queues, err := pending.NewQueues(database, mb, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		DeleteWindow:      time.Hour,
+		TypingTimeout:     time.Second,
+		DedupeWindow:      time.Hour,
+		MaxAttachmentSize: 1 << 20,
+		StorageQuota:      1 << 20,
+		MaxMessageTTL:     time.Hour,
+	}
+	chat := NewChat(noShutdown{}, "test", database, zerolog.Nop(), validator.New(), mb, blobs, cfg, tracker,
+		thumbnail.NewGenerator(blobs, 1, 64), scheduler, expiry.NewSweeper(database, time.Hour),
+		moderation.NewPipeline(), export.NewExporter(database, blobs, scheduler, 1), eraser, queues)
+	return chat, database, mb
+}
+
+// as returns a context authenticated as email
+func as(email string) context.Context {
+	return auth.WithClaims(context.Background(), auth.Claims{Email: email, Role: "user"})
+}
+
+// code returns the webrpc code of err
+func code(err error) proto.ErrorCode {
+	rpcErr, ok := err.(proto.Error)
+	if !ok {
+		return ""
+	}
+	return rpcErr.Code()
+}
+
+func TestCreateChatMessageOnlySendsAsTheCaller(t *testing.T) {
+	chat, _, mb := newTestChat(t)
+	msg := &proto.ChatMessage{FromEmail: "victim@x.com", ToEmail: "b@x.com", MessageText: "hi"}
+
+	_, err := chat.CreateChatMessage(context.Background(), msg)
+	if code(err) != proto.ErrUnauthenticated {
+		t.Fatalf("anonymous caller: err = %v, want %s", err, proto.ErrUnauthenticated)
+	}
+	_, err = chat.CreateChatMessage(as("a@x.com"), msg)
+	if code(err) != proto.ErrPermissionDenied {
+		t.Fatalf("spoofed sender: err = %v, want %s", err, proto.ErrPermissionDenied)
+	}
+	if events := mb.events("b@x.com"); len(events) != 0 {
+		t.Fatalf("published %v", events)
+	}
+
+	msg.FromEmail = "a@x.com"
+	_, err = chat.CreateChatMessage(as("a@x.com"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@x.com", "b@x.com"} {
+		if events := mb.events(email); len(events) != 1 || events[0] != event.Message {
+			t.Fatalf("published %v to %s, want a message", events, email)
+		}
+	}
+}
+
+func TestMessagesOnlyCarryAttachmentsOfTheSender(t *testing.T) {
+	chat, database, _ := newTestChat(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	ofA, ofC := "0b7f3a52-8c1e-4d0f-9e4b-5a2c6d8e1f03", "9d2e4c61-3b7a-4f18-8c5d-2e6f1a9b7c04"
+	for owner, attachmentID := range map[string]string{"a@x.com": ofA, "c@x.com": ofC} {
+		attachment := proto.Attachment{AttachmentID: attachmentID, OwnerEmail: owner, FileName: "f.txt", Size: 1}
+		err := database.CreateAttachment(ctx, attachment, "blob-"+owner, 0, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := chat.CreateChatMessage(as("a@x.com"), &proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", AttachmentIDs: []string{ofC}})
+	if code(err) != proto.ErrNotFound {
+		t.Fatalf("attachment of another user: err = %v, want %s", err, proto.ErrNotFound)
+	}
+	_, _, err = database.GetAttachment(ctx, "b@x.com", ofC)
+	if errors.Cause(err) != db.ErrAttachmentNotFound {
+		t.Fatalf("the recipient can get the attachment of another user: err = %v", err)
+	}
+
+	_, err = chat.CreateChatMessage(as("a@x.com"), &proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", AttachmentIDs: []string{ofA}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = database.GetAttachment(ctx, "b@x.com", ofA)
+	if err != nil {
+		t.Fatalf("the recipient can't get the attachment sent to them: %v", err)
+	}
+}
+
+func TestBlockedUsersCannotSendMessages(t *testing.T) {
+	chat, _, mb := newTestChat(t)
+
+	_, err := chat.BlockUser(as("b@x.com"), "a@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the blocked user is not told
+	if published := mb.events("a@x.com"); len(published) != 0 {
+		t.Fatalf("published %v to the blocked user", published)
+	}
+	_, err = chat.CreateChatMessage(as("a@x.com"), &proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageText: "hi"})
+	if code(err) != proto.ErrPermissionDenied {
+		t.Fatalf("err = %v, want %s", err, proto.ErrPermissionDenied)
+	}
+	for _, ev := range mb.events("b@x.com") {
+		if ev == event.Message {
+			t.Fatal("the message of a blocked user was published")
+		}
+	}
+
+	// the blocker can still write to them
+	_, err = chat.CreateChatMessage(as("b@x.com"), &proto.ChatMessage{FromEmail: "b@x.com", ToEmail: "a@x.com", MessageText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chat.UnblockUser(as("b@x.com"), "a@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = chat.CreateChatMessage(as("a@x.com"), &proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageText: "hi"})
+	if err != nil {
+		t.Fatalf("unblocked: %v", err)
+	}
+}
+
+func TestRetriedMessagesArePublishedToTheRemainingRecipients(t *testing.T) {
+	chat, _, mb := newTestChat(t)
+	msg := &proto.ChatMessage{
+		FromEmail:   "a@x.com",
+		ToEmail:     "b@x.com",
+		MessageUUID: "6f1c0f4e-2a1d-4c55-9a4e-1f7e1a0c6b6d",
+		MessageText: "hi",
+	}
+
+	mb.failing[userTopic("a@x.com")] = true
+	_, err := chat.CreateChatMessage(as("a@x.com"), msg)
+	if code(err) != proto.ErrInternal {
+		t.Fatalf("err = %v, want %s", err, proto.ErrInternal)
+	}
+
+	// the retry takes over right away, the failed attempt gave up its lease
+	mb.failing[userTopic("a@x.com")] = false
+	_, err = chat.CreateChatMessage(as("a@x.com"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = chat.CreateChatMessage(as("a@x.com"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"a@x.com", "b@x.com"} {
+		if events := mb.events(email); len(events) != 1 {
+			t.Fatalf("published %v to %s, want the message once", events, email)
+		}
+	}
+}
+
+func TestOnlyAdminsEraseUsers(t *testing.T) {
+	chat, _, _ := newTestChat(t)
+
+	_, err := chat.EraseUser(context.Background(), "b@x.com")
+	if code(err) != proto.ErrUnauthenticated {
+		t.Fatalf("anonymous caller: err = %v, want %s", err, proto.ErrUnauthenticated)
+	}
+	_, err = chat.EraseUser(as("a@x.com"), "b@x.com")
+	if code(err) != proto.ErrPermissionDenied {
+		t.Fatalf("user: err = %v, want %s", err, proto.ErrPermissionDenied)
+	}
+
+	admin := auth.WithClaims(context.Background(), auth.Claims{Email: "admin@x.com", Role: auth.RoleAdmin})
+	erasure, err := chat.EraseUser(admin, "b@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erasure.Email != "b@x.com" || erasure.RequestedBy != "admin@x.com" {
+		t.Fatalf("erasure = %+v, want b@x.com erased by admin@x.com", erasure)
+	}
+}
+
+// asAdmin returns a context authenticated as email with the admin role
+func asAdmin(email string) context.Context {
+	return auth.WithClaims(context.Background(), auth.Claims{Email: email, Role: auth.RoleAdmin})
+}
+
+func TestOnlyAuthorsAndAdminsDeleteMessages(t *testing.T) {
+	chat, database, mb := newTestChat(t)
+
+	msg, err := database.CreateChatMessage(context.Background(), "a@x.com", proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "m1", MessageText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chat.DeleteChatMessage(as("b@x.com"), msg.MessageUUID)
+	if code(err) != proto.ErrPermissionDenied {
+		t.Fatalf("recipient: err = %v, want %s", err, proto.ErrPermissionDenied)
+	}
+	if events := mb.events("b@x.com"); len(events) != 0 {
+		t.Fatalf("published %v", events)
+	}
+
+	deleted, err := chat.DeleteChatMessage(asAdmin("mod@x.com"), msg.MessageUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleted.Deleted {
+		t.Fatal("the message was not deleted")
+	}
+}
+
+func TestOnlyCreatorsAndAdminsRemoveMembers(t *testing.T) {
+	chat, _, _ := newTestChat(t)
+
+	room, err := chat.CreateRoom(as("a@x.com"), "team", []string{"b@x.com", "c@x.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chat.RemoveMember(as("b@x.com"), room.RoomID, "c@x.com")
+	if code(err) != proto.ErrPermissionDenied {
+		t.Fatalf("member: err = %v, want %s", err, proto.ErrPermissionDenied)
+	}
+
+	room, err = chat.RemoveMember(asAdmin("mod@x.com"), room.RoomID, "c@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range room.Members {
+		if member == "c@x.com" {
+			t.Fatal("the member was not removed")
+		}
+	}
+}
+
+func TestDroppedScheduledMessagesAreReportedToTheSender(t *testing.T) {
+	chat, _, mb := newTestChat(t)
+
+	_, err := chat.BlockUser(as("b@x.com"), "a@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendAt := time.Now().UTC()
+	scheduled := proto.ChatMessage{
+		FromEmail:   "a@x.com",
+		ToEmail:     "b@x.com",
+		MessageUUID: "6f1c0f4e-2a1d-4c55-9a4e-1f7e1a0c6b6d",
+		MessageText: "hi",
+		SendAt:      &sendAt,
+	}
+	err = chat.dispatchScheduled(context.Background(), scheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	var failures []proto.ScheduledMessageFailure
+	for _, ev := range mb.published[userTopic("a@x.com")] {
+		if ev.Type != event.ScheduledFailed {
+			continue
+		}
+		var failure proto.ScheduledMessageFailure
+		err := json.Unmarshal(ev.Data, &failure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		failures = append(failures, failure)
+	}
+	if len(failures) != 1 || failures[0].Message == nil || failures[0].Message.MessageUUID != scheduled.MessageUUID {
+		t.Fatalf("failures %+v, want one of %s", failures, scheduled.MessageUUID)
+	}
+	if failures[0].Reason != db.ErrBlocked.Error() {
+		t.Fatalf("reason %q, want %q", failures[0].Reason, db.ErrBlocked.Error())
+	}
+}
+
+func TestRefreshedTypingIndicatorsDoNotExpire(t *testing.T) {
+	expired := make(chan typingKey, 10)
+	tracker := newTypingTracker(100*time.Millisecond, func(key typingKey) {
+		expired <- key
+	})
+	key := typingKey{from: "a@x.com", to: "b@x.com"}
+
+	if !tracker.set(key, true) {
+		t.Fatal("starting to type was not a change")
+	}
+	for i := 0; i < 20; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if tracker.set(key, true) {
+			t.Fatal("refreshing was a change")
+		}
+	}
+	select {
+	case <-expired:
+		t.Fatal("the refreshed indicator expired")
+	default:
+	}
+
+	select {
+	case got := <-expired:
+		if got != key {
+			t.Fatalf("expired %v, want %v", got, key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the indicator never expired")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if len(expired) != 0 {
+		t.Fatal("the indicator expired more than once")
+	}
+}
+
+func TestReactionsAreSingleEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		ok    bool
+	}{
+		{"👍", true},
+		{"❤️", true},
+		{"👍🏽", true},
+		{"👩‍👩‍👧‍👦", true},
+		{"🧑🏿‍🚀", true},
+		{"🇫🇷", true},
+		{"1️⃣", true},
+		{"🏴󠁧󠁢󠁳󠁣󠁴󠁿", true},
+		{"a", false},
+		{"👍👍", false},
+		{"👍 ", false},
+		{"🇫", false},
+		{"🏽", false},
+		{"👍‍", false},
+		{"1", false},
+		{"<b>", false},
+	}
+	for _, tt := range tests {
+		if ok := singleEmoji(tt.emoji); ok != tt.ok {
+			t.Errorf("singleEmoji(%q) = %v, want %v", tt.emoji, ok, tt.ok)
+		}
+	}
+
+	chat, database, _ := newTestChat(t)
+	_, err := database.CreateChatMessage(context.Background(), "a@x.com", proto.ChatMessage{FromEmail: "a@x.com", ToEmail: "b@x.com", MessageUUID: "m1", MessageText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = chat.AddReaction(as("b@x.com"), "m1", "lol")
+	if code(err) != proto.ErrInvalidArgument {
+		t.Fatalf("reacting with text: err = %v, want %s", err, proto.ErrInvalidArgument)
+	}
+	msg, err := chat.AddReaction(as("b@x.com"), "m1", "👍🏽")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Reactions) != 1 || msg.Reactions[0].Emoji != "👍🏽" {
+		t.Fatalf("reactions %+v, want 👍🏽", msg.Reactions)
+	}
+}
+
+func TestPresenceIsOnlyToldToContactsThatAreNotBlocked(t *testing.T) {
+	chat, database, _ := newTestChat(t)
+	ctx := context.Background()
+
+	for _, to := range []string{"b@x.com", "d@x.com"} {
+		_, err := database.CreateChatMessage(ctx, "a@x.com", proto.ChatMessage{FromEmail: "a@x.com", ToEmail: to, MessageUUID: "to-" + to, MessageText: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := database.BlockUser(ctx, "d@x.com", "a@x.com", time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"b@x.com", "c@x.com", "d@x.com"} {
+		chat.presence.Connect(email)
+	}
+
+	presence, err := chat.GetPresence(as("a@x.com"), []string{"b@x.com", "c@x.com", "d@x.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(presence) != 1 || presence[0].Email != "b@x.com" || !presence[0].Online {
+		t.Fatalf("presence %+v, want b@x.com online only", presence)
+	}
+}
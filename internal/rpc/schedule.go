@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/db"
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/schedule"
+)
+
+const (
+	// messages can't be scheduled further away
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// Errors
+	scheduleErr         = "scheduled message error"
+	scheduleTooLateErr  = "messages can't be scheduled more than a year ahead"
+	dropScheduledErr    = "scheduled message can't be sent, dropping it"
+	publishFailureErr   = "cannot publish scheduled message failure"
+	scheduledNotSentErr = "cannot store scheduled message"
+)
+
+// ListScheduledMessages returns the messages the caller scheduled that are not sent yet, the first due first
+func (d *Chat) ListScheduledMessages(ctx context.Context) ([]*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := d.scheduler.List(claims.Email)
+	if err != nil {
+		d.rlog.Err(err).Msg(scheduleErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, scheduleErr)
+	}
+
+	res := make([]*proto.ChatMessage, len(messages))
+	for i := range messages {
+		res[i] = &messages[i]
+	}
+
+	return res, nil
+}
+
+// CancelScheduledMessage drops a message the caller scheduled, it can't be
+// cancelled once it is being sent
+func (d *Chat) CancelScheduledMessage(ctx context.Context, messageUUID string) (*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageUUID == "" {
+		return nil, proto.ErrorRequiredArgument("messageUUID")
+	}
+
+	msg, err := d.scheduler.Cancel(claims.Email, messageUUID)
+	if errors.Cause(err) == schedule.ErrNotFound {
+		return nil, proto.WrapError(proto.ErrNotFound, err, scheduleErr)
+	}
+	if err != nil {
+		d.rlog.Err(err).Msg(scheduleErr)
+		return nil, proto.WrapError(proto.ErrInternal, err, scheduleErr)
+	}
+
+	return &msg, nil
+}
+
+// scheduleChatMessage holds a new chat message until msg.SendAt.
+// Retries of a message sent with an idempotency key get the same MessageUUID,
+// so it is held only once, and stored only once if it was already sent
+func (d *Chat) scheduleChatMessage(msg proto.ChatMessage, idempotencyKey string) (bool, error) {
+	if msg.SendAt.After(time.Now().Add(maxScheduleAhead)) {
+		return false, proto.Errorf(proto.ErrInvalidArgument, scheduleTooLateErr)
+	}
+
+	sendAt := msg.SendAt.UTC()
+	msg.SendAt = &sendAt
+	if idempotencyKey != "" {
+		msg.MessageUUID = nameUUID(submissionName(msg.FromEmail, idempotencyKey))
+	}
+
+	err := d.scheduler.Schedule(msg)
+	if errors.Cause(err) == schedule.ErrExists {
+		return true, nil
+	}
+	if err != nil {
+		d.rlog.Err(err).Msg(scheduleErr)
+		return false, proto.WrapError(proto.ErrInternal, err, scheduleErr)
+	}
+
+	return true, nil
+}
+
+// dispatchScheduled stores and publishes a scheduled message once it is due,
+// an error has the scheduler try again later. The sender is told when it is dropped
+func (d *Chat) dispatchScheduled(ctx context.Context, scheduled proto.ChatMessage) error {
+	now := time.Now().UTC()
+	scheduled.SendAt = nil
+	scheduled.UpdatedAt = &now
+
+	// the sender was authenticated when the message was scheduled
+	msg, err := d.db.CreateChatMessage(ctx, scheduled.FromEmail, scheduled)
+	switch errors.Cause(err) {
+	case nil:
+	// sent by an earlier attempt
+	case db.ErrMessageExists:
+		return nil
+	case context.Canceled, context.DeadlineExceeded:
+		return err
+	default:
+		// the parent or attachments of the message are gone, or the recipient blocked the sender
+		d.rlog.Err(err).Msgf("%v : %s", dropScheduledErr, scheduled.MessageUUID)
+		failure := proto.ScheduledMessageFailure{
+			Message:  &scheduled,
+			Reason:   errors.Cause(err).Error(),
+			FailedAt: now,
+		}
+		err = d.publish(event.ScheduledFailed, failure, scheduled.FromEmail)
+		if err != nil {
+			d.rlog.Err(err).Msg(publishFailureErr)
+		}
+		return nil
+	}
+
+	d.typing.set(typingKey{from: msg.FromEmail, to: msg.ToEmail}, false)
+
+	// the message is stored, trying again would not publish it
+	published, err := d.publishMessage(ctx, msg, msg.ToEmail, msg.FromEmail)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishChatMessageErr)
+	}
+	d.publishMentions(msg, mentionedAmong(msg, published)...)
+	return nil
+}
+
+// submissionName returns a name unique to an idempotency key of a sender
+func submissionName(sender, idempotencyKey string) string {
+	return fmt.Sprintf("%s#%s", sender, idempotencyKey)
+}
+
+// nameUUID returns a name based (version 5) UUID, the same name always gets the same UUID
+func nameUUID(name string) string {
+	b := sha1.Sum([]byte(name))
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
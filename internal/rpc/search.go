@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/proto"
+	"github.com/rumsrami/example-service/internal/search"
+)
+
+const (
+	// longest search query accepted, in runes
+	maxQueryLength = 256
+	// words of a search query past that are ignored
+	maxQueryTerms = 10
+	// Errors
+	emptyQueryErr = "search query has no words"
+)
+
+// SearchMessages returns the messages of the caller conversations matching every word of query,
+// query words also match the longer words they start. Results are ranked with the best match first
+// and optionally restricted to the conversation with withEmail and to the messages sent between after and before
+func (d *Chat) SearchMessages(ctx context.Context, query string, withEmail *string, before *time.Time, after *time.Time, limit int) ([]*proto.SearchResult, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.Val.Var(query, fmt.Sprintf("required,max=%d", maxQueryLength))
+	if err != nil {
+		return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return nil, proto.Errorf(proto.ErrInvalidArgument, emptyQueryErr)
+	}
+	if len(terms) > maxQueryTerms {
+		terms = terms[:maxQueryTerms]
+	}
+
+	var with string
+	if withEmail != nil {
+		with = *withEmail
+		err = d.Val.Var(with, "required,email")
+		if err != nil {
+			return nil, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+		}
+	}
+
+	var from, to time.Time
+	if after != nil {
+		from = *after
+	}
+	if before != nil {
+		to = *before
+	}
+
+	results, err := d.db.SearchMessages(ctx, claims.Email, terms, with, from, to, pageLimit(limit))
+	if err != nil {
+		return nil, d.dataError(err)
+	}
+
+	res := make([]*proto.SearchResult, len(results))
+	for i := range results {
+		result := &results[i]
+		for _, part := range search.Snippet(result.Message.MessageText, terms) {
+			result.Snippet = append(result.Snippet, &proto.SnippetPart{Text: part.Text, Highlight: part.Match})
+		}
+		res[i] = result
+	}
+
+	return res, nil
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// ListThread returns a message along with the replies of its thread, oldest first
+func (d *Chat) ListThread(ctx context.Context, parentMessageUUID string) (*proto.ChatMessage, []*proto.ChatMessage, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parentMessageUUID == "" {
+		return nil, nil, proto.ErrorRequiredArgument("parentMessageUUID")
+	}
+
+	parent, replies, err := d.db.ListThread(ctx, claims.Email, parentMessageUUID)
+	if err != nil {
+		return nil, nil, d.dataError(err)
+	}
+
+	res := make([]*proto.ChatMessage, len(replies))
+	for i := range replies {
+		res[i] = &replies[i]
+	}
+
+	return &parent, res, nil
+}
+
+// messageEvent returns the event a new chat message goes out as, thread
+// replies carry the counters of their parent so clients don't have to refetch it
+func (d *Chat) messageEvent(ctx context.Context, msg proto.ChatMessage) (string, interface{}, error) {
+	if msg.ParentMessageUUID == "" {
+		return event.Message, msg, nil
+	}
+
+	parent, err := d.db.GetChatMessage(ctx, msg.FromEmail, msg.ParentMessageUUID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	reply := proto.ThreadReply{
+		Reply:      &msg,
+		ReplyCount: parent.ReplyCount,
+	}
+	if parent.LastReplyAt != nil {
+		reply.LastReplyAt = *parent.LastReplyAt
+	}
+	return event.Reply, reply, nil
+}
+
+// messageEnvelope returns the event a new chat message goes out as,
+// along with the users that muted its conversation
+func (d *Chat) messageEnvelope(ctx context.Context, msg proto.ChatMessage) (event.Event, error) {
+	eventType, data, err := d.messageEvent(ctx, msg)
+	if err != nil {
+		return event.Event{}, err
+	}
+
+	muted, err := d.db.MutedBy(ctx, msg)
+	if err != nil {
+		return event.Event{}, err
+	}
+
+	return event.New(eventType, data, muted)
+}
+
+// publishMessage publishes a new chat message to the chat topic of every
+// given user and returns the ones it was published to
+func (d *Chat) publishMessage(ctx context.Context, msg proto.ChatMessage, emails ...string) ([]string, error) {
+	ev, err := d.messageEnvelope(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	published := make([]string, 0, len(emails))
+	for _, email := range emails {
+		err = d.publishUser(email, ev)
+		if err != nil {
+			return published, err
+		}
+		published = append(published, email)
+	}
+	return published, nil
+}
+
+// publishRoomMessage publishes a new chat message to its room topic
+func (d *Chat) publishRoomMessage(ctx context.Context, msg proto.ChatMessage) error {
+	ev, err := d.messageEnvelope(ctx, msg)
+	if err != nil {
+		return err
+	}
+	return d.publishRoomEvent(msg.RoomID, ev)
+}
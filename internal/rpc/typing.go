@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rumsrami/example-service/internal/event"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+// typingKey identifies who is typing to whom
+type typingKey struct {
+	from string
+	to   string
+}
+
+// typingTracker coalesces typing calls and expires the ones clients stop refreshing,
+// typing indicators only live here and are never persisted
+type typingTracker struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	timers   map[typingKey]*time.Timer
+	onExpire func(typingKey)
+}
+
+func newTypingTracker(timeout time.Duration, onExpire func(typingKey)) *typingTracker {
+	return &typingTracker{
+		timeout:  timeout,
+		timers:   make(map[typingKey]*time.Timer),
+		onExpire: onExpire,
+	}
+}
+
+// set records whether key is typing and reports if that changed,
+// refreshing an indicator only pushes its expiry back
+func (t *typingTracker) set(key typingKey, typing bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timer, ok := t.timers[key]
+	switch {
+	case typing && ok:
+		// the old timer may have fired already and be waiting for the lock,
+		// it finds a new timer tracking key and leaves it alone
+		timer.Stop()
+		t.timers[key] = t.start(key)
+		return false
+	case typing:
+		t.timers[key] = t.start(key)
+		return true
+	case ok:
+		timer.Stop()
+		delete(t.timers, key)
+		return true
+	}
+	return false
+}
+
+// start returns a timer expiring key unless it is replaced or stopped before.
+// Must be called with the lock held
+func (t *typingTracker) start(key typingKey) *time.Timer {
+	var expiry *time.Timer
+	expiry = time.AfterFunc(t.timeout, func() {
+		if t.expire(key, func() *time.Timer { return expiry }) {
+			t.onExpire(key)
+		}
+	})
+	return expiry
+}
+
+// expire drops key if timer is still the one tracking it, timer is
+// read with the lock held as it is set once start returns
+func (t *typingTracker) expire(key typingKey, timer func() *time.Timer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timers[key] != timer() {
+		return false
+	}
+	delete(t.timers, key)
+	return true
+}
+
+// forget drops the typing indicators from and to email without expiring them
+func (t *typingTracker) forget(email string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, timer := range t.timers {
+		if key.from == email || key.to == email {
+			timer.Stop()
+			delete(t.timers, key)
+		}
+	}
+}
+
+// SetTyping tells toEmail whether the caller is typing to them,
+// the indicator expires unless the client keeps refreshing it.
+// Nothing is published when either user blocked the other
+func (d *Chat) SetTyping(ctx context.Context, toEmail string, typing bool) (bool, error) {
+	claims, err := caller(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.Val.Var(toEmail, "required,email")
+	if err != nil {
+		return false, proto.WrapError(proto.ErrInvalidArgument, err, reqValidationErr)
+	}
+
+	blocked, err := d.db.Blocked(ctx, claims.Email, toEmail)
+	if err != nil {
+		return false, d.dataError(err)
+	}
+	if blocked {
+		return true, nil
+	}
+
+	key := typingKey{from: claims.Email, to: toEmail}
+	if !d.typing.set(key, typing) {
+		return true, nil
+	}
+
+	err = d.publishTyping(key, typing)
+	if err != nil {
+		return false, proto.WrapError(proto.ErrInternal, err, brokerErr)
+	}
+
+	return true, nil
+}
+
+// publishTyping publishes a typing event to the recipient topic
+func (d *Chat) publishTyping(key typingKey, typing bool) error {
+	ev := proto.Typing{
+		FromEmail: key.from,
+		ToEmail:   key.to,
+		Typing:    typing,
+	}
+	if typing {
+		expiresAt := time.Now().UTC().Add(d.cfg.TypingTimeout)
+		ev.ExpiresAt = &expiresAt
+	}
+
+	err := d.publish(event.Typing, ev, key.to)
+	if err != nil {
+		d.rlog.Err(err).Msg(publishTypingErr)
+	}
+	return err
+}
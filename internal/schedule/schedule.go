@@ -0,0 +1,513 @@
+package schedule
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// messages waiting to be sent
+	pendingDir = "pending"
+	// messages an instance is sending
+	claimedDir = "claimed"
+	// records of the messages handed to dispatch, a message is never dispatched twice
+	sentDir = "sent"
+	// messages being written before they are moved to pending
+	tmpDir = "tmp"
+	// time allowed to dispatch one message
+	dispatchTimeout = 10 * time.Second
+	// time the records of the dispatched messages are kept
+	sentRetention = 7 * 24 * time.Hour
+	// Errors
+	errDispatch = "scheduled message dispatch error"
+)
+
+var (
+	// ErrNotFound is returned when no pending message has the requested MessageUUID
+	ErrNotFound = errors.New("scheduled message not found")
+	// ErrExists is returned when a message with the same MessageUUID is already scheduled
+	ErrExists = errors.New("scheduled message already exists")
+)
+
+// Scheduler holds chat messages until they are due and hands them to a dispatch function.
+// Messages are kept as files under a directory that every instance shares, in a directory per
+// sender so that the messages of one are found without reading the others. File names start
+// with the due time so the due messages are found without reading them. An instance claims a
+// message by moving its file to a name starting with the end of its lease, only one of them
+// can, and claims of instances that died are handed back once the lease passed. A record of
+// every message is kept there before it is dispatched so that no instance dispatches it again,
+// a message whose dispatch was cut short by a crash is dropped rather than sent twice
+type Scheduler struct {
+	dir      string
+	interval time.Duration
+	lease    time.Duration
+	dispatch func(context.Context, proto.ChatMessage) error
+
+	quitCh chan chan struct{}
+}
+
+// NewScheduler returns a scheduler keeping messages under dir and looking for the due ones every interval
+func NewScheduler(dir string, interval, lease time.Duration) (*Scheduler, error) {
+	for _, sub := range []string{pendingDir, claimedDir, sentDir, tmpDir} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0700)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create schedule directory")
+		}
+	}
+
+	s := &Scheduler{
+		dir:      dir,
+		interval: interval,
+		lease:    lease,
+		dispatch: func(context.Context, proto.ChatMessage) error { return nil },
+		quitCh:   make(chan chan struct{}),
+	}
+	err := s.moveToSenders()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OnDue sets the function due messages are handed to, a message whose dispatch
+// fails is tried again later. It must be set before Run is called
+func (s *Scheduler) OnDue(fn func(context.Context, proto.ChatMessage) error) {
+	s.dispatch = fn
+}
+
+// Schedule holds msg until msg.SendAt, ErrExists is returned when a message
+// with the same MessageUUID is already scheduled or was sent
+func (s *Scheduler) Schedule(msg proto.ChatMessage) error {
+	if msg.SendAt == nil {
+		return errors.New("scheduled message has no send time")
+	}
+
+	existing, err := s.find(msg.FromEmail, msg.MessageUUID)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		return errors.Wrap(ErrExists, msg.MessageUUID)
+	}
+	_, err = os.Stat(s.sentPath(msg))
+	if err == nil {
+		return errors.Wrap(ErrExists, msg.MessageUUID)
+	}
+	if !os.IsNotExist(err) {
+		return errors.Wrap(err, "cannot find scheduled message")
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode scheduled message")
+	}
+
+	// written aside first so that pending messages are always complete
+	tmp, err := ioutil.TempFile(filepath.Join(s.dir, tmpDir), "message-")
+	if err != nil {
+		return errors.Wrap(err, "cannot write scheduled message")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "cannot write scheduled message")
+	}
+	err = tmp.Close()
+	if err != nil {
+		return errors.Wrap(err, "cannot write scheduled message")
+	}
+
+	err = s.store(tmp.Name(), pendingDir, senderDir(msg.FromEmail), fileName(msg))
+	if err != nil {
+		return errors.Wrap(err, "cannot store scheduled message")
+	}
+	return nil
+}
+
+// List returns the pending messages of fromEmail, the first due first
+func (s *Scheduler) List(fromEmail string) ([]proto.ChatMessage, error) {
+	sender := senderDir(fromEmail)
+	names, err := readNames(filepath.Join(s.dir, pendingDir, sender))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []proto.ChatMessage
+	for _, name := range names {
+		msg, err := s.read(filepath.Join(s.dir, pendingDir, sender, name))
+		// sent or cancelled in the meantime
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, msg)
+	}
+	return res, nil
+}
+
+// Cancel drops a pending message of fromEmail, ErrNotFound is returned
+// when there is none, including when it is already being sent
+func (s *Scheduler) Cancel(fromEmail, messageUUID string) (proto.ChatMessage, error) {
+	name, err := s.find(fromEmail, messageUUID)
+	if err != nil {
+		return proto.ChatMessage{}, err
+	}
+	if name == "" || filepath.Base(filepath.Dir(filepath.Dir(name))) != pendingDir {
+		return proto.ChatMessage{}, errors.Wrap(ErrNotFound, messageUUID)
+	}
+
+	msg, err := s.read(name)
+	if os.IsNotExist(errors.Cause(err)) {
+		return proto.ChatMessage{}, errors.Wrap(ErrNotFound, messageUUID)
+	}
+	if err != nil {
+		return proto.ChatMessage{}, err
+	}
+
+	// the message is either claimed or removed, never both
+	err = os.Remove(name)
+	if os.IsNotExist(err) {
+		return proto.ChatMessage{}, errors.Wrap(ErrNotFound, messageUUID)
+	}
+	if err != nil {
+		return proto.ChatMessage{}, errors.Wrap(err, "cannot cancel scheduled message")
+	}
+	return msg, nil
+}
+
+// Run dispatches the due messages every interval until Stop is called
+func (s *Scheduler) Run() error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.recover()
+			s.dispatchDue(time.Now().UTC())
+		case q := <-s.quitCh:
+			close(q)
+			return nil
+		}
+	}
+}
+
+// Stop stops dispatching messages
+func (s *Scheduler) Stop() {
+	q := make(chan struct{})
+	s.quitCh <- q
+	// This blocks until Run() closes q and returns
+	<-q
+}
+
+// dispatchDue claims and dispatches the messages due at now
+func (s *Scheduler) dispatchDue(now time.Time) {
+	names, err := s.pending()
+	if err != nil {
+		log.Printf("%v : %v", errDispatch, err)
+		return
+	}
+
+	for _, name := range names {
+		if !due(filepath.Base(name), now) {
+			// names are in due time order
+			return
+		}
+
+		// the lease is part of the name the message is claimed under, it is
+		// known to every instance as soon as the message is claimed
+		sender, base := filepath.Dir(name), filepath.Base(name)
+		claimedName := claimName(base, now.Add(s.lease))
+		claimed := filepath.Join(s.dir, claimedDir, sender, claimedName)
+		err := s.store(filepath.Join(s.dir, pendingDir, name), claimedDir, sender, claimedName)
+		// claimed by another instance or cancelled
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+			continue
+		}
+
+		msg, err := s.read(claimed)
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+			continue
+		}
+
+		// recorded before the dispatch so that it never happens twice,
+		// an instance that died dispatching it may have sent it already
+		sent, err := s.recordSent(msg)
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+			_ = s.store(claimed, pendingDir, sender, base)
+			continue
+		}
+		if !sent {
+			log.Printf("%v : %s : dispatched before, dropping it", errDispatch, msg.MessageUUID)
+			_ = os.Remove(claimed)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dispatchTimeout)
+		err = s.dispatch(ctx, msg)
+		cancel()
+		if err != nil {
+			log.Printf("%v : %s : %v", errDispatch, msg.MessageUUID, err)
+			// tried again on the next tick
+			_ = os.Remove(s.sentPath(msg))
+			_ = s.store(claimed, pendingDir, sender, base)
+			continue
+		}
+
+		err = os.Remove(claimed)
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+		}
+	}
+}
+
+// recordSent records that msg is dispatched and reports whether
+// it was not already, only one instance can record it
+func (s *Scheduler) recordSent(msg proto.ChatMessage) (bool, error) {
+	path := s.sentPath(msg)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot create schedule directory")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot record sent message")
+	}
+	err = f.Close()
+	if err != nil {
+		return false, errors.Wrap(err, "cannot record sent message")
+	}
+	return true, nil
+}
+
+// sentPath returns the path of the record of a dispatched message
+func (s *Scheduler) sentPath(msg proto.ChatMessage) string {
+	return filepath.Join(s.dir, sentDir, senderDir(msg.FromEmail), msg.MessageUUID)
+}
+
+// recover hands the messages whose lease passed back, the instance that claimed them
+// is gone, and drops the records of the messages dispatched before sentRetention
+func (s *Scheduler) recover() {
+	now := time.Now()
+
+	senders, err := readNames(filepath.Join(s.dir, claimedDir))
+	if err != nil {
+		log.Printf("%v : %v", errDispatch, err)
+		return
+	}
+	for _, sender := range senders {
+		names, err := readNames(filepath.Join(s.dir, claimedDir, sender))
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+			continue
+		}
+		for _, name := range names {
+			path := filepath.Join(s.dir, claimedDir, sender, name)
+			leaseEnd, base, ok := claimLease(name)
+			// claimed before leases were part of the name, the lease started when it was moved
+			if !ok {
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				leaseEnd, base = info.ModTime().Add(s.lease), name
+			}
+			if now.Before(leaseEnd) {
+				continue
+			}
+			_ = s.store(path, pendingDir, sender, base)
+		}
+	}
+
+	senders, err = readNames(filepath.Join(s.dir, sentDir))
+	if err != nil {
+		log.Printf("%v : %v", errDispatch, err)
+		return
+	}
+	for _, sender := range senders {
+		infos, err := ioutil.ReadDir(filepath.Join(s.dir, sentDir, sender))
+		if err != nil {
+			log.Printf("%v : %v", errDispatch, err)
+			continue
+		}
+		for _, info := range infos {
+			if now.Sub(info.ModTime()) > sentRetention {
+				_ = os.Remove(filepath.Join(s.dir, sentDir, sender, info.Name()))
+			}
+		}
+	}
+}
+
+// pending returns the paths of the pending messages relative to the pending
+// directory, the first due first whoever sends them
+func (s *Scheduler) pending() ([]string, error) {
+	senders, err := readNames(filepath.Join(s.dir, pendingDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, sender := range senders {
+		messages, err := readNames(filepath.Join(s.dir, pendingDir, sender))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range messages {
+			names = append(names, filepath.Join(sender, name))
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return filepath.Base(names[i]) < filepath.Base(names[j])
+	})
+	return names, nil
+}
+
+// store moves the file at path to name in the directory of a sender under sub
+func (s *Scheduler) store(path, sub, sender, name string) error {
+	dir := filepath.Join(s.dir, sub, sender)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "cannot create schedule directory")
+	}
+	return os.Rename(path, filepath.Join(dir, name))
+}
+
+// moveToSenders moves the messages kept before they had a directory per sender into theirs
+func (s *Scheduler) moveToSenders() error {
+	for _, sub := range []string{pendingDir, claimedDir} {
+		infos, err := ioutil.ReadDir(filepath.Join(s.dir, sub))
+		if err != nil {
+			return errors.Wrap(err, "cannot list scheduled messages")
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			path := filepath.Join(s.dir, sub, info.Name())
+			msg, err := s.read(path)
+			if err != nil {
+				return err
+			}
+			err = s.store(path, sub, senderDir(msg.FromEmail), info.Name())
+			if err != nil {
+				return errors.Wrap(err, "cannot move scheduled message")
+			}
+		}
+	}
+	return nil
+}
+
+// find returns the path of a pending or claimed message of fromEmail, empty if there is none
+func (s *Scheduler) find(fromEmail, messageUUID string) (string, error) {
+	for _, sub := range []string{pendingDir, claimedDir} {
+		matches, err := filepath.Glob(filepath.Join(s.dir, sub, senderDir(fromEmail), "*_"+messageUUID+".json"))
+		if err != nil {
+			return "", errors.Wrap(err, "cannot find scheduled message")
+		}
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
+	}
+	return "", nil
+}
+
+func (s *Scheduler) read(path string) (proto.ChatMessage, error) {
+	var msg proto.ChatMessage
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return msg, errors.Wrap(err, "cannot read scheduled message")
+	}
+	err = json.Unmarshal(b, &msg)
+	if err != nil {
+		return msg, errors.Wrap(err, "cannot decode scheduled message")
+	}
+	return msg, nil
+}
+
+// readNames returns the sorted names in a directory, none when it doesn't exist
+func readNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list scheduled messages")
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list scheduled messages")
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// senderDir returns the name of the directory of the messages of a sender,
+// emails are hashed so that any of them makes a valid name
+func senderDir(fromEmail string) string {
+	sum := sha256.Sum256([]byte(fromEmail))
+	return hex.EncodeToString(sum[:])
+}
+
+// fileName returns the name of the file of a scheduled message,
+// the zero padded due time comes first so that names sort by it
+func fileName(msg proto.ChatMessage) string {
+	return fmt.Sprintf("%020d_%s.json", msg.SendAt.UnixNano(), msg.MessageUUID)
+}
+
+// claimName returns the name a message is claimed under until leaseEnd
+func claimName(name string, leaseEnd time.Time) string {
+	return fmt.Sprintf("%020d_%s", leaseEnd.UnixNano(), name)
+}
+
+// claimLease returns the end of the lease of a claimed message and its pending name
+func claimLease(name string) (time.Time, string, bool) {
+	i := strings.IndexByte(name, '_')
+	if i < 0 || strings.Count(name, "_") < 2 {
+		return time.Time{}, "", false
+	}
+	at, err := strconv.ParseInt(name[:i], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(0, at), name[i+1:], true
+}
+
+// due reports whether the message of a file is due at now
+func due(name string, now time.Time) bool {
+	i := strings.IndexByte(name, '_')
+	if i < 0 {
+		return false
+	}
+	at, err := strconv.ParseInt(name[:i], 10, 64)
+	return err == nil && at <= now.UnixNano()
+}
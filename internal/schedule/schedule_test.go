@@ -0,0 +1,248 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	s, err := NewScheduler(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func scheduled(from, messageUUID string, sendAt time.Time) proto.ChatMessage {
+	return proto.ChatMessage{
+		FromEmail:   from,
+		ToEmail:     "to@x.com",
+		MessageUUID: messageUUID,
+		MessageText: messageUUID,
+		SendAt:      &sendAt,
+	}
+}
+
+func uuids(messages []proto.ChatMessage) []string {
+	res := make([]string, len(messages))
+	for i, msg := range messages {
+		res[i] = msg.MessageUUID
+	}
+	return res
+}
+
+func TestListOnlyReadsTheMessagesOfTheSender(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Now().UTC()
+
+	for _, msg := range []proto.ChatMessage{
+		scheduled("a@x.com", "a2", now.Add(2*time.Hour)),
+		scheduled("b@x.com", "b1", now.Add(time.Hour)),
+		scheduled("a@x.com", "a1", now.Add(time.Hour)),
+	} {
+		err := s.Schedule(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a file of another sender that can't be read doesn't get in the way
+	err := ioutil.WriteFile(filepath.Join(s.dir, pendingDir, senderDir("b@x.com"), "0_broken.json"), []byte("{"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := s.List("a@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uuids(messages); len(got) != 2 || got[0] != "a1" || got[1] != "a2" {
+		t.Fatalf("List = %v, want [a1 a2]", got)
+	}
+	if messages, _ := s.List("c@x.com"); len(messages) != 0 {
+		t.Fatalf("List of a sender without messages = %v", uuids(messages))
+	}
+}
+
+func TestScheduleRejectsTheSameMessageTwice(t *testing.T) {
+	s := newTestScheduler(t)
+	msg := scheduled("a@x.com", "m", time.Now().Add(time.Hour))
+
+	err := s.Schedule(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Schedule(msg)
+	if errors.Cause(err) != ErrExists {
+		t.Fatalf("Schedule again = %v, want %v", err, ErrExists)
+	}
+}
+
+func TestCancelOnlyTheMessagesOfTheSender(t *testing.T) {
+	s := newTestScheduler(t)
+	err := s.Schedule(scheduled("a@x.com", "m", time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Cancel("b@x.com", "m")
+	if errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Cancel by someone else = %v, want %v", err, ErrNotFound)
+	}
+	msg, err := s.Cancel("a@x.com", "m")
+	if err != nil || msg.MessageUUID != "m" {
+		t.Fatalf("Cancel = %v, %v", msg.MessageUUID, err)
+	}
+	_, err = s.Cancel("a@x.com", "m")
+	if errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Cancel again = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDispatchDueInDueOrder(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Now().UTC()
+
+	for _, msg := range []proto.ChatMessage{
+		scheduled("a@x.com", "late", now.Add(time.Hour)),
+		scheduled("b@x.com", "second", now.Add(-time.Minute)),
+		scheduled("a@x.com", "first", now.Add(-time.Hour)),
+		scheduled("c@x.com", "failing", now.Add(-time.Second)),
+	} {
+		err := s.Schedule(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dispatched []string
+	s.OnDue(func(ctx context.Context, msg proto.ChatMessage) error {
+		dispatched = append(dispatched, msg.MessageUUID)
+		if msg.MessageUUID == "failing" {
+			return errors.New("dispatch failed")
+		}
+		return nil
+	})
+	s.dispatchDue(now)
+
+	if len(dispatched) != 3 || dispatched[0] != "first" || dispatched[1] != "second" || dispatched[2] != "failing" {
+		t.Fatalf("dispatched %v, want [first second failing]", dispatched)
+	}
+	// the failed one waits for the next tick
+	names, err := s.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("pending = %v, want failing and late", names)
+	}
+	if messages, _ := s.List("c@x.com"); len(messages) != 1 {
+		t.Fatal("failed message not handed back")
+	}
+}
+
+func TestRecoverHandsBackExpiredClaims(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Now().UTC()
+	expired := scheduled("a@x.com", "expired", now.Add(-time.Hour))
+	leased := scheduled("a@x.com", "leased", now.Add(-time.Hour))
+
+	// an instance claimed a message and died, another one just claimed one that was
+	// scheduled long ago. Only the lease in the name counts, not when the files were written
+	sender := senderDir("a@x.com")
+	for msg, leaseEnd := range map[*proto.ChatMessage]time.Time{&expired: now.Add(-time.Second), &leased: now.Add(s.lease)} {
+		err := s.Schedule(*msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.store(filepath.Join(s.dir, pendingDir, sender, fileName(*msg)), claimedDir, sender, claimName(fileName(*msg), leaseEnd))
+		if err != nil {
+			t.Fatal(err)
+		}
+		old := now.Add(-2 * s.lease)
+		err = os.Chtimes(filepath.Join(s.dir, claimedDir, sender, claimName(fileName(*msg), leaseEnd)), old, old)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if messages, _ := s.List("a@x.com"); len(messages) != 0 {
+		t.Fatal("claimed messages still listed")
+	}
+
+	s.recover()
+	if messages, _ := s.List("a@x.com"); len(messages) != 1 || messages[0].MessageUUID != "expired" {
+		t.Fatalf("handed back %v, want [expired]", uuids(messages))
+	}
+}
+
+func TestDispatchedMessagesAreNeverDispatchedAgain(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Now().UTC()
+	msg := scheduled("a@x.com", "m", now.Add(-time.Minute))
+	err := s.Schedule(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an instance dispatched the message and died before dropping its claim
+	sent, err := s.recordSent(msg)
+	if err != nil || !sent {
+		t.Fatalf("recordSent = %v, %v", sent, err)
+	}
+
+	var dispatched []string
+	s.OnDue(func(ctx context.Context, msg proto.ChatMessage) error {
+		dispatched = append(dispatched, msg.MessageUUID)
+		return nil
+	})
+	s.dispatchDue(now)
+	if len(dispatched) != 0 {
+		t.Fatalf("dispatched %v again", dispatched)
+	}
+	if names, _ := s.pending(); len(names) != 0 {
+		t.Fatalf("pending = %v, want none", names)
+	}
+
+	// nor is it held again
+	err = s.Schedule(msg)
+	if errors.Cause(err) != ErrExists {
+		t.Fatalf("Schedule a sent message = %v, want %v", err, ErrExists)
+	}
+}
+
+func TestMessagesOfTheFlatLayoutMoveToTheirSender(t *testing.T) {
+	s := newTestScheduler(t)
+	msg := scheduled("a@x.com", "m", time.Now().Add(time.Hour))
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(s.dir, pendingDir, fileName(msg)), b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewScheduler(s.dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := s.List("a@x.com")
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("List after the move = %v, %v", uuids(messages), err)
+	}
+}
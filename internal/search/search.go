@@ -0,0 +1,309 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// longer words are not indexed
+	maxTermLength = 64
+	// prefix matches are worth less than whole word matches
+	prefixWeight = 0.5
+	// indexed terms a query term expands to at most, the shortest come first
+	maxPrefixTerms = 50
+	// runes of context kept before the first match of a snippet
+	snippetLead = 30
+	// runes a snippet is cut at
+	snippetLength = 160
+	// marks text left out of a snippet
+	ellipsis = "…"
+)
+
+// Index is an inverted index of documents keyed by id, it is not safe
+// for concurrent use
+type Index struct {
+	// term frequency per document id, keyed by term
+	postings map[string]map[string]int
+	// distinct terms of every document
+	docs map[string][]string
+	// every indexed term in order, for prefix lookups
+	terms []string
+}
+
+// Hit is a document matching every term of a query
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Part is a piece of a snippet, Match is set on the words matching the query
+type Part struct {
+	Text  string
+	Match bool
+}
+
+// token is a folded word of a text and where it is in the text
+type token struct {
+	term       string
+	start, end int
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string][]string),
+	}
+}
+
+// Add indexes the text of a document, replacing what was indexed for it before
+func (ix *Index) Add(id, text string) {
+	ix.Remove(id)
+
+	freq := make(map[string]int)
+	for _, t := range tokenize(text) {
+		freq[t.term]++
+	}
+	if len(freq) == 0 {
+		return
+	}
+
+	terms := make([]string, 0, len(freq))
+	for term, n := range freq {
+		docs, ok := ix.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			ix.postings[term] = docs
+			ix.insertTerm(term)
+		}
+		docs[id] = n
+		terms = append(terms, term)
+	}
+	ix.docs[id] = terms
+}
+
+// Len returns the number of documents in the index
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(id string) {
+	for _, term := range ix.docs[id] {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+			ix.removeTerm(term)
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// Search returns the documents matching every query term, a query term matches the words
+// it is a prefix of, up to maxPrefixTerms of them. Documents score higher the more often
+// they use rare query terms
+func (ix *Index) Search(query []string) []Hit {
+	if len(query) == 0 {
+		return nil
+	}
+
+	total := float64(len(ix.docs))
+	var scores map[string]float64
+	for _, q := range query {
+		termScores := make(map[string]float64)
+		for _, term := range ix.prefixed(q) {
+			docs := ix.postings[term]
+			weight := math.Log(1 + total/float64(len(docs)))
+			if term != q {
+				weight *= prefixWeight
+			}
+			for id, n := range docs {
+				termScores[id] += float64(n) * weight
+			}
+		}
+
+		// documents have to match every term
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id, score := range scores {
+			s, ok := termScores[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] = score + s
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	return hits
+}
+
+// prefixed returns the indexed terms starting with prefix, up to maxPrefixTerms
+// of them with the shortest first so that short prefixes don't expand to the whole index
+func (ix *Index) prefixed(prefix string) []string {
+	i := sort.SearchStrings(ix.terms, prefix)
+	j := i
+	for j < len(ix.terms) && strings.HasPrefix(ix.terms[j], prefix) {
+		j++
+	}
+	if j-i <= maxPrefixTerms {
+		return ix.terms[i:j]
+	}
+
+	terms := append([]string(nil), ix.terms[i:j]...)
+	sort.SliceStable(terms, func(a, b int) bool {
+		return len(terms[a]) < len(terms[b])
+	})
+	return terms[:maxPrefixTerms]
+}
+
+func (ix *Index) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) removeTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// Terms returns the distinct folded words of a query in the order they appear
+func Terms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// Words returns the folded words of text in the order they appear, repeated ones included
+func Words(text string) []string {
+	tokens := tokenize(text)
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.term
+	}
+	return words
+}
+
+// Snippet returns the part of text around its first word matching a query term,
+// split so that the matching words can be highlighted
+func Snippet(text string, query []string) []Part {
+	matches := func(term string) bool {
+		for _, q := range query {
+			if strings.HasPrefix(term, q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	tokens := tokenize(text)
+	start := 0
+	for _, t := range tokens {
+		if matches(t.term) {
+			start = t.start
+			break
+		}
+	}
+
+	// keep some context before the first match, starting on a word
+	lead := start
+	for n := 0; lead > 0 && n < snippetLead; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:lead])
+		lead -= size
+	}
+	if lead > 0 {
+		for _, t := range tokens {
+			if t.start >= lead {
+				lead = t.start
+				break
+			}
+		}
+	}
+
+	end := lead
+	for n := 0; end < len(text) && n < snippetLength; n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var parts []Part
+	add := func(s string, match bool) {
+		if s != "" {
+			parts = append(parts, Part{Text: s, Match: match})
+		}
+	}
+
+	if lead > 0 {
+		add(ellipsis, false)
+	}
+	pos := lead
+	for _, t := range tokens {
+		if t.start < lead || t.end > end || !matches(t.term) {
+			continue
+		}
+		add(text[pos:t.start], false)
+		add(text[t.start:t.end], true)
+		pos = t.end
+	}
+	add(text[pos:end], false)
+	if end < len(text) {
+		add(ellipsis, false)
+	}
+	return parts
+}
+
+// tokenize splits text into folded words, anything but letters and digits separates words
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		if utf8.RuneCountInString(word) <= maxTermLength {
+			tokens = append(tokens, token{term: fold(word), start: start, end: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
+
+// fold maps the case variants of a word to the same term
+func fold(word string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, word)
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ids(hits []Hit) map[string]float64 {
+	res := make(map[string]float64, len(hits))
+	for _, hit := range hits {
+		res[hit.ID] = hit.Score
+	}
+	return res
+}
+
+func TestSearchMatchesEveryTerm(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", "The delivery to depot 7 is late")
+	ix.Add("2", "delivered on time")
+	ix.Add("3", "Depot closed")
+
+	hits := ids(ix.Search(Terms("deliv")))
+	if len(hits) != 2 {
+		t.Fatalf("deliv matched %v, want 1 and 2", hits)
+	}
+	hits = ids(ix.Search(Terms("DELIVERY depot")))
+	if _, ok := hits["1"]; !ok || len(hits) != 1 {
+		t.Fatalf("delivery depot matched %v, want 1", hits)
+	}
+	if hits := ix.Search(Terms("nope")); len(hits) != 0 {
+		t.Fatalf("nope matched %v", hits)
+	}
+}
+
+func TestSearchPrefersWholeWords(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("word", "depot")
+	ix.Add("prefix", "depots")
+
+	hits := ids(ix.Search([]string{"depot"}))
+	if hits["word"] <= hits["prefix"] {
+		t.Fatalf("whole word scored %v, prefix %v", hits["word"], hits["prefix"])
+	}
+}
+
+func TestRemoveAndReplace(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", "first text")
+	ix.Add("2", "second text")
+	if ix.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", ix.Len())
+	}
+
+	ix.Add("1", "edited")
+	if hits := ix.Search([]string{"first"}); len(hits) != 0 {
+		t.Fatalf("replaced text still matches: %v", hits)
+	}
+	ix.Remove("2")
+	if hits := ix.Search([]string{"text"}); len(hits) != 0 {
+		t.Fatalf("removed document still matches: %v", hits)
+	}
+	if ix.Len() != 1 || len(ix.terms) != 1 {
+		t.Fatalf("Len = %d with terms %v, want only edited", ix.Len(), ix.terms)
+	}
+}
+
+func TestPrefixExpansionIsCapped(t *testing.T) {
+	ix := NewIndex()
+	for i := 0; i < 2*maxPrefixTerms; i++ {
+		ix.Add(fmt.Sprint(i), fmt.Sprintf("a%03d", i))
+	}
+	ix.Add("short", "ab")
+
+	terms := ix.prefixed("a")
+	if len(terms) != maxPrefixTerms {
+		t.Fatalf("a expanded to %d terms, want %d", len(terms), maxPrefixTerms)
+	}
+	if terms[0] != "ab" {
+		t.Fatalf("shortest term %q not kept first", terms[0])
+	}
+	if hits := ix.Search([]string{"a"}); len(hits) != maxPrefixTerms {
+		t.Fatalf("a matched %d documents, want %d", len(hits), maxPrefixTerms)
+	}
+}
+
+func TestSnippetHighlightsMatches(t *testing.T) {
+	parts := Snippet("Delivery to depot 7", []string{"depot"})
+	var matched []string
+	for _, p := range parts {
+		if p.Match {
+			matched = append(matched, p.Text)
+		}
+	}
+	if len(matched) != 1 || matched[0] != "depot" {
+		t.Fatalf("matched %v, want depot", matched)
+	}
+}
@@ -0,0 +1,271 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	// registers the gif decoder used by image.Decode
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rumsrami/example-service/internal/platform/blob"
+	"github.com/rumsrami/example-service/internal/proto"
+)
+
+const (
+	// jobs waiting for a worker, uploads past that are not thumbnailed
+	queueSize = 256
+	// larger images are not decoded
+	maxPixels = 50 * 1000 * 1000
+	// quality of the jpeg thumbnails
+	jpegQuality = 80
+	// Errors
+	errTooLarge = "image is too large to be decoded"
+)
+
+// Job is an image attachment to make a thumbnail of
+type Job struct {
+	AttachmentID string
+	// key of the image in the blob store
+	BlobKey string
+}
+
+// Result is what is known of an image once its Job is processed
+type Result struct {
+	Job
+	Width     int
+	Height    int
+	Thumbnail proto.Thumbnail
+	// key of the thumbnail in the blob store, and when it started to be stored there
+	ThumbnailKey string
+	StoredAt     time.Time
+	Err          error
+}
+
+// Generator makes thumbnails of images on a bounded pool of workers
+type Generator struct {
+	blobs   blob.Store
+	workers int
+	// longest side of a thumbnail in pixels
+	size   int
+	onDone func(Result)
+
+	jobs   chan Job
+	quitCh chan chan struct{}
+}
+
+// NewGenerator returns a thumbnail generator running workers at once,
+// thumbnails fit in a size by size square
+func NewGenerator(blobs blob.Store, workers, size int) *Generator {
+	return &Generator{
+		blobs:   blobs,
+		workers: workers,
+		size:    size,
+		onDone:  func(Result) {},
+		jobs:    make(chan Job, queueSize),
+		quitCh:  make(chan chan struct{}),
+	}
+}
+
+// OnDone sets the function called with the result of every job,
+// it must be set before Run is called
+func (g *Generator) OnDone(fn func(Result)) {
+	g.onDone = fn
+}
+
+// Enqueue hands an image over to the workers, false is returned when the queue is full
+func (g *Generator) Enqueue(job Job) bool {
+	select {
+	case g.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes the queued jobs until Stop is called,
+// the jobs in progress are finished before it returns
+func (g *Generator) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < g.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-g.jobs:
+					g.onDone(g.process(ctx, job))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	q := <-g.quitCh
+	cancel()
+	wg.Wait()
+	close(q)
+	return nil
+}
+
+// Stop stops the workers
+func (g *Generator) Stop() {
+	q := make(chan struct{})
+	g.quitCh <- q
+	// This blocks until Run() closes q and returns
+	<-q
+}
+
+// process reads the dimensions of an image and stores its thumbnail
+func (g *Generator) process(ctx context.Context, job Job) Result {
+	res := Result{Job: job}
+
+	// the header is read first so that huge images are never decoded
+	cfg, format, err := g.decodeConfig(ctx, job.BlobKey)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Width, res.Height = cfg.Width, cfg.Height
+	if cfg.Width*cfg.Height > maxPixels {
+		res.Err = errors.New(errTooLarge)
+		return res
+	}
+
+	img, err := g.decode(ctx, job.BlobKey)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	thumb := scale(img, g.size)
+
+	// jpeg is smaller, other formats may be transparent
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		res.Err = errors.Wrap(err, "cannot encode thumbnail")
+		return res
+	}
+
+	size := int64(buf.Len())
+	storedAt := time.Now().UTC()
+	key, _, err := g.blobs.Put(ctx, &buf)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.ThumbnailKey = key
+	res.StoredAt = storedAt
+	res.Thumbnail = proto.Thumbnail{
+		Width:       thumb.Bounds().Dx(),
+		Height:      thumb.Bounds().Dy(),
+		ContentType: contentType,
+		Size:        size,
+	}
+	return res
+}
+
+func (g *Generator) decodeConfig(ctx context.Context, key string) (image.Config, string, error) {
+	r, err := g.blobs.Get(ctx, key)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer r.Close()
+
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return image.Config{}, "", errors.Wrap(err, "cannot read image header")
+	}
+	return cfg, format, nil
+}
+
+func (g *Generator) decode(ctx context.Context, key string) (image.Image, error) {
+	r, err := g.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode image")
+	}
+	return img, nil
+}
+
+// scale returns src shrunk to fit in a size by size square, every thumbnail
+// pixel is the average of the source pixels it covers. Smaller images are kept as is
+func scale(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	w, h := sw, sh
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, sh*size/sw
+		} else {
+			w, h = sw*size/sh, size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*sh/h, b.Min.Y+(y+1)*sh/h
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*sw/w, b.Min.X+(x+1)*sw/w
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// Supported reports whether thumbnails can be made of images of contentType
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}